@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// auditTimeout bounds the write of an audit entry to the audit table
+const auditTimeout = 5 * time.Second
+
+// auditIdentityKey is the context key of the identity set with ContextWithAuditIdentity
+type auditIdentityKey struct{}
+
+// AuditEntry is the record of one OpExec or OpBulk call
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	// Table is the target of bulk loads, comma-separated for InsertBulkMulti;
+	// Statement is the SQL of execs, with the statements of a RunBatch
+	// joined by ";\n"
+	Table     string `json:"table,omitempty"`
+	Statement string `json:"statement,omitempty"`
+	// InputRows is the number of rows given to a bulk load; Rows is the
+	// number of rows affected or loaded
+	InputRows int           `json:"input_rows,omitempty"`
+	Rows      int           `json:"rows"`
+	Identity  string        `json:"identity,omitempty"`
+	Duration  time.Duration `json:"duration_ns"`
+	Success   bool          `json:"success"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// WithAuditWriter writes an AuditEntry for every Exec, ExecPrepared and bulk
+// load call of the DB to w, as one JSON object per line. Writes to w are
+// serialized.
+func WithAuditWriter(w io.Writer) PoolOption {
+	return func(o *poolOptions) {
+		o.auditWriter = w
+	}
+}
+
+// WithAuditTable inserts an AuditEntry for every Exec, ExecPrepared and bulk
+// load call of the DB into table, which must exist with the columns
+//
+//	time timestamptz, operation text, table_name text, statement text,
+//	input_rows bigint, rows bigint, identity text, duration interval,
+//	success boolean, error text
+//
+// The entries are inserted on the pool of the DB, after the call, and are
+// not audited themselves. A failed insert is logged and does not fail the call.
+func WithAuditTable(table string) PoolOption {
+	return func(o *poolOptions) {
+		o.auditTable = table
+	}
+}
+
+// WithAuditIdentity makes identity the source of the Identity of the audit
+// entries, e.g. to read the user an auth middleware put in the context. Without
+// it the identity is the one set with ContextWithAuditIdentity.
+func WithAuditIdentity(identity func(context.Context) string) PoolOption {
+	return func(o *poolOptions) {
+		o.auditIdentity = identity
+	}
+}
+
+// ContextWithAuditIdentity returns a copy of ctx whose calls are audited as
+// made by identity
+func ContextWithAuditIdentity(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, auditIdentityKey{}, identity)
+}
+
+// auditLog writes the audit entries of a DB
+type auditLog struct {
+	d        *DB
+	table    string
+	identity func(context.Context) string
+	logger   pgx.Logger
+
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+// newAuditLog returns the audit log of d set up by options, or nil when
+// nothing is audited
+func newAuditLog(d *DB, options *poolOptions) *auditLog {
+	if options.auditWriter == nil && options.auditTable == "" {
+		return nil
+	}
+
+	identity := options.auditIdentity
+	if identity == nil {
+		identity = func(ctx context.Context) string {
+			id, _ := ctx.Value(auditIdentityKey{}).(string)
+			return id
+		}
+	}
+	return &auditLog{
+		d:        d,
+		table:    options.auditTable,
+		identity: identity,
+		logger:   eventLogger(options.logger),
+		writer:   options.auditWriter,
+	}
+}
+
+// record writes the entry of call, which produced rows rows, if its operation
+// is audited
+func (a *auditLog) record(call *queryCall, rows int, err error) {
+	if a == nil || (call.op != OpExec && call.op != OpBulk) {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:      call.start,
+		Operation: call.op,
+		Table:     call.table,
+		Statement: call.statement,
+		InputRows: call.inputRows,
+		Rows:      rows,
+		Identity:  a.identity(call.parent),
+		Duration:  time.Since(call.start),
+		Success:   err == nil,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if a.writer != nil {
+		if err := a.writeJSON(entry); err != nil {
+			a.logger.Log(call.parent, pgx.LogLevelError, "audit write failed", map[string]interface{}{"err": err})
+		}
+	}
+	if a.table != "" {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(call.parent), auditTimeout)
+		defer cancel()
+		if err := a.insert(ctx, entry); err != nil {
+			a.logger.Log(call.parent, pgx.LogLevelError, "audit insert failed", map[string]interface{}{"err": err, "table": a.table})
+		}
+	}
+}
+
+// writeJSON writes entry to the writer as a line of JSON
+func (a *auditLog) writeJSON(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.writer.Write(line)
+	return err
+}
+
+// insert adds entry to the audit table
+func (a *auditLog) insert(ctx context.Context, entry AuditEntry) error {
+	sql := fmt.Sprintf(`INSERT INTO %s (time, operation, table_name, statement, input_rows, rows, identity, duration, success, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`, quoteTable(a.table))
+
+	_, err := a.d.Pool().Exec(ctx, sql,
+		entry.Time, entry.Operation, nullString(entry.Table), nullString(entry.Statement),
+		entry.InputRows, entry.Rows, nullString(entry.Identity), entry.Duration, entry.Success, nullString(entry.Error))
+	return err
+}
+
+// nullString returns s, or nil for NULL when s is empty
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
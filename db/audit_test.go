@@ -0,0 +1,97 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePathsAreAudited(t *testing.T) {
+	var log bytes.Buffer
+	if err := InitDB(lazyConfig(), WithAuditWriter(&log)); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		Close()
+		Pool = nil
+		std.Store(nil)
+	})
+
+	rows := []map[string]interface{}{{"id": 1}}
+	tests := []struct {
+		name      string
+		operation string
+		table     string
+		statement string
+		call      func(context.Context) error
+	}{
+		{"CopyInsert", OpBulk, "trades", "", func(ctx context.Context) error {
+			_, err := CopyInsert(ctx, rows, "trades")
+			return err
+		}},
+		{"DeleteBulk", OpBulk, "trades", "", func(ctx context.Context) error {
+			_, err := DeleteBulk(ctx, "trades", []string{"id"}, rows, time.Second)
+			return err
+		}},
+		{"SoftDeleteBulk", OpBulk, "trades", "", func(ctx context.Context) error {
+			_, err := SoftDeleteBulk(ctx, "trades", []string{"id"}, rows, "deleted_at", time.Second)
+			return err
+		}},
+		{"InsertBulkMulti", OpBulk, "trades,quotes", "", func(ctx context.Context) error {
+			return InsertBulkMulti(ctx, []TableBatch{{Table: "trades", Rows: rows}, {Table: "quotes", Rows: rows}}, time.Second)
+		}},
+		{"InsertBulkChannel", OpBulk, "trades", "", func(ctx context.Context) error {
+			ch := make(chan map[string]interface{}, 1)
+			ch <- rows[0]
+			close(ch)
+			_, err := InsertBulkChannel(ctx, ch, "trades", []string{"id"}, time.Second)
+			return err
+		}},
+		{"IngestStream", OpBulk, "trades", "", func(ctx context.Context) error {
+			decode := func(b []byte) (map[string]interface{}, error) {
+				var row map[string]interface{}
+				return row, json.Unmarshal(b, &row)
+			}
+			_, err := IngestStream(ctx, strings.NewReader(`{"id": 1}`+"\n"), decode, "trades", []string{"id"}, time.Second)
+			return err
+		}},
+		{"InsertBulkFromSource", OpBulk, "trades", "", func(ctx context.Context) error {
+			_, err := InsertBulkFromSource(ctx, newMapCopyFromSource(rows, []string{"id"}), []string{"id"}, "trades", []string{"id"}, time.Second)
+			return err
+		}},
+		{"ImportCSV", OpBulk, "trades", "", func(ctx context.Context) error {
+			_, err := ImportCSV(ctx, "trades", strings.NewReader("1\n"), CSVOptions{Columns: []string{"id"}})
+			return err
+		}},
+		{"RunBatch", OpExec, "", "SELECT 1;\nSELECT 2", func(ctx context.Context) error {
+			_, err := RunBatch(ctx, []Statement{{SQL: "SELECT 1"}, {SQL: "SELECT 2"}})
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log.Reset()
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// Nothing listens on the configured port, so every call fails
+			if err := tt.call(ctx); err == nil {
+				t.Fatal("call succeeded without a server")
+			}
+
+			var entry AuditEntry
+			if err := json.Unmarshal(log.Bytes(), &entry); err != nil {
+				t.Fatalf("audit log %q: %v", log.String(), err)
+			}
+			if entry.Operation != tt.operation || entry.Table != tt.table || entry.Statement != tt.statement {
+				t.Errorf("entry = %+v, want operation %q, table %q and statement %q", entry, tt.operation, tt.table, tt.statement)
+			}
+			if entry.Success || entry.Error == "" {
+				t.Errorf("entry = %+v, want a failure", entry)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Statement is one statement of a batch sent by RunBatch
+type Statement struct {
+	SQL  string
+	Args []interface{}
+}
+
+// Result is the outcome of one Statement of a batch. Rows holds the rows the
+// statement returned, if any, converted like FetchDataFromTable.
+type Result struct {
+	RowsAffected int64
+	Rows         []map[string]interface{}
+	Err          error
+}
+
+// RunBatch sends stmts to the server in a single round trip on the
+// package-level Pool; see DB.RunBatch
+func RunBatch(ctx context.Context, stmts []Statement, opts ...QueryOption) ([]Result, error) {
+	return defaultDB().RunBatch(ctx, stmts, opts...)
+}
+
+// RunBatch queues stmts into a pgx.Batch and sends them in a single round
+// trip. It returns one Result per statement, in order, and the first
+// statement error, if any. The server runs a batch as one implicit
+// transaction, so once a statement fails the ones after it fail too and
+// nothing is committed.
+func (d *DB) RunBatch(ctx context.Context, stmts []Statement, opts ...QueryOption) ([]Result, error) {
+	for _, stmt := range stmts {
+		if err := d.checkSQL(stmt.SQL); err != nil {
+			return nil, err
+		}
+	}
+
+	sqls := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		sqls[i] = stmt.SQL
+	}
+
+	// The batch is recorded as one exec of all of its statements
+	call, _ := d.startCall(ctx, OpExec, strings.Join(sqls, ";\n"), optionArgs(nil, opts))
+	defer call.done()
+
+	results, err := d.runBatch(call.ctx, call, stmts)
+	affected := 0
+	for _, result := range results {
+		affected += int(result.RowsAffected)
+	}
+	call.finish(affected, err)
+	return results, err
+}
+
+// runBatch sends the batch of RunBatch for call
+func (d *DB) runBatch(ctx context.Context, call *queryCall, stmts []Statement) ([]Result, error) {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, call.wrapErr(err)
+	}
+	defer release()
+
+	batch := &pgx.Batch{}
+	for _, stmt := range stmts {
+		batch.Queue(d.tagSQL(ctx, stmt.SQL), stmt.Args...)
+	}
+
+	br := conn.SendBatch(ctx, batch)
+	defer br.Close()
+
+	results := make([]Result, len(stmts))
+	var firstErr error
+
+	for i := range stmts {
+		results[i] = readBatchResult(br, decodeOptions{zone: call.options.decode.zone})
+		results[i].Err = call.wrapErr(results[i].Err)
+		if firstErr == nil && results[i].Err != nil {
+			firstErr = results[i].Err
+		}
+	}
+
+	if err := br.Close(); err != nil && firstErr == nil {
+		firstErr = call.wrapErr(err)
+	}
+
+	return results, firstErr
+}
+
+// readBatchResult reads the result of the next statement of br
+func readBatchResult(br pgx.BatchResults, decode decodeOptions) Result {
+	rows, err := br.Query()
+	if err != nil {
+		return Result{Err: err}
+	}
+	defer rows.Close()
+
+	var result Result
+	columns := columnNames(rows)
+
+	for rows.Next() {
+		entry, err := scanRowMap(rows, columns, decode)
+		if err != nil {
+			return Result{Rows: result.Rows, Err: err}
+		}
+		result.Rows = append(result.Rows, entry)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+
+	result.RowsAffected = rows.CommandTag().RowsAffected()
+	return result
+}
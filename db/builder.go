@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// SelectBuilder builds a simple parameterized SELECT statement:
+//
+//	rows, err := db.Table("trades").
+//		Select("price", "time").
+//		Where("symbol = ?", symbol).
+//		OrderBy("time DESC").
+//		Limit(100).
+//		Fetch(ctx)
+//
+// Each ? in a Where condition becomes a $n placeholder bound to the matching
+// argument; write ?? for a literal question mark. Table, column and ORDER BY
+// expressions are inserted as given, so they must not come from user input.
+type SelectBuilder struct {
+	db      *DB
+	table   string
+	columns []string
+	where   []string
+	args    []interface{}
+	orderBy []string
+	limit   int
+	offset  int
+	opts    []QueryOption
+}
+
+// Table starts a SELECT on table, executed on the package-level Pool
+func Table(table string) *SelectBuilder {
+	return &SelectBuilder{table: table}
+}
+
+// Table starts a SELECT on table, executed on the pool of d
+func (d *DB) Table(table string) *SelectBuilder {
+	return &SelectBuilder{db: d, table: table}
+}
+
+// Select sets the selected columns; without it every column is selected
+func (b *SelectBuilder) Select(columns ...string) *SelectBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// Where adds a condition; several conditions are combined with AND
+func (b *SelectBuilder) Where(condition string, args ...interface{}) *SelectBuilder {
+	b.where = append(b.where, b.bindPlaceholders(condition, args))
+	return b
+}
+
+// OrderBy adds ORDER BY expressions such as "time DESC"
+func (b *SelectBuilder) OrderBy(expressions ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, expressions...)
+	return b
+}
+
+// Limit caps the number of rows returned; n <= 0 means no limit
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset skips the first n rows
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+// Options sets QueryOptions, such as WithTimeout, for running the statement
+func (b *SelectBuilder) Options(opts ...QueryOption) *SelectBuilder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// ToSQL returns the generated statement and its arguments
+func (b *SelectBuilder) ToSQL() (string, []interface{}) {
+	var sql strings.Builder
+
+	sql.WriteString("SELECT ")
+	if len(b.columns) == 0 {
+		sql.WriteString("*")
+	} else {
+		sql.WriteString(strings.Join(b.columns, ", "))
+	}
+	sql.WriteString(" FROM ")
+	sql.WriteString(b.table)
+
+	if len(b.where) > 0 {
+		sql.WriteString(" WHERE (")
+		sql.WriteString(strings.Join(b.where, ") AND ("))
+		sql.WriteString(")")
+	}
+	if len(b.orderBy) > 0 {
+		sql.WriteString(" ORDER BY ")
+		sql.WriteString(strings.Join(b.orderBy, ", "))
+	}
+	if b.limit > 0 {
+		sql.WriteString(" LIMIT ")
+		sql.WriteString(strconv.Itoa(b.limit))
+	}
+	if b.offset > 0 {
+		sql.WriteString(" OFFSET ")
+		sql.WriteString(strconv.Itoa(b.offset))
+	}
+
+	return sql.String(), b.args
+}
+
+// Fetch runs the statement and returns every row as a map
+func (b *SelectBuilder) Fetch(ctx context.Context) ([]map[string]interface{}, error) {
+	sql, args := b.ToSQL()
+	return b.target().FetchDataFromTable(ctx, sql, optionArgs(args, b.opts)...)
+}
+
+// FetchOne runs the statement and returns the first row, or ErrNoRows
+func (b *SelectBuilder) FetchOne(ctx context.Context) (map[string]interface{}, error) {
+	sql, args := b.ToSQL()
+	return b.target().FetchOne(ctx, sql, optionArgs(args, b.opts)...)
+}
+
+// target returns the DB the statement runs on
+func (b *SelectBuilder) target() *DB {
+	if b.db != nil {
+		return b.db
+	}
+	return defaultDB()
+}
+
+// bindPlaceholders replaces each ? outside quotes in condition with the next
+// $n placeholder and records the matching argument
+func (b *SelectBuilder) bindPlaceholders(condition string, args []interface{}) string {
+	var out strings.Builder
+	next := 0
+
+	for i := 0; i < len(condition); {
+		c := condition[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := skipQuoted(condition, i, c)
+			out.WriteString(condition[i:end])
+			i = end
+		case c == '?' && strings.HasPrefix(condition[i:], "??"):
+			out.WriteByte('?')
+			i += 2
+		case c == '?' && next < len(args):
+			b.args = append(b.args, args[next])
+			next++
+			out.WriteString("$" + strconv.Itoa(len(b.args)))
+			i++
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// BulkResult reports what a bulk load did. Only committed chunks are counted.
+type BulkResult struct {
+	RowsStaged    int64 // Rows copied into the staging table
+	RowsInserted  int64 // Rows of the target table that were new
+	RowsUpdated   int64 // Existing rows of the target table that were overwritten
+	CopyDuration  time.Duration
+	MergeDuration time.Duration
+}
+
+// InsertBulkDataResult is InsertBulkData on the package-level Pool that also
+// reports what the load did; see DB.InsertBulkDataResult
+func InsertBulkDataResult(ctx context.Context, data []map[string]interface{}, table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) (*BulkResult, error) {
+	return defaultDB().InsertBulkDataResult(ctx, data, table, primaryKey, timeout, opts...)
+}
+
+// InsertBulkDataResult is InsertBulkData returning a BulkResult, so callers
+// can alert on anomalies such as a load that inserted nothing. Inserted and
+// updated rows are told apart by the xmax of the merged rows, which costs one
+// aggregate over the merge per chunk. On error the result covers the chunks
+// committed before it.
+func (d *DB) InsertBulkDataResult(ctx context.Context, data []map[string]interface{}, table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) (*BulkResult, error) {
+	result := &BulkResult{}
+	collect := func(o *bulkOptions) {
+		o.result = &resultCollector{total: result}
+	}
+
+	err := d.InsertBulkData(ctx, data, table, primaryKey, timeout, append(opts[:len(opts):len(opts)], collect)...)
+	return result, err
+}
+
+// resultCollector adds the counts of each committed transaction to total.
+// Its methods do nothing on a nil collector.
+type resultCollector struct {
+	total *BulkResult
+	tx    BulkResult // Counts of the transaction in progress
+}
+
+// begin starts counting a new transaction
+func (c *resultCollector) begin() {
+	if c != nil {
+		c.tx = BulkResult{}
+	}
+}
+
+// copied records a COPY of rows rows that took elapsed
+func (c *resultCollector) copied(rows int64, elapsed time.Duration) {
+	if c != nil {
+		c.tx.RowsStaged += rows
+		c.tx.CopyDuration += elapsed
+	}
+}
+
+// commit adds the counts of the committed transaction to the total
+func (c *resultCollector) commit() {
+	if c != nil {
+		c.total.RowsStaged += c.tx.RowsStaged
+		c.total.RowsInserted += c.tx.RowsInserted
+		c.total.RowsUpdated += c.tx.RowsUpdated
+		c.total.CopyDuration += c.tx.CopyDuration
+		c.total.MergeDuration += c.tx.MergeDuration
+	}
+}
+
+// runMerge executes the statements merging tempTable into table in tx, after
+// creating any missing partitions, counting the inserted and updated rows
+// when a result is collected
+func (d *DB) runMerge(ctx context.Context, tx pgx.Tx, table, tempTable string, columns []string, primaryKey []string, options *bulkOptions) error {
+	if options.partitions != 0 {
+		if err := d.ensurePartitions(ctx, tx, table, tempTable, options.partitions); err != nil {
+			return err
+		}
+	}
+
+	version := serverMajorVersion(tx.Conn().PgConn())
+	statements, err := buildMergeStatements(table, tempTable, columns, primaryKey, options, version)
+	if err != nil {
+		return err
+	}
+
+	for i, sql := range statements {
+		if i == 0 && options.result != nil {
+			err = d.runCountedMerge(ctx, tx, sql, version, options)
+		} else {
+			_, err = tx.Exec(ctx, d.tagSQL(ctx, sql))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runCountedMerge executes merge in tx and adds its inserted and updated rows
+// to the result. MERGE only reports them from PostgreSQL 17 on; before that
+// only its duration is recorded.
+func (d *DB) runCountedMerge(ctx context.Context, tx pgx.Tx, merge string, serverVersion int, options *bulkOptions) error {
+	start := time.Now()
+
+	var counted string
+	switch {
+	case !strings.HasPrefix(merge, "MERGE"):
+		// xmax is 0 for rows the statement inserted and set for rows it updated
+		counted = "WITH merged AS (" + merge + " RETURNING (xmax = 0) AS inserted) " +
+			"SELECT count(*) FILTER (WHERE inserted), count(*) FILTER (WHERE NOT inserted) FROM merged"
+	case serverVersion >= 17:
+		counted = "WITH merged AS (" + merge + " RETURNING merge_action() AS action) " +
+			"SELECT count(*) FILTER (WHERE action = 'INSERT'), count(*) FILTER (WHERE action = 'UPDATE') FROM merged"
+	default:
+		if _, err := tx.Exec(ctx, d.tagSQL(ctx, merge)); err != nil {
+			return err
+		}
+		options.result.tx.MergeDuration += time.Since(start)
+		return nil
+	}
+
+	var inserted, updated int64
+	if err := tx.QueryRow(ctx, d.tagSQL(ctx, counted)).Scan(&inserted, &updated); err != nil {
+		return err
+	}
+
+	options.result.tx.RowsInserted += inserted
+	options.result.tx.RowsUpdated += updated
+	options.result.tx.MergeDuration += time.Since(start)
+	return nil
+}
@@ -0,0 +1,243 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// InsertBulkStructs upserts rows into table on the package-level Pool; see InsertBulkStructsWith
+func InsertBulkStructs[T any](ctx context.Context, rows []T, table string, timeout time.Duration, opts ...BulkOption) error {
+	return InsertBulkStructsWith(ctx, defaultDB(), rows, table, timeout, opts...)
+}
+
+// InsertBulkStructsWith upserts rows into table on the pool of d with the same
+// temporary table, COPY and ON CONFLICT steps as InsertBulkData, reading the
+// values straight from the struct fields instead of going through maps.
+//
+// T must be a struct. Its fields map to columns like in Fetch and are copied
+// in declaration order; the fields tagged with the pk option, e.g.
+// `db:"id,pk"`, form the conflict target. Nil pointers are written as NULL
+// and time.Time fields follow the timestamp options; every other value is
+// converted by pgx to the type of its column, or as described at
+// WithColumnTypeDetection when it is set. WithParallelCopy is ignored.
+func InsertBulkStructsWith[T any](ctx context.Context, d *DB, rows []T, table string, timeout time.Duration, opts ...BulkOption) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	call, _ := d.startCall(ctx, OpBulk, "", nil)
+	defer call.done()
+	call.setTable(table, len(rows))
+
+	copied, err := insertBulkStructs(call.ctx, d, rows, table, timeout, opts)
+	call.finish(copied, err)
+	return err
+}
+
+// insertBulkStructs runs InsertBulkStructsWith and returns the number of rows loaded
+func insertBulkStructs[T any](ctx context.Context, d *DB, rows []T, table string, timeout time.Duration, opts []BulkOption) (int, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	columns, indexes, primaryKey, err := bulkStructColumns(t)
+	if err != nil {
+		return 0, err
+	}
+
+	options := &bulkOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if len(primaryKey) == 0 && options.conflictAction != DoNothing && options.conflictOn == "" {
+		return 0, fmt.Errorf("db: %s has no field tagged pk to upsert on", t)
+	}
+
+	if err := d.prepareBulk(ctx, table, columns, options); err != nil {
+		return 0, err
+	}
+	if err := options.checkDeleteMissing(len(rows)); err != nil {
+		return 0, err
+	}
+
+	timeTypes := make([]string, len(columns))
+	var pkIndexes [][]int
+	for i, col := range columns {
+		timeTypes[i] = options.timeColumns[col]
+		if contains(primaryKey, col) {
+			pkIndexes = append(pkIndexes, indexes[i])
+		}
+	}
+
+	copied := 0
+	err = insertChunks(ctx, len(rows), options, func(start, end int) error {
+		// Create a new context with timeout
+		ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		chunk := rows[start:end]
+		keep, err := dedupIndexes(len(chunk), start, options.dedupFor(primaryKey), func(i int) []interface{} {
+			row := reflect.ValueOf(&chunk[i]).Elem()
+			key := make([]interface{}, len(pkIndexes))
+			for j, index := range pkIndexes {
+				key[j] = row.FieldByIndex(index).Interface()
+			}
+			return key
+		})
+		if err != nil {
+			return err
+		}
+		if keep != nil {
+			deduped := make([]T, len(keep))
+			for i, index := range keep {
+				deduped[i] = chunk[index]
+			}
+			chunk = deduped
+		}
+
+		src := &structCopyFromSource[T]{rows: chunk, offset: start, keep: keep, columns: columns, indexes: indexes, timeTypes: timeTypes, options: options}
+		_, err = d.mergeInTx(ctxWithTimeout, table, columns, primaryKey, src, options)
+		if err == nil {
+			copied += len(chunk)
+		}
+		return err
+	})
+	return copied, err
+}
+
+// bulkStructColumns returns the columns of struct type t in field declaration
+// order, the index of the field behind each one and the columns tagged pk
+func bulkStructColumns(t reflect.Type) ([]string, [][]int, []string, error) {
+	fields, err := structFields(t)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	columns := make([]string, 0, len(fields))
+	for name := range fields {
+		columns = append(columns, name)
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		return indexBefore(fields[columns[i]], fields[columns[j]])
+	})
+
+	indexes := make([][]int, len(columns))
+	var primaryKey []string
+	for i, col := range columns {
+		indexes[i] = fields[col]
+
+		_, options, _ := strings.Cut(t.FieldByIndex(indexes[i]).Tag.Get("db"), ",")
+		for _, option := range strings.Split(options, ",") {
+			if strings.TrimSpace(option) == "pk" {
+				primaryKey = append(primaryKey, col)
+			}
+		}
+	}
+
+	if len(columns) == 0 {
+		return nil, nil, nil, fmt.Errorf("db: %s has no fields to insert", t)
+	}
+
+	return columns, indexes, primaryKey, nil
+}
+
+// indexBefore reports whether the field at index a is declared before the one at b
+func indexBefore(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// structCopyFromSource is an implementation of pgx.CopyFromSource over a slice of structs
+type structCopyFromSource[T any] struct {
+	rows      []T
+	pos       int
+	offset    int   // Index of the chunk in the input
+	keep      []int // Index in the chunk of each row after deduplication, nil when none was dropped
+	columns   []string
+	indexes   [][]int  // Field index of each column
+	timeTypes []string // "timestamp" or "date" for the time columns without time zone
+	options   *bulkOptions
+}
+
+// Next implements the pgx.CopyFromSource interface
+func (s *structCopyFromSource[T]) Next() bool {
+	if s.pos >= len(s.rows) {
+		return false
+	}
+	s.pos++
+	return true
+}
+
+// Values implements the pgx.CopyFromSource interface
+func (s *structCopyFromSource[T]) Values() ([]interface{}, error) {
+	row := reflect.ValueOf(&s.rows[s.pos-1]).Elem()
+
+	values := make([]interface{}, len(s.indexes))
+	for i, index := range s.indexes {
+		field := row.FieldByIndex(index)
+		if rule, ok := s.options.rounding[s.columns[i]]; ok {
+			field = reflect.ValueOf(roundValue(field.Interface(), rule))
+		}
+		if s.options.schema == nil {
+			if typ, ok := s.options.columnType(s.columns[i], field.Interface()); ok {
+				value, err := coerceValue(field.Interface(), typ)
+				if err != nil {
+					return nil, &ColumnTypeError{Row: s.rowIndex(), Column: s.columns[i], Type: typ, Value: field.Interface(), Err: err}
+				}
+				values[i] = value
+				continue
+			}
+			values[i] = structCopyValue(field, s.timeTypes[i])
+			continue
+		}
+
+		col := s.columns[i]
+		value, err := coerceColumn(field.Interface(), col, s.options)
+		if err != nil {
+			return nil, &ColumnTypeError{Row: s.rowIndex(), Column: col, Type: s.options.schema.types[col], Value: field.Interface(), Err: err}
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// rowIndex returns the index in the input of the current row
+func (s *structCopyFromSource[T]) rowIndex() int {
+	if s.keep != nil {
+		return s.offset + s.keep[s.pos-1]
+	}
+	return s.offset + s.pos - 1
+}
+
+// Err implements the pgx.CopyFromSource interface
+func (s *structCopyFromSource[T]) Err() error {
+	return nil
+}
+
+// structCopyValue returns the value to COPY for a struct field
+func structCopyValue(field reflect.Value, timeType string) interface{} {
+	for field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+
+	switch value := field.Interface().(type) {
+	case time.Time:
+		return timeValue(value, timeType)
+	case net.IP:
+		// An IPv4 address must not be sent in its 16 byte form
+		return inetValue(value)
+	default:
+		return value
+	}
+}
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrBulkWriterClosed is returned by a BulkWriter after Close
+var ErrBulkWriterClosed = errors.New("db: bulk writer is closed")
+
+// BulkWriter upserts rows into one table over many merge cycles. It pins a
+// pooled connection and creates its staging table once, instead of creating
+// a temporary table per call like InsertBulkData, which pays off for loops
+// that load many small batches into the same table. A BulkWriter is not safe
+// for concurrent use.
+type BulkWriter struct {
+	d          *DB
+	conn       *pgxpool.Conn
+	table      string
+	primaryKey []string
+	staging    string
+	options    *bulkOptions
+	columns    []string // Taken from the first row written
+	pending    []map[string]interface{}
+	written    int // Rows flushed so far, the input index of pending[0]
+	closed     bool
+}
+
+// NewBulkWriter returns a BulkWriter for table on the package-level Pool; see DB.NewBulkWriter
+func NewBulkWriter(ctx context.Context, table string, primaryKey []string, opts ...BulkOption) (*BulkWriter, error) {
+	return defaultDB().NewBulkWriter(ctx, table, primaryKey, opts...)
+}
+
+// NewBulkWriter acquires a connection and creates the staging table of a new
+// BulkWriter for table. The options apply to every flush; with WithChunkSize
+// Write flushes on its own whenever that many rows are pending. The
+// connection is held until Close.
+func (d *DB) NewBulkWriter(ctx context.Context, table string, primaryKey []string, opts ...BulkOption) (*BulkWriter, error) {
+	options := &bulkOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// Each flush only sees its own rows
+	if options.deleteMissing {
+		return nil, errors.New("db: BulkWriter does not support WithDeleteMissing")
+	}
+
+	conn, err := d.Pool().Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	staging := generateUniqueTempTableName(table)
+	_, err = conn.Exec(ctx, d.tagSQL(ctx, buildStagingTable(staging, table, options.stagingUnlogged, false, options.stagingTablespace)))
+	if err != nil {
+		conn.Release()
+		return nil, err
+	}
+
+	return &BulkWriter{
+		d:          d,
+		conn:       conn,
+		table:      table,
+		primaryKey: primaryKey,
+		staging:    staging,
+		options:    options,
+	}, nil
+}
+
+// Write adds rows to the next flush
+func (w *BulkWriter) Write(ctx context.Context, rows ...map[string]interface{}) error {
+	if w.closed {
+		return ErrBulkWriterClosed
+	}
+
+	w.pending = append(w.pending, rows...)
+	if w.options.chunkSize > 0 && len(w.pending) >= w.options.chunkSize {
+		_, err := w.Flush(ctx)
+		return err
+	}
+	return nil
+}
+
+// Flush merges the pending rows into the table in one transaction and
+// returns the number of rows copied. On error the pending rows are kept, so
+// the flush can be retried.
+func (w *BulkWriter) Flush(ctx context.Context) (int64, error) {
+	if w.closed {
+		return 0, ErrBulkWriterClosed
+	}
+	if len(w.pending) == 0 {
+		return 0, nil
+	}
+
+	return w.d.bulkCall(ctx, w.table, len(w.pending), w.flush)
+}
+
+// flush runs Flush for a non-empty batch of pending rows
+func (w *BulkWriter) flush(ctx context.Context) (int64, error) {
+	if w.columns == nil {
+		columns, err := w.d.bulkColumns(ctx, w.table, w.pending[0], w.options)
+		if err != nil {
+			return 0, err
+		}
+		if err := w.d.prepareBulk(ctx, w.table, columns, w.options); err != nil {
+			return 0, err
+		}
+		w.columns = columns
+	}
+
+	if err := validateRows(w.pending, w.columns, w.options.nullPolicy); err != nil {
+		return 0, err
+	}
+
+	// Convert the values before inserting
+	formatted, err := formatBulkRows(w.pending, w.written, w.columns, w.options)
+	if err != nil {
+		return 0, err
+	}
+
+	// Drop rows with a duplicate primary key
+	formatted, _, err = dedupMaps(w.pending, formatted, w.written, w.primaryKey, w.options.dedupFor(w.primaryKey))
+	if err != nil {
+		return 0, err
+	}
+
+	// Begin the transaction
+	tx, err := w.conn.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	copied, err := w.d.copyAndMerge(ctx, tx, w.table, w.staging, w.columns, w.primaryKey, newMapCopyFromSource(formatted, w.columns), w.options)
+	if err != nil {
+		return 0, err
+	}
+
+	// Empty the staging table for the next cycle
+	if _, err := tx.Exec(ctx, w.d.tagSQL(ctx, "TRUNCATE "+quoteIdent(w.staging))); err != nil {
+		return 0, err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	w.written += len(w.pending)
+	w.pending = w.pending[:0]
+	return copied, nil
+}
+
+// Close flushes the pending rows, drops the staging table and releases the
+// connection. When the flush fails the writer stays open with its rows
+// pending, so Close can be retried; Discard gives them up instead.
+func (w *BulkWriter) Close(ctx context.Context) error {
+	if w.closed {
+		return nil
+	}
+
+	if _, err := w.Flush(ctx); err != nil {
+		return err
+	}
+	return w.release(ctx)
+}
+
+// Discard drops the pending rows and the staging table and releases the
+// connection, for a writer whose rows cannot be flushed
+func (w *BulkWriter) Discard(ctx context.Context) error {
+	if w.closed {
+		return nil
+	}
+
+	w.pending = nil
+	return w.release(ctx)
+}
+
+// release drops the staging table, releases the connection and closes w
+func (w *BulkWriter) release(ctx context.Context) error {
+	w.closed = true
+
+	_, err := w.conn.Exec(ctx, w.d.tagSQL(ctx, "DROP TABLE IF EXISTS "+quoteIdent(w.staging)))
+	if err != nil {
+		// Temporary tables go away with their session, don't reuse it
+		w.conn.Conn().Close(ctx)
+	}
+	w.conn.Release()
+
+	return err
+}
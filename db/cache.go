@@ -0,0 +1,236 @@
+package db
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryCache is an in-memory read-through cache of FetchDataFromTable results,
+// keyed by the normalized SQL text and the arguments. Entries expire after
+// the cache's TTL, and the least recently used entry is evicted once the
+// cache holds maxEntries. Caching is opt-in per call:
+//
+//	cache := db.NewQueryCache(30*time.Second, 1000)
+//	rows, err := db.FetchDataFromTable(ctx, query, db.WithCache(cache), symbol)
+//
+// A QueryCache is safe for concurrent use and can be shared by several DBs;
+// the results of each DB, and of each set of query options, are kept apart.
+type QueryCache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // front is the most recently used
+	stats   CacheStats
+
+	// variants maps the query key of each cached query to the keys of its
+	// entries, so Invalidate finds them all
+	variants map[string]map[string]struct{}
+}
+
+// CacheStats holds the counters of a QueryCache
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64 // entries dropped to make room, not counting expiry
+	Entries   int
+}
+
+// cacheEntry is one cached result
+type cacheEntry struct {
+	key     string
+	query   string // queryKey of the entry
+	rows    []map[string]interface{}
+	expires time.Time
+}
+
+// NewQueryCache returns a QueryCache whose entries live for ttl. maxEntries
+// caps the number of cached results; 0 means no cap.
+func NewQueryCache(ttl time.Duration, maxEntries int) *QueryCache {
+	return &QueryCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		variants:   make(map[string]map[string]struct{}),
+	}
+}
+
+// WithCache serves the call from cache when it holds a fresh result for the
+// same query and arguments, and stores the result otherwise. It applies to
+// FetchData, FetchDataFromTable, FetchNamed and SelectBuilder.Fetch; other
+// calls ignore it.
+func WithCache(cache *QueryCache) QueryOption {
+	return func(o *queryOptions) {
+		o.cache = cache
+	}
+}
+
+// Stats returns the current counters
+func (c *QueryCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = len(c.entries)
+	return stats
+}
+
+// Invalidate drops the cached results of query with args, whichever DB and
+// query options they were fetched with. QueryOptions among args are ignored.
+func (c *QueryCache) Invalidate(query string, args ...interface{}) {
+	query = queryKey(query, withoutQueryOptions(args))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.variants[query] {
+		c.remove(c.entries[key])
+	}
+}
+
+// InvalidateAll drops every cached result
+func (c *QueryCache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.variants = make(map[string]map[string]struct{})
+	c.order.Init()
+}
+
+// get returns a copy of the fresh result cached under key
+func (c *QueryCache) get(key string) ([]map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		c.stats.Misses++
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	c.stats.Hits++
+	return copyRows(entry.rows), true
+}
+
+// put caches a copy of rows under key, a variant of query, evicting the
+// least recently used entries when the cache is full
+func (c *QueryCache) put(query, key string, rows []map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, query: query, rows: copyRows(rows), expires: time.Now().Add(c.ttl)}
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	if c.variants[query] == nil {
+		c.variants[query] = make(map[string]struct{})
+	}
+	c.variants[query][key] = struct{}{}
+
+	for c.maxEntries > 0 && len(c.entries) > c.maxEntries {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove drops elem; the caller holds mu
+func (c *QueryCache) remove(elem *list.Element) {
+	entry := elem.Value.(*cacheEntry)
+	c.order.Remove(elem)
+	delete(c.entries, entry.key)
+
+	delete(c.variants[entry.query], entry.key)
+	if len(c.variants[entry.query]) == 0 {
+		delete(c.variants, entry.query)
+	}
+}
+
+// queryKey identifies query with args. Runs of whitespace in the SQL are
+// collapsed so formatting differences share an entry.
+func queryKey(query string, args []interface{}) string {
+	var key strings.Builder
+	key.WriteString(strings.Join(strings.Fields(query), " "))
+	for _, arg := range args {
+		fmt.Fprintf(&key, "\x00%T:%#v", arg, arg)
+	}
+	return key.String()
+}
+
+// cacheKey returns the queryKey of query with args, the QueryOptions already
+// removed, and the key of its result fetched by d with decode. The key tells
+// apart the pools and the row transforms of DBs sharing a cache, and every
+// decode option that changes the rows.
+func (d *DB) cacheKey(query string, args []interface{}, decode decodeOptions) (string, string) {
+	d.mu.RLock()
+	pool, options := d.pool, d.poolOpts
+	d.mu.RUnlock()
+
+	base := queryKey(query, args)
+
+	var key strings.Builder
+	key.WriteString(base)
+	fmt.Fprintf(&key, "\x00db:%p/%p", pool, options)
+	if decode.keepNulls {
+		key.WriteString("\x00nulls")
+	}
+	if decode.raw {
+		key.WriteString("\x00raw")
+	}
+	if decode.json == JSONRaw {
+		key.WriteString("\x00rawjson")
+	}
+	if decode.numeric != NumericFloat {
+		fmt.Fprintf(&key, "\x00numeric%d", decode.numeric)
+	}
+	if decode.location != nil {
+		key.WriteString("\x00" + decode.location.String())
+	}
+	if decode.zone != nil {
+		key.WriteString("\x00zone:" + decode.zone.String())
+	}
+	if len(decode.rounding) > 0 {
+		key.WriteString("\x00rounding:" + roundingKey(decode.rounding))
+	}
+	if decode.bytes {
+		columns := make([]string, 0, len(decode.byteColumns))
+		for col := range decode.byteColumns {
+			columns = append(columns, col)
+		}
+		sort.Strings(columns)
+		key.WriteString("\x00bytes:" + strings.Join(columns, ","))
+	}
+	return base, key.String()
+}
+
+// copyRows returns a copy of rows whose maps can be modified independently
+func copyRows(rows []map[string]interface{}) []map[string]interface{} {
+	out := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		newRow := make(map[string]interface{}, len(row))
+		for col, value := range row {
+			newRow[col] = value
+		}
+		out[i] = newRow
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestQueryKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		argsA []interface{}
+		argsB []interface{}
+		same  bool
+	}{
+		{"whitespace", "SELECT *\n  FROM t", "SELECT * FROM t", nil, nil, true},
+		{"different SQL", "SELECT a FROM t", "SELECT b FROM t", nil, nil, false},
+		{"same args", "SELECT $1", "SELECT $1", []interface{}{1}, []interface{}{1}, true},
+		{"different args", "SELECT $1", "SELECT $1", []interface{}{1}, []interface{}{2}, false},
+		{"same value, different type", "SELECT $1", "SELECT $1", []interface{}{int32(1)}, []interface{}{int64(1)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			same := queryKey(tt.a, tt.argsA) == queryKey(tt.b, tt.argsB)
+			if same != tt.same {
+				t.Errorf("queryKey(%q, %v) == queryKey(%q, %v) is %v, want %v", tt.a, tt.argsA, tt.b, tt.argsB, same, tt.same)
+			}
+		})
+	}
+}
+
+func TestCacheKeyVariants(t *testing.T) {
+	d := &DB{pool: &pgxpool.Pool{}, poolOpts: &poolOptions{}}
+	query, args := "SELECT * FROM trades WHERE symbol = $1", []interface{}{"BTC"}
+	base, plain := d.cacheKey(query, args, decodeOptions{})
+
+	tests := []struct {
+		name   string
+		d      *DB
+		decode decodeOptions
+	}{
+		{"other pool", &DB{pool: &pgxpool.Pool{}, poolOpts: d.poolOpts}, decodeOptions{}},
+		{"other transforms", &DB{pool: d.pool, poolOpts: &poolOptions{}}, decodeOptions{}},
+		{"nulls", d, decodeOptions{keepNulls: true}},
+		{"raw", d, decodeOptions{raw: true}},
+		{"raw JSON", d, decodeOptions{json: JSONRaw}},
+		{"numeric", d, decodeOptions{numeric: NumericString}},
+		{"location", d, decodeOptions{location: time.FixedZone("X", 3600)}},
+		{"zone", d, decodeOptions{zone: time.FixedZone("X", 3600)}},
+		{"rounding", d, decodeOptions{rounding: map[string]Rounding{"price": {Scale: 2}}}},
+		{"bytes", d, decodeOptions{bytes: true}},
+		{"byte columns", d, decodeOptions{bytes: true, byteColumns: map[string]bool{"blob": true}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBase, key := tt.d.cacheKey(query, args, tt.decode)
+			if gotBase != base {
+				t.Errorf("query key = %q, want %q", gotBase, base)
+			}
+			if key == plain {
+				t.Errorf("key %q is the key of the plain fetch", key)
+			}
+		})
+	}
+
+	if _, again := d.cacheKey(query, args, decodeOptions{}); again != plain {
+		t.Errorf("the same fetch has keys %q and %q", plain, again)
+	}
+}
+
+func TestQueryCacheInvalidateDropsEveryVariant(t *testing.T) {
+	cache := NewQueryCache(time.Minute, 0)
+	d := &DB{pool: &pgxpool.Pool{}, poolOpts: &poolOptions{}}
+	other := &DB{pool: &pgxpool.Pool{}, poolOpts: &poolOptions{}}
+	query := "SELECT * FROM trades WHERE symbol = $1"
+	rows := []map[string]interface{}{{"symbol": "BTC"}}
+
+	for _, fetch := range []struct {
+		d      *DB
+		decode decodeOptions
+	}{
+		{d, decodeOptions{}},
+		{d, decodeOptions{keepNulls: true}},
+		{other, decodeOptions{raw: true}},
+	} {
+		base, key := fetch.d.cacheKey(query, []interface{}{"BTC"}, fetch.decode)
+		cache.put(base, key, rows)
+	}
+	base, key := d.cacheKey(query, []interface{}{"ETH"}, decodeOptions{})
+	cache.put(base, key, rows)
+
+	cache.Invalidate(query, WithNulls(), "BTC")
+	if entries := cache.Stats().Entries; entries != 1 {
+		t.Fatalf("%d entries after Invalidate, want only the ETH one", entries)
+	}
+	if _, ok := cache.get(key); !ok {
+		t.Error("Invalidate dropped the result of other arguments")
+	}
+
+	cache.Invalidate(query, "ETH")
+	if entries := cache.Stats().Entries; entries != 0 || len(cache.variants) != 0 {
+		t.Errorf("%d entries and %d variant sets left, want none", entries, len(cache.variants))
+	}
+}
+
+func TestQueryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewQueryCache(time.Minute, 2)
+	for _, key := range []string{"a", "b"} {
+		cache.put(key, key, nil)
+	}
+	cache.get("a")
+	cache.put("c", "c", nil)
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("b is still cached, want it evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	if stats := cache.Stats(); stats.Evictions != 1 || len(cache.variants) != 2 {
+		t.Errorf("Evictions = %d and %d variant sets, want 1 and 2", stats.Evictions, len(cache.variants))
+	}
+}
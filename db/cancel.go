@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrQueryCanceled is returned, wrapping the driver error, by a call whose
+// QueryHandle was canceled
+var ErrQueryCanceled = errors.New("db: query canceled")
+
+// QueryHandle lets another goroutine cancel the statement of a fetch or exec
+// call on the server, e.g. for a "stop query" button:
+//
+//	var h db.QueryHandle
+//	go func() {
+//		<-stop
+//		h.Cancel(context.Background())
+//	}()
+//	n, err := db.Exec(ctx, "UPDATE trades SET ...", db.WithQueryHandle(&h))
+//
+// The zero value is ready to use. A QueryHandle is meant for a single call.
+type QueryHandle struct {
+	mu       sync.Mutex
+	conn     *pgconn.PgConn
+	canceled bool
+}
+
+// queryHandleKey is the context key of the QueryHandle of a call
+type queryHandleKey struct{}
+
+// WithQueryHandle attaches h to the call so that h.Cancel cancels it
+func WithQueryHandle(h *QueryHandle) QueryOption {
+	return func(o *queryOptions) {
+		o.handle = h
+	}
+}
+
+// Cancel asks the server, over a separate connection, to cancel the statement
+// the call is running. A call that has not reached the server yet fails with
+// ErrQueryCanceled as soon as it acquires its connection. Canceling a call
+// that already finished does nothing.
+func (h *QueryHandle) Cancel(ctx context.Context) error {
+	// Holding the lock keeps the connection from being released, and reused
+	// by another query, while the cancel request is on its way
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.canceled = true
+	if h.conn == nil {
+		return nil
+	}
+	return h.conn.CancelRequest(ctx)
+}
+
+// Canceled reports whether Cancel was called
+func (h *QueryHandle) Canceled() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.canceled
+}
+
+// attach records conn as the connection running the call
+func (h *QueryHandle) attach(conn *pgconn.PgConn) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.canceled {
+		return ErrQueryCanceled
+	}
+	h.conn = conn
+	return nil
+}
+
+// detach forgets the connection before it goes back to the pool
+func (h *QueryHandle) detach() {
+	h.mu.Lock()
+	h.conn = nil
+	h.mu.Unlock()
+}
+
+// acquire acquires a connection from the pool of d and attaches it to the
+// QueryHandle of ctx, if any. The returned release func detaches and
+// releases the connection.
+func (d *DB) acquire(ctx context.Context) (*pgxpool.Conn, func(), error) {
+	if d.metrics != nil {
+		d.metrics.waiting.Add(1)
+		defer d.metrics.waiting.Add(-1)
+	}
+
+	start := time.Now()
+	conn, err := d.Pool().Acquire(ctx)
+	addPoolWait(ctx, start)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h, _ := ctx.Value(queryHandleKey{}).(*QueryHandle)
+	if h == nil {
+		return conn, conn.Release, nil
+	}
+
+	if err := h.attach(conn.Conn().PgConn()); err != nil {
+		conn.Release()
+		return nil, nil, err
+	}
+
+	return conn, func() {
+		h.detach()
+		conn.Release()
+	}, nil
+}
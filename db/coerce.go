@@ -0,0 +1,495 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/shopspring/decimal"
+)
+
+// timeLayouts are the layouts tried, in order, when a string is written to a
+// timestamp or date column
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// copyConnInfo resolves type names to the pgtype values that encode them for
+// COPY, which uses the binary format and writes plain strings as they are
+var copyConnInfo = pgtype.NewConnInfo()
+
+// ErrNullValue is reported by ColumnTypeError for a NULL in a NOT NULL column
+var ErrNullValue = errors.New("null value in a NOT NULL column")
+
+// ColumnTypeError is returned by the bulk functions when a value cannot be
+// converted to the type of its column, as detected by WithColumnTypeDetection
+// or declared with WithColumnTypes, or guessed from its Go type. Batch
+// loads report it before their chunk is copied; streaming loads abort the COPY.
+type ColumnTypeError struct {
+	Row    int    // Index of the row in the input
+	Column string // Column of the value
+	Type   string // Type of the column, e.g. int8
+	Value  interface{}
+	Err    error
+}
+
+// Error implements the error interface
+func (e *ColumnTypeError) Error() string {
+	return fmt.Sprintf("db: row %d, column %s (%s): cannot use %T %v: %v", e.Row, e.Column, e.Type, e.Value, e.Value, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ColumnTypeError) Unwrap() error {
+	return e.Err
+}
+
+// columnSchema is what the bulk functions know about the columns of a table
+type columnSchema struct {
+	types    map[string]string // Type name of each column
+	notNull  map[string]bool   // Columns declared NOT NULL
+	defaults map[string]string // Default expression of the columns that have one
+}
+
+// columnSchema looks up the columns of table. Domains are reported as their base type.
+func (d *DB) columnSchema(ctx context.Context, table string) (*columnSchema, error) {
+	rows, err := d.Pool().Query(ctx, `SELECT a.attname, bt.typname, a.attnotnull, coalesce(pg_get_expr(ad.adbin, ad.adrelid), '')
+		FROM pg_attribute a
+		JOIN pg_type t ON t.oid = a.atttypid
+		JOIN pg_type bt ON bt.oid = CASE WHEN t.typtype = 'd' THEN t.typbasetype ELSE t.oid END
+		LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+		WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped`, quoteTable(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schema := &columnSchema{
+		types:    make(map[string]string),
+		notNull:  make(map[string]bool),
+		defaults: make(map[string]string),
+	}
+	for rows.Next() {
+		var name, typ, def string
+		var notNull bool
+		if err := rows.Scan(&name, &typ, &notNull, &def); err != nil {
+			return nil, err
+		}
+		schema.types[name] = typ
+		if notNull {
+			schema.notNull[name] = true
+		}
+		if def != "" {
+			schema.defaults[name] = def
+		}
+	}
+
+	return schema, rows.Err()
+}
+
+// nullAllowed reports whether a NULL can be loaded into col of schema, which
+// is the case when the column is nullable or NullDefault fills in its default
+func (s *columnSchema) nullAllowed(col string, policy NullPolicy) bool {
+	if !s.notNull[col] {
+		return true
+	}
+	_, hasDefault := s.defaults[col]
+	return policy == NullDefault && hasDefault
+}
+
+// formatBulkRows converts the values of data, whose first row is at index
+// offset of the input, for COPY. With detected column types the values are
+// coerced to them; otherwise the types are guessed from the Go values.
+func formatBulkRows(data []map[string]interface{}, offset int, columns []string, options *bulkOptions) ([]map[string]interface{}, error) {
+	if len(options.rounding) > 0 {
+		rounded := make([]map[string]interface{}, len(data))
+		for i, row := range data {
+			rounded[i] = options.roundRow(row)
+		}
+		data = rounded
+	}
+
+	if options.schema == nil {
+		typed := make([]map[string]interface{}, len(data))
+		for i, row := range data {
+			row, err := options.applyColumnTypes(offset+i, row, columns)
+			if err != nil {
+				return nil, err
+			}
+			typed[i] = row
+		}
+		data = formatTimestamps(typed, columns)
+		return formatToBinaryData(data, offset, columns, options.timeColumns)
+	}
+
+	newData := make([]map[string]interface{}, len(data))
+	for i, row := range data {
+		newRow, err := coerceRow(offset+i, row, columns, options)
+		if err != nil {
+			return nil, err
+		}
+		newData[i] = newRow
+	}
+	return newData, nil
+}
+
+// formatBulkRow is formatBulkRows for the single row at index i of the input
+func formatBulkRow(i int, row map[string]interface{}, columns []string, options *bulkOptions) (map[string]interface{}, error) {
+	row = options.roundRow(row)
+	if options.schema == nil {
+		row, err := options.applyColumnTypes(i, row, columns)
+		if err != nil {
+			return nil, err
+		}
+		row = formatRowTimestamps(row, columns)
+		return formatRowToBinary(i, row, columns, options.timeColumns)
+	}
+	return coerceRow(i, row, columns, options)
+}
+
+// coerceRow converts every value of row, at index i of the input, to the type of its column
+func coerceRow(i int, row map[string]interface{}, columns []string, options *bulkOptions) (map[string]interface{}, error) {
+	newRow := make(map[string]interface{}, len(columns))
+	for _, col := range columns {
+		value, err := coerceColumn(row[col], col, options)
+		if err != nil {
+			return nil, &ColumnTypeError{Row: i, Column: col, Type: options.schema.types[col], Value: row[col], Err: err}
+		}
+		newRow[col] = value
+	}
+	return newRow, nil
+}
+
+// coerceColumn converts value to the type of col and rejects a NULL that col does not allow
+func coerceColumn(value interface{}, col string, options *bulkOptions) (interface{}, error) {
+	value, err := coerceValue(value, options.schema.types[col])
+	if err != nil {
+		return nil, err
+	}
+	if value == nil && !options.schema.nullAllowed(col, options.nullPolicy) {
+		return nil, ErrNullValue
+	}
+	return value, nil
+}
+
+// coerceValue converts value to a value COPY can encode into a column of type
+// typ. Values of types it does not know about are left to pgx.
+func coerceValue(value interface{}, typ string) (interface{}, error) {
+	value, err := normalizeValue(value, typ)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return encodeAs(value, typ)
+}
+
+// normalizeValue converts value to the Go value that stands for it in a
+// column of type typ, parsing strings where needed
+func normalizeValue(value interface{}, typ string) (interface{}, error) {
+	// Nil pointers are NULL, others stand for the value they point to
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+		value = v.Interface()
+	}
+	if value == nil {
+		return nil, nil
+	}
+
+	switch typ {
+	case "int2":
+		return coerceInt(value, 16)
+	case "int4":
+		return coerceInt(value, 32)
+	case "int8":
+		return coerceInt(value, 64)
+	case "float4", "float8":
+		return coerceFloat(value)
+	case "numeric":
+		return coerceNumeric(value)
+	case "bool":
+		return coerceBool(value)
+	case "text", "varchar", "bpchar", "name", "citext":
+		return coerceText(value)
+	case "timestamptz", "timestamp", "date":
+		return coerceTime(value, typ)
+	case "uuid":
+		return coerceUUID(value)
+	case "tstzrange", "tsrange", "daterange", "int4range", "int8range", "numrange":
+		return coerceRange(value, typ)
+	case "inet", "cidr":
+		return coerceInet(value)
+	case "macaddr", "macaddr8":
+		return coerceMacaddr(value)
+	case "geometry", "geography":
+		return geometryValue(value, typ)
+	case "interval":
+		switch v := value.(type) {
+		case Interval:
+			return intervalValue(v), nil
+		case string:
+			// Text such as "1 day 02:00:00", as the server prints it
+			var interval pgtype.Interval
+			if err := interval.DecodeText(copyConnInfo, []byte(v)); err != nil {
+				return nil, err
+			}
+			return interval, nil
+		}
+	}
+
+	return value, nil
+}
+
+// encodeAs wraps value in the pgtype value of typ, so it is encoded as that
+// type. Values that encode themselves and types pgtype does not know, such as
+// enums, are returned as they are.
+func encodeAs(value interface{}, typ string) (interface{}, error) {
+	if _, ok := value.(pgtype.BinaryEncoder); ok {
+		return value, nil
+	}
+	dt, ok := copyConnInfo.DataTypeForName(typ)
+	if !ok {
+		return value, nil
+	}
+
+	encoded := pgtype.NewValue(dt.Value)
+	if err := encoded.Set(value); err != nil {
+		// pgx falls back to the driver.Valuer of the value
+		if _, ok := value.(driver.Valuer); ok {
+			return value, nil
+		}
+		return nil, err
+	}
+	return encoded, nil
+}
+
+// coerceInt converts value to an int64 that fits in bits bits
+func coerceInt(value interface{}, bits int) (interface{}, error) {
+	var n int64
+	switch v := value.(type) {
+	case int:
+		n = int64(v)
+	case int8:
+		n = int64(v)
+	case int16:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case uint8:
+		n = int64(v)
+	case uint16:
+		n = int64(v)
+	case uint32:
+		n = int64(v)
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return nil, fmt.Errorf("out of range")
+		}
+		n = int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("out of range")
+		}
+		n = int64(v)
+	case float32:
+		return coerceInt(float64(v), bits)
+	case float64:
+		if v != math.Trunc(v) || math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("not an integer")
+		}
+		if v < math.MinInt64 || v >= math.MaxInt64 {
+			return nil, fmt.Errorf("out of range")
+		}
+		n = int64(v)
+	case string:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("not an integer")
+		}
+		n = parsed
+	case json.Number:
+		return coerceInt(string(v), bits)
+	case decimal.Decimal:
+		if !v.IsInteger() {
+			return nil, fmt.Errorf("not an integer")
+		}
+		return coerceInt(v.String(), bits)
+	case bool, time.Time:
+		return nil, fmt.Errorf("not an integer")
+	default:
+		return value, nil
+	}
+
+	if bits < 64 && (n < -1<<(bits-1) || n > 1<<(bits-1)-1) {
+		return nil, fmt.Errorf("out of range")
+	}
+	return n, nil
+}
+
+// coerceFloat converts value to a float64
+func coerceFloat(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return reflect.ValueOf(v).Convert(reflect.TypeOf(float64(0))).Float(), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("not a number")
+		}
+		return f, nil
+	case json.Number:
+		return coerceFloat(string(v))
+	case decimal.Decimal:
+		return v.InexactFloat64(), nil
+	case bool, time.Time:
+		return nil, fmt.Errorf("not a number")
+	}
+	return value, nil
+}
+
+// coerceNumeric converts value to the text of a numeric, so no precision is lost
+func coerceNumeric(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		if math.IsNaN(v) {
+			return "NaN", nil
+		}
+		if math.IsInf(v, 0) {
+			return nil, fmt.Errorf("infinite")
+		}
+		return decimal.NewFromFloat(v).String(), nil
+	case float32:
+		return coerceNumeric(float64(v))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), nil
+	case string:
+		s := strings.TrimSpace(v)
+		if _, err := decimal.NewFromString(s); err != nil && s != "NaN" {
+			return nil, fmt.Errorf("not a number")
+		}
+		return s, nil
+	case json.Number:
+		return coerceNumeric(string(v))
+	case decimal.Decimal:
+		return v.String(), nil
+	case bool, time.Time:
+		return nil, fmt.Errorf("not a number")
+	}
+	return value, nil
+}
+
+// coerceBool converts value to a bool
+func coerceBool(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("not a boolean")
+		}
+		return b, nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		switch fmt.Sprint(v) {
+		case "0":
+			return false, nil
+		case "1":
+			return true, nil
+		}
+		return nil, fmt.Errorf("not a boolean")
+	case float32, float64, time.Time:
+		return nil, fmt.Errorf("not a boolean")
+	}
+	return value, nil
+}
+
+// coerceText converts value to a string
+func coerceText(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, bool, json.Number, decimal.Decimal:
+		return fmt.Sprint(v), nil
+	}
+	return value, nil
+}
+
+// coerceTime converts value to the pgtype value of a timestamptz, timestamp or date column
+func coerceTime(value interface{}, typ string) (interface{}, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case time.Time:
+		t = v
+	case string:
+		parsed, err := parseTime(strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		t = parsed
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
+		return nil, fmt.Errorf("not a time")
+	default:
+		return value, nil
+	}
+
+	switch typ {
+	case "timestamp":
+		return pgtype.Timestamp{Time: wallClockUTC(t), Status: pgtype.Present}, nil
+	case "date":
+		return pgtype.Date{Time: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), Status: pgtype.Present}, nil
+	}
+	return pgtype.Timestamptz{Time: t, Status: pgtype.Present}, nil
+}
+
+// parseTime parses s with the first of timeLayouts that matches
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("not a time")
+}
+
+// coerceUUID converts value to the bytes of a uuid
+func coerceUUID(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case uuid.UUID:
+		return [16]byte(v), nil
+	case string:
+		id, err := uuid.Parse(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("not a uuid")
+		}
+		return [16]byte(id), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/shopspring/decimal"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	id := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	at := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+	n := 7
+	var nilInt *int
+
+	tests := []struct {
+		name    string
+		typ     string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"nil pointer", "int4", nilInt, nil, false},
+		{"pointer", "int4", &n, int64(7), false},
+		{"int from string", "int8", " 42 ", int64(42), false},
+		{"int from json number", "int8", json.Number("42"), int64(42), false},
+		{"int from whole float", "int4", 3.0, int64(3), false},
+		{"int from fraction", "int4", 3.5, nil, true},
+		{"int2 overflow", "int2", 40000, nil, true},
+		{"uint64 overflow", "int8", uint64(math.MaxUint64), nil, true},
+		{"float from string", "float8", "2.5", 2.5, false},
+		{"float from bool", "float8", true, nil, true},
+		{"numeric from float", "numeric", 0.1, "0.1", false},
+		{"numeric from decimal", "numeric", decimal.RequireFromString("12.3400"), "12.34", false},
+		{"numeric NaN", "numeric", math.NaN(), "NaN", false},
+		{"numeric infinity", "numeric", math.Inf(1), nil, true},
+		{"numeric from text", "numeric", "abc", nil, true},
+		{"bool from string", "bool", "true", true, false},
+		{"bool from int", "bool", 1, true, false},
+		{"bool from other int", "bool", 2, nil, true},
+		{"text from int", "text", 12, "12", false},
+		{"text from float", "varchar", 1.25, "1.25", false},
+		{"text from bytes", "text", []byte("raw"), "raw", false},
+		{"timestamptz from string", "timestamptz", "2026-10-14T09:30:00Z", pgtype.Timestamptz{Time: at, Status: pgtype.Present}, false},
+		{"date from time", "date", at, pgtype.Date{Time: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), Status: pgtype.Present}, false},
+		{"time from int", "timestamptz", 5, nil, true},
+		{"uuid from string", "uuid", id.String(), [16]byte(id), false},
+		{"uuid from text", "uuid", "not-a-uuid", nil, true},
+		{"unknown type", "mood", "happy", "happy", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeValue(tt.value, tt.typ)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeValue(%v, %q) error = %v, want error %v", tt.value, tt.typ, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeValue(%v, %q) = %#v, want %#v", tt.value, tt.typ, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoerceValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		typ      string
+		value    interface{}
+		wantType interface{}
+		want     interface{}
+	}{
+		{"NULL", "int4", nil, nil, nil},
+		{"int4", "int4", "5", &pgtype.Int4{}, int32(5)},
+		{"int8", "int8", 5, &pgtype.Int8{}, int64(5)},
+		{"text", "text", 5, &pgtype.Text{}, "5"},
+		{"bool", "bool", "false", &pgtype.Bool{}, false},
+		{"self-encoding", "timestamptz", "2026-10-14T09:30:00Z", pgtype.Timestamptz{}, pgtype.Timestamptz{Time: time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC), Status: pgtype.Present}},
+		{"unknown type", "mood", "happy", "", "happy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := coerceValue(tt.value, tt.typ)
+			if err != nil {
+				t.Fatalf("coerceValue(%v, %q): %v", tt.value, tt.typ, err)
+			}
+			if reflect.TypeOf(got) != reflect.TypeOf(tt.wantType) {
+				t.Fatalf("coerceValue(%v, %q) = %T, want %T", tt.value, tt.typ, got, tt.wantType)
+			}
+			if v, ok := got.(pgtype.Value); ok && tt.want != nil {
+				if get := v.Get(); get != tt.want {
+					t.Errorf("coerceValue(%v, %q) holds %#v, want %#v", tt.value, tt.typ, get, tt.want)
+				}
+			} else if !ok && got != tt.want {
+				t.Errorf("coerceValue(%v, %q) = %#v, want %#v", tt.value, tt.typ, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := coerceValue("x", "int4"); err == nil {
+		t.Error("coerceValue(\"x\", int4) succeeded")
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+)
+
+// ResultSet is a query result in column-major order: Values[i] holds every
+// value of column Columns[i], one per row
+type ResultSet struct {
+	Columns []string
+	Values  [][]interface{}
+}
+
+// Len returns the number of rows in the result
+func (r *ResultSet) Len() int {
+	if len(r.Values) == 0 {
+		return 0
+	}
+	return len(r.Values[0])
+}
+
+// Column returns the values of the named column, or nil when there is no such column
+func (r *ResultSet) Column(name string) []interface{} {
+	for i, column := range r.Columns {
+		if column == name {
+			return r.Values[i]
+		}
+	}
+	return nil
+}
+
+// FetchColumns executes query on the package-level Pool and returns the result
+// column by column. For analytics over many rows it is much lighter than a
+// map per row. Values go through the same native-type conversion as
+// FetchDataFromTable; values that are not present are nil.
+func FetchColumns(ctx context.Context, query string, args ...interface{}) (*ResultSet, error) {
+	return defaultDB().FetchColumns(ctx, query, args...)
+}
+
+// FetchColumns executes query with args and returns the result column by column
+func (d *DB) FetchColumns(ctx context.Context, query string, args ...interface{}) (*ResultSet, error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, err
+	}
+
+	call, args := d.startCall(ctx, OpFetch, query, args)
+	defer call.done()
+
+	result, err := d.fetchColumns(call.ctx, query, args, call.options.decode)
+	rows := 0
+	if result != nil && len(result.Values) > 0 {
+		rows = len(result.Values[0])
+	}
+	call.finish(rows, err)
+	return result, call.wrapErr(err)
+}
+
+// fetchColumns runs the query of FetchColumns in a single pass over the rows
+func (d *DB) fetchColumns(ctx context.Context, query string, args []interface{}, decode decodeOptions) (*ResultSet, error) {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Execute the query
+	rows, err := conn.Query(ctx, d.tagSQL(ctx, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := columnNames(rows)
+	result := &ResultSet{Columns: columns, Values: make([][]interface{}, len(columns))}
+
+	// The scan buffer is reused for every row
+	columnData := make([]interface{}, len(columns))
+
+	for rows.Next() {
+		if err := scanValues(rows, columnData, decode); err != nil {
+			return nil, err
+		}
+
+		for i, val := range columnData {
+			native, _ := toNativeValue(scannedValue(rows, i, val, decode), decode)
+			result.Values[i] = append(result.Values[i], native)
+			columnData[i] = nil
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ColumnMismatchError is returned by the bulk functions when a row does not
+// have exactly the columns being loaded
+type ColumnMismatchError struct {
+	Row     int      // Index of the row in the input
+	Missing []string // Columns the row has no key for
+	Extra   []string // Keys of the row that are not loaded columns
+}
+
+// Error implements the error interface
+func (e *ColumnMismatchError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Extra) > 0 {
+		problems = append(problems, "unexpected "+strings.Join(e.Extra, ", "))
+	}
+	return fmt.Sprintf("db: row %d does not match the columns: %s", e.Row, strings.Join(problems, "; "))
+}
+
+// NullPolicy is how the bulk functions treat missing keys and nil values
+type NullPolicy int
+
+const (
+	// NullStrict requires every row to have every column; nil is NULL (the default)
+	NullStrict NullPolicy = iota
+	// NullMissing loads a missing key as NULL, like a nil value
+	NullMissing
+	// NullDefault loads a missing key or nil value as the column's default,
+	// or NULL when the column has none
+	NullDefault
+)
+
+// WithNullPolicy sets how missing keys and nil values are loaded. NullDefault
+// looks the columns up like WithColumnTypeDetection, which then also applies.
+// When the columns are looked up, a NULL headed for a NOT NULL column fails
+// with a *ColumnTypeError wrapping ErrNullValue before COPY starts.
+func WithNullPolicy(policy NullPolicy) BulkOption {
+	return func(o *bulkOptions) {
+		o.nullPolicy = policy
+	}
+}
+
+// WithColumns sets the columns to load and their order instead of taking them
+// from the keys of the first row. Every row must have exactly these keys.
+func WithColumns(columns ...string) BulkOption {
+	return func(o *bulkOptions) {
+		o.columns = append(o.columns, columns...)
+	}
+}
+
+// WithSchemaColumns orders the columns like the target table, looking its
+// columns up once per call. A key of the first row that is not a column of the
+// table is reported before anything is copied. Columns the rows leave out
+// keep their default.
+func WithSchemaColumns() BulkOption {
+	return func(o *bulkOptions) {
+		o.schemaColumns = true
+	}
+}
+
+// bulkColumns returns the columns to load into table, given the first row
+func (d *DB) bulkColumns(ctx context.Context, table string, first map[string]interface{}, options *bulkOptions) ([]string, error) {
+	if len(options.columns) > 0 {
+		return options.columns, nil
+	}
+	if !options.schemaColumns {
+		return getColumns([]map[string]interface{}{first}), nil
+	}
+
+	tableColumns, err := d.tableColumns(ctx, table)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up the columns of %s: %v", table, err)
+	}
+
+	columns := make([]string, 0, len(first))
+	for _, col := range tableColumns {
+		if _, ok := first[col]; ok {
+			columns = append(columns, col)
+		}
+	}
+
+	if len(columns) < len(first) {
+		var unknown []string
+		for key := range first {
+			if !contains(tableColumns, key) {
+				unknown = append(unknown, key)
+			}
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("db: %s has no column %s", table, strings.Join(unknown, ", "))
+	}
+
+	return columns, nil
+}
+
+// tableColumns returns the columns of table in their table order
+func (d *DB) tableColumns(ctx context.Context, table string) ([]string, error) {
+	rows, err := d.Pool().Query(ctx, `SELECT attname FROM pg_attribute
+		WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped ORDER BY attnum`, quoteTable(table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+
+	return columns, rows.Err()
+}
+
+// validateRows checks that every row of data has exactly columns as its keys,
+// or a subset of them when policy lets keys be missing
+func validateRows(data []map[string]interface{}, columns []string, policy NullPolicy) error {
+	for i, row := range data {
+		if err := validateRow(i, row, columns, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateRow is validateRows for the single row at index i of the input
+func validateRow(i int, row map[string]interface{}, columns []string, policy NullPolicy) error {
+	present := 0
+	var missing []string
+	for _, col := range columns {
+		if _, ok := row[col]; ok {
+			present++
+		} else {
+			missing = append(missing, col)
+		}
+	}
+	if policy != NullStrict {
+		missing = nil
+	}
+	if missing == nil && present == len(row) {
+		return nil
+	}
+
+	var extra []string
+	for key := range row {
+		if !contains(columns, key) {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+
+	return &ColumnMismatchError{Row: i, Missing: missing, Extra: extra}
+}
@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/shopspring/decimal"
+)
+
+// compositeStructs maps composite type names to the struct types registered
+// with RegisterComposite
+var compositeStructs sync.Map // map[string]reflect.Type
+
+// RegisterComposite makes the fetch functions return values of the composite
+// type typeName as a T, a struct whose exported fields match the attributes
+// of the type in order, instead of a map[string]interface{}. Register types
+// before the queries that return them.
+func RegisterComposite[T any](typeName string) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("db: cannot register %s for composite type %s, a struct type is required", t, typeName)
+	}
+	compositeStructs.Store(typeName, t)
+	return nil
+}
+
+// compositeRow is a decoded composite value before its fields are converted
+// to native types
+type compositeRow map[string]interface{}
+
+// registerComposites registers every standalone composite type of the
+// database with conn. Types whose attributes have types pgx cannot decode
+// are skipped.
+func registerComposites(ctx context.Context, conn *pgx.Conn) error {
+	rows, err := conn.Query(ctx, `
+		SELECT t.oid, t.typname, a.attname, a.atttypid
+		FROM pg_type t
+		JOIN pg_class c ON c.oid = t.typrelid
+		JOIN pg_attribute a ON a.attrelid = c.oid
+		WHERE t.typtype = 'c' AND c.relkind = 'c' AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY t.oid, a.attnum`)
+	if err != nil {
+		return fmt.Errorf("error looking up composite types: %w", err)
+	}
+	defer rows.Close()
+
+	type composite struct {
+		oid    uint32
+		name   string
+		fields []pgtype.CompositeTypeField
+	}
+	var pending []*composite
+	for rows.Next() {
+		var oid, fieldOID uint32
+		var name, field string
+		if err := rows.Scan(&oid, &name, &field, &fieldOID); err != nil {
+			return fmt.Errorf("error looking up composite types: %w", err)
+		}
+		if len(pending) == 0 || pending[len(pending)-1].oid != oid {
+			pending = append(pending, &composite{oid: oid, name: name})
+		}
+		last := pending[len(pending)-1]
+		last.fields = append(last.fields, pgtype.CompositeTypeField{Name: field, OID: fieldOID})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error looking up composite types: %w", err)
+	}
+
+	// Types used as attributes of other types must be registered first
+	ci := conn.ConnInfo()
+	for len(pending) > 0 {
+		var next []*composite
+		for _, c := range pending {
+			ct, err := pgtype.NewCompositeType(c.name, c.fields, ci)
+			if err != nil {
+				next = append(next, c)
+				continue
+			}
+			ci.RegisterDataType(pgtype.DataType{Value: &compositeType{ct}, Name: c.name, OID: c.oid})
+		}
+		if len(next) == len(pending) {
+			break
+		}
+		pending = next
+	}
+	return nil
+}
+
+// compositeType is the pgtype value registered for composite types. It is
+// scanned as a compositeRow, or as the struct registered for the type, and
+// can be set from a map keyed by attribute name or a struct.
+type compositeType struct {
+	*pgtype.CompositeType
+}
+
+// NewTypeValue implements pgtype.TypeValue
+func (c *compositeType) NewTypeValue() pgtype.Value {
+	return &compositeType{c.CompositeType.NewTypeValue().(*pgtype.CompositeType)}
+}
+
+// Set sets the attributes from a map keyed by attribute name, a struct whose
+// fields are named like in Fetch, or a []interface{} in attribute order
+func (c *compositeType) Set(src interface{}) error {
+	var lookup func(name string) interface{}
+
+	switch v := src.(type) {
+	case map[string]interface{}:
+		lookup = func(name string) interface{} { return v[name] }
+	case compositeRow:
+		lookup = func(name string) interface{} { return v[name] }
+	default:
+		value := reflect.ValueOf(src)
+		if value.Kind() != reflect.Struct {
+			return c.CompositeType.Set(src)
+		}
+		fields, err := structFields(value.Type())
+		if err != nil {
+			return err
+		}
+		lookup = func(name string) interface{} {
+			if index, ok := fields[name]; ok {
+				return value.FieldByIndex(index).Interface()
+			}
+			return nil
+		}
+	}
+
+	fields := c.Fields()
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		values[i] = compositeFieldValue(lookup(field.Name))
+	}
+	return c.CompositeType.Set(values)
+}
+
+// compositeFieldValue converts the package's value types that pgtype cannot
+// set an attribute from
+func compositeFieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case decimal.Decimal:
+		return v.String()
+	case uuid.UUID:
+		return [16]byte(v)
+	}
+	return value
+}
+
+// Get returns the attributes as a compositeRow, or as the struct registered
+// for the type
+func (c compositeType) Get() interface{} {
+	value := c.CompositeType.Get()
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+
+	if t, ok := compositeStructs.Load(c.TypeName()); ok {
+		dst := reflect.New(t.(reflect.Type))
+		if err := c.CompositeType.AssignTo(dst.Interface()); err == nil {
+			return dst.Elem().Interface()
+		}
+	}
+	return compositeRow(m)
+}
+
+// nativeComposite converts the attributes of row to native types; attributes
+// that are not present become nil
+func nativeComposite(row compositeRow, decode decodeOptions) map[string]interface{} {
+	m := make(map[string]interface{}, len(row))
+	for name, value := range row {
+		native, ok := toNativeValue(value, decode)
+		if !ok {
+			native = nil
+		}
+		m[name] = native
+	}
+	return m
+}
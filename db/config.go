@@ -0,0 +1,603 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultConnectRetryDelay is the wait before the first dial retry
+const DefaultConnectRetryDelay = time.Second
+
+// maxConnectRetryDelay caps the backoff between dial retries
+const maxConnectRetryDelay = 30 * time.Second
+
+// Defaults applied by LoadConfig to fields missing from the file
+const (
+	DefaultHost     = "localhost"
+	DefaultPort     = 5432
+	DefaultSSLMode  = "prefer"
+	DefaultLogLevel = "error"
+)
+
+// DefaultProfile is the profile LoadConfig picks from a file with several profiles
+const DefaultProfile = "default"
+
+// DatabaseConfig represents the structure of the YAML file
+type DatabaseConfig struct {
+	// ConnString is a postgres:// URL or key=value DSN. When set, it provides
+	// the connection parameters, and the discrete fields below that are set,
+	// such as SSLRootCert, are merged into it and win over the same parameter
+	// in ConnString. The pool, session and logging settings apply as usual.
+	ConnString string `yaml:"connString"`
+
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	DBName   string `yaml:"dbname"`
+	SSLMode  string `yaml:"sslmode"`
+	LogLevel string `yaml:"logLevel"`
+	NATSURL  string `yaml:"nats_url"`
+
+	// Service names a section of the libpq service file (pg_service.conf, or
+	// ServiceFile) that supplies any connection field left empty here.
+	// PassFile overrides ~/.pgpass, which is read whenever Password is empty.
+	Service     string `yaml:"service"`
+	ServiceFile string `yaml:"serviceFile"`
+	PassFile    string `yaml:"passfile"`
+
+	// TLS files, passed to the driver as sslrootcert, sslcert and sslkey
+	SSLRootCert string `yaml:"sslrootcert"`
+	SSLCert     string `yaml:"sslcert"`
+	SSLKey      string `yaml:"sslkey"`
+
+	// TLSConfig, when set, is used as is for every connection instead of the
+	// config derived from SSLMode and the files above. Set ServerName for
+	// verify-full style hostname checks. Connections never fall back to plain text.
+	TLSConfig *tls.Config `yaml:"-"`
+
+	// CredentialProvider, when set, supplies User and Password for every new
+	// connection; the static fields are then only used as a fallback user name.
+	// Credentials are refreshed CredentialRefreshWindow before they expire.
+	CredentialProvider      CredentialProvider `yaml:"-"`
+	CredentialRefreshWindow time.Duration      `yaml:"credentialRefreshWindow"`
+
+	// AuthMode "rds-iam" replaces Password with an RDS IAM auth token generated
+	// for every new connection, signed with the AWS_* environment credentials
+	// for AWSRegion. AuthToken overrides how the token is produced.
+	AuthMode  string        `yaml:"authMode"`
+	AWSRegion string        `yaml:"awsRegion"`
+	AuthToken AuthTokenFunc `yaml:"-"`
+
+	// Session parameters sent when every pooled connection starts.
+	// RuntimeParams holds any other server setting by name.
+	ApplicationName  string            `yaml:"applicationName"`
+	SearchPath       string            `yaml:"searchPath"`
+	StatementTimeout time.Duration     `yaml:"statementTimeout"`
+	TimeZone         string            `yaml:"timezone"`
+	RuntimeParams    map[string]string `yaml:"runtimeParams"`
+
+	// Pool tuning; zero values keep the pgxpool defaults.
+	// Durations are written in YAML as strings such as "30m" or "1h".
+	MaxConns          int32         `yaml:"maxConns"`
+	MinConns          int32         `yaml:"minConns"`
+	MaxConnLifetime   time.Duration `yaml:"maxConnLifetime"`
+	MaxConnIdleTime   time.Duration `yaml:"maxConnIdleTime"`
+	HealthCheckPeriod time.Duration `yaml:"healthCheckPeriod"`
+
+	// LazyConnect creates the pool without dialing, so InitDB succeeds while
+	// the database is briefly unavailable and connections are made on first use.
+	// ConnectRetries retries failed dials, waiting ConnectRetryDelay before the
+	// first retry and doubling the wait up to maxConnectRetryDelay.
+	LazyConnect       bool          `yaml:"lazyConnect"`
+	ConnectRetries    int           `yaml:"connectRetries"`
+	ConnectRetryDelay time.Duration `yaml:"connectRetryDelay"`
+
+	// FetchLocation, when set, moves every timestamptz value fetched to it,
+	// e.g. time.UTC, instead of the local time zone of the process.
+	// FetchTimeZone names it in YAML, such as "UTC" or "Europe/Berlin".
+	FetchLocation *time.Location `yaml:"-"`
+	FetchTimeZone string         `yaml:"fetchTimezone"`
+
+	// Hstore registers the hstore type on every connection, so hstore columns
+	// are fetched as map[string]*string and can be bulk loaded from one. The
+	// hstore extension must be installed in the database.
+	Hstore bool `yaml:"hstore"`
+
+	// PostGIS, GeometryWKT or GeometryGeoJSON, registers the geometry and
+	// geography types on every connection and returns their columns in that
+	// format. The postgis extension must be installed in the database.
+	PostGIS string `yaml:"postgis"`
+
+	// SlowQueryThreshold, when set, logs every fetch, exec and bulk load call
+	// that runs longer than it, with its SQL, duration, rows and the time it
+	// waited for a connection. SlowQueryMaxSQL truncates the SQL to that many
+	// bytes; zero logs it whole.
+	SlowQueryThreshold time.Duration `yaml:"slowQueryThreshold"`
+	SlowQueryMaxSQL    int           `yaml:"slowQueryMaxSQL"`
+
+	// Replicas are read replicas served by FetchReadOnly. They share every
+	// other setting, credentials included, with the primary.
+	Replicas []HostConfig `yaml:"replicas"`
+}
+
+// LoadConfig reads a DatabaseConfig from the YAML file at path.
+//
+// The file either holds a single config at the top level, or several named
+// profiles, each one a mapping of config fields:
+//
+//	production:
+//	  host: db.internal
+//	  user: app
+//	staging:
+//	  host: db.staging.internal
+//	  user: app
+//
+// For a file with profiles, LoadConfig returns the "default" profile, or the
+// only profile if there is just one; use LoadConfigProfile to pick another.
+// Missing fields are filled with the package defaults.
+func LoadConfig(path string) (*DatabaseConfig, error) {
+	return LoadConfigProfile(path, "")
+}
+
+// LoadConfigProfile reads the named profile from the YAML file at path.
+// An empty profile behaves like LoadConfig.
+func LoadConfigProfile(path, profile string) (*DatabaseConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	config, err := parseConfig(data, profile)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+
+	config.setDefaults()
+	return config, nil
+}
+
+// parseConfig decodes data as either a single config or a set of named profiles
+func parseConfig(data []byte, profile string) (*DatabaseConfig, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	if !hasProfiles(raw) {
+		if profile != "" {
+			return nil, fmt.Errorf("profile %q requested but the file has no profiles", profile)
+		}
+		config := &DatabaseConfig{}
+		if err := yaml.Unmarshal(data, config); err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+
+	var profiles map[string]*DatabaseConfig
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return nil, err
+	}
+
+	if profile == "" {
+		if _, ok := profiles[DefaultProfile]; ok {
+			profile = DefaultProfile
+		} else if len(profiles) == 1 {
+			for name := range profiles {
+				profile = name
+			}
+		} else {
+			return nil, fmt.Errorf("no %q profile and no profile requested; available: %s", DefaultProfile, profileNames(profiles))
+		}
+	}
+
+	config, ok := profiles[profile]
+	if !ok || config == nil {
+		return nil, fmt.Errorf("profile %q not found; available: %s", profile, profileNames(profiles))
+	}
+
+	return config, nil
+}
+
+// hasProfiles reports whether every top-level value of the document is a mapping
+func hasProfiles(raw map[string]interface{}) bool {
+	if len(raw) == 0 {
+		return false
+	}
+	for _, value := range raw {
+		if _, ok := value.(map[interface{}]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// profileNames returns the sorted profile names for error messages
+func profileNames(profiles map[string]*DatabaseConfig) string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// ConfigFromEnv builds a DatabaseConfig from the standard libpq environment
+// variables PGHOST, PGPORT, PGUSER, PGPASSWORD, PGDATABASE and PGSSLMODE,
+// and takes ConnString from DATABASE_URL as set by most hosting platforms.
+// Unset variables leave their field empty, so the result can be layered on top
+// of a file config with MergeConfig:
+//
+//	fileConfig, err := db.LoadConfig("config.yaml")
+//	...
+//	envConfig, err := db.ConfigFromEnv()
+//	...
+//	err = db.InitDB(db.MergeConfig(fileConfig, envConfig))
+func ConfigFromEnv() (*DatabaseConfig, error) {
+	config := &DatabaseConfig{
+		ConnString: os.Getenv("DATABASE_URL"),
+		Host:       os.Getenv("PGHOST"),
+		User:       os.Getenv("PGUSER"),
+		Password:   os.Getenv("PGPASSWORD"),
+		DBName:     os.Getenv("PGDATABASE"),
+		SSLMode:    os.Getenv("PGSSLMODE"),
+	}
+
+	if port := os.Getenv("PGPORT"); port != "" {
+		p, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PGPORT %q: %w", port, err)
+		}
+		config.Port = p
+	}
+
+	return config, nil
+}
+
+// MergeConfig returns a copy of base where every field that is set in override
+// replaces the corresponding field of base. Either argument may be nil.
+//
+// A field is set when it is not the zero value of its type, so an override
+// cannot turn a field of base back to false, 0 or "": an override with
+// LazyConnect false or StatementTimeout 0 leaves the value of base in place.
+// Change such fields on the merged config instead. Maps and slices, such as
+// RuntimeParams and Replicas, are replaced as a whole, not merged.
+func MergeConfig(base, override *DatabaseConfig) *DatabaseConfig {
+	merged := &DatabaseConfig{}
+	if base != nil {
+		*merged = *base
+	}
+	if override == nil {
+		return merged
+	}
+
+	dst := reflect.ValueOf(merged).Elem()
+	src := reflect.ValueOf(override).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if field := src.Field(i); !field.IsZero() {
+			dst.Field(i).Set(field)
+		}
+	}
+
+	return merged
+}
+
+// validSSLModes are the sslmode values understood by the driver
+var validSSLModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// ConfigError lists every problem found by DatabaseConfig.Validate
+type ConfigError struct {
+	Problems []string
+}
+
+// Error implements the error interface
+func (e *ConfigError) Error() string {
+	return "invalid database config: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate checks the config for problems that would make connecting fail or
+// behave unexpectedly and reports all of them at once as a *ConfigError.
+// InitDB validates the config before any connection attempt.
+func (c *DatabaseConfig) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// A connection string carries its own connection parameters
+	if c.ConnString == "" {
+		// A service entry may supply the host, user and port
+		if c.Service == "" {
+			if c.Host == "" {
+				addf("host is required")
+			}
+			if c.User == "" && c.CredentialProvider == nil {
+				addf("user is required")
+			}
+		}
+		if c.Port < 0 || c.Port > 65535 || (c.Port == 0 && c.Service == "") {
+			addf("port %d is out of range 1-65535", c.Port)
+		}
+		if c.SSLMode != "" && !validSSLModes[c.SSLMode] {
+			addf("unknown sslmode %q (want disable, allow, prefer, require, verify-ca or verify-full)", c.SSLMode)
+		}
+	}
+
+	if _, ok := logLevelMapping[c.LogLevel]; c.LogLevel != "" && !ok {
+		addf("unknown logLevel %q (want trace, debug, info, warn, error or none)", c.LogLevel)
+	}
+
+	if c.ConnectRetries < 0 {
+		addf("connectRetries must not be negative")
+	}
+
+	if c.StatementTimeout < 0 {
+		addf("statementTimeout must not be negative")
+	}
+
+	if c.SlowQueryThreshold < 0 {
+		addf("slowQueryThreshold must not be negative")
+	}
+	if c.SlowQueryMaxSQL < 0 {
+		addf("slowQueryMaxSQL must not be negative")
+	}
+
+	if c.MaxConns < 0 {
+		addf("maxConns must not be negative")
+	}
+	if c.MinConns < 0 {
+		addf("minConns must not be negative")
+	}
+	if c.MaxConns > 0 && c.MinConns > c.MaxConns {
+		addf("minConns %d is greater than maxConns %d", c.MinConns, c.MaxConns)
+	}
+
+	for i, replica := range c.Replicas {
+		if replica.Host == "" {
+			addf("replicas[%d]: host is required", i)
+		}
+		if replica.Port < 0 || replica.Port > 65535 {
+			addf("replicas[%d]: port %d is out of range 1-65535", i, replica.Port)
+		}
+	}
+
+	if (c.SSLCert == "") != (c.SSLKey == "") {
+		addf("sslcert and sslkey must be set together")
+	}
+
+	if c.FetchLocation == nil && c.FetchTimeZone != "" {
+		if _, err := time.LoadLocation(c.FetchTimeZone); err != nil {
+			addf("unknown fetchTimezone %q", c.FetchTimeZone)
+		}
+	}
+
+	switch c.PostGIS {
+	case "", GeometryWKT, GeometryGeoJSON:
+	default:
+		addf("unknown postgis format %q (want wkt or geojson)", c.PostGIS)
+	}
+
+	switch c.AuthMode {
+	case "":
+	case AuthModeRDSIAM:
+		if c.AWSRegion == "" && c.AuthToken == nil {
+			addf("awsRegion is required for rds-iam auth")
+		}
+	default:
+		addf("unknown authMode %q", c.AuthMode)
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{Problems: problems}
+	}
+	return nil
+}
+
+// setDefaults fills in fields that were left empty
+func (c *DatabaseConfig) setDefaults() {
+	// Connection fields left empty are resolved from the service file
+	if c.Service == "" {
+		if c.Host == "" {
+			c.Host = DefaultHost
+		}
+		if c.Port == 0 {
+			c.Port = DefaultPort
+		}
+	}
+	if c.SSLMode == "" {
+		c.SSLMode = DefaultSSLMode
+	}
+	if c.LogLevel == "" {
+		c.LogLevel = DefaultLogLevel
+	}
+	if c.DBName == "" && c.Service == "" {
+		// libpq also defaults the database name to the user name
+		c.DBName = c.User
+	}
+}
+
+// fetchLocation returns the location timestamptz values are fetched in, nil
+// to leave them in the local time zone
+func (c *DatabaseConfig) fetchLocation() *time.Location {
+	if c.FetchLocation != nil || c.FetchTimeZone == "" {
+		return c.FetchLocation
+	}
+	// Validate reports a name that does not load
+	loc, _ := time.LoadLocation(c.FetchTimeZone)
+	return loc
+}
+
+// applyPoolSettings copies the pool tuning fields that are set onto poolConfig
+func (c *DatabaseConfig) applyPoolSettings(poolConfig *pgxpool.Config) {
+	if c.MaxConns > 0 {
+		poolConfig.MaxConns = c.MaxConns
+	}
+	if c.MinConns > 0 {
+		poolConfig.MinConns = c.MinConns
+	}
+	if c.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = c.MaxConnLifetime
+	}
+	if c.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = c.MaxConnIdleTime
+	}
+	if c.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = c.HealthCheckPeriod
+	}
+}
+
+// applyConnectMode sets lazy connecting and wraps the dialer with retries
+func (c *DatabaseConfig) applyConnectMode(poolConfig *pgxpool.Config) {
+	poolConfig.LazyConnect = c.LazyConnect
+
+	if c.ConnectRetries <= 0 {
+		return
+	}
+
+	delay := c.ConnectRetryDelay
+	if delay <= 0 {
+		delay = DefaultConnectRetryDelay
+	}
+	retries := c.ConnectRetries
+	dial := poolConfig.ConnConfig.DialFunc
+
+	poolConfig.ConnConfig.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		wait := delay
+		for attempt := 0; ; attempt++ {
+			conn, err := dial(ctx, network, addr)
+			if err == nil || attempt >= retries {
+				return conn, err
+			}
+
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return nil, err
+			}
+
+			wait *= 2
+			if wait > maxConnectRetryDelay {
+				wait = maxConnectRetryDelay
+			}
+		}
+	}
+}
+
+// applyRuntimeParams sets the configured session parameters on every connection
+func (c *DatabaseConfig) applyRuntimeParams(poolConfig *pgxpool.Config) {
+	params := poolConfig.ConnConfig.RuntimeParams
+	for name, value := range c.RuntimeParams {
+		params[name] = value
+	}
+	if c.ApplicationName != "" {
+		params["application_name"] = c.ApplicationName
+	}
+	if c.SearchPath != "" {
+		params["search_path"] = c.SearchPath
+	}
+	if c.StatementTimeout > 0 {
+		params["statement_timeout"] = strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10)
+	}
+	if c.TimeZone != "" {
+		params["TimeZone"] = c.TimeZone
+	}
+}
+
+// applyTLS installs the caller-provided TLS config, if any, on poolConfig
+func (c *DatabaseConfig) applyTLS(poolConfig *pgxpool.Config) {
+	if c.TLSConfig == nil {
+		return
+	}
+	poolConfig.ConnConfig.TLSConfig = c.TLSConfig
+	// Drop the sslmode fallbacks so there is no plain-text attempt
+	poolConfig.ConnConfig.Fallbacks = nil
+}
+
+// buildConnString builds the PostgreSQL connection string from the
+// DatabaseConfig: the discrete fields that are set, merged into ConnString
+// when there is one
+func buildConnString(config *DatabaseConfig) string {
+	port := ""
+	if config.Port != 0 {
+		port = strconv.Itoa(config.Port)
+	}
+
+	// Empty fields are left out so the driver can resolve them the way libpq
+	// does: from the service file, ~/.pgpass and the PG* environment variables
+	settings := []struct{ key, value string }{
+		{"user", config.User},
+		{"password", config.Password},
+		{"host", config.Host},
+		{"port", port},
+		{"dbname", config.DBName},
+		{"sslmode", config.SSLMode},
+		{"service", config.Service},
+		{"servicefile", config.ServiceFile},
+		{"passfile", config.PassFile},
+		{"sslrootcert", config.SSLRootCert},
+		{"sslcert", config.SSLCert},
+		{"sslkey", config.SSLKey},
+	}
+
+	if isConnURL(config.ConnString) {
+		u, err := url.Parse(config.ConnString)
+		if err != nil {
+			// Leave the error to the driver, which reports it with context
+			return config.ConnString
+		}
+		// Query parameters win over the user, host, port and database of the URL
+		query := u.Query()
+		for _, setting := range settings {
+			if setting.value != "" {
+				query.Set(setting.key, setting.value)
+			}
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	pairs := make([]string, 0, len(settings)+1)
+	if config.ConnString != "" {
+		pairs = append(pairs, config.ConnString)
+	}
+	// The last occurrence of a key in a DSN wins
+	for _, setting := range settings {
+		if setting.value != "" {
+			pairs = append(pairs, setting.key+"="+quoteDSNValue(setting.value))
+		}
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+// isConnURL reports whether connString is a postgres:// URL rather than a DSN
+func isConnURL(connString string) bool {
+	return strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://")
+}
+
+// quoteDSNValue quotes a key=value DSN value so paths with spaces or quotes survive parsing
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
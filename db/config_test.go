@@ -0,0 +1,177 @@
+package db
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+func TestBuildConnString(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DatabaseConfig
+		want   string
+	}{
+		{
+			"discrete fields",
+			DatabaseConfig{User: "app", Password: "it's secret", Host: "db", Port: 5432, DBName: "trades", SSLMode: "require"},
+			`user='app' password='it\'s secret' host='db' port='5432' dbname='trades' sslmode='require'`,
+		},
+		{"empty fields left out", DatabaseConfig{Host: "db"}, `host='db'`},
+		{"path with spaces", DatabaseConfig{SSLRootCert: `C:\certs\root ca.pem`}, `sslrootcert='C:\\certs\\root ca.pem'`},
+		{"DSN alone", DatabaseConfig{ConnString: "host=db user=app"}, "host=db user=app"},
+		{"DSN merged", DatabaseConfig{ConnString: "host=db user=app", SSLRootCert: "/ca.pem"}, "host=db user=app sslrootcert='/ca.pem'"},
+		{"URL alone", DatabaseConfig{ConnString: "postgres://app@db:5432/trades"}, "postgres://app@db:5432/trades"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildConnString(&tt.config); got != tt.want {
+				t.Errorf("buildConnString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildConnStringMergesURL(t *testing.T) {
+	config := &DatabaseConfig{
+		ConnString:  "postgres://app:pw@db.example.com:5432/trades?sslmode=require&application_name=web",
+		SSLMode:     "verify-full",
+		SSLRootCert: "/etc/ssl/root.pem",
+	}
+	u, err := url.Parse(buildConnString(config))
+	if err != nil {
+		t.Fatalf("buildConnString gave an invalid URL: %v", err)
+	}
+
+	query := u.Query()
+	want := map[string]string{"sslmode": "verify-full", "sslrootcert": "/etc/ssl/root.pem", "application_name": "web"}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if u.Host != "db.example.com:5432" || u.Path != "/trades" || u.User.String() != "app:pw" {
+		t.Errorf("the URL lost its address: %s", u.Redacted())
+	}
+}
+
+func TestBuildConnStringFieldsWinOverURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   DatabaseConfig
+		host     string
+		port     uint16
+		user     string
+		password string
+		database string
+	}{
+		{
+			"URL only",
+			DatabaseConfig{ConnString: "postgres://app:pw@db:5433/trades?sslmode=disable"},
+			"db", 5433, "app", "pw", "trades",
+		},
+		{
+			"fields override",
+			DatabaseConfig{ConnString: "postgres://app:pw@db:5433/trades?sslmode=disable", Host: "replica", Port: 6432, User: "admin", Password: "new", DBName: "audit"},
+			"replica", 6432, "admin", "new", "audit",
+		},
+		{
+			"DSN fields override",
+			DatabaseConfig{ConnString: "host=db port=5433 user=app dbname=trades sslmode=disable", Host: "replica", DBName: "audit"},
+			"replica", 5433, "app", "", "audit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := pgconn.ParseConfig(buildConnString(&tt.config))
+			if err != nil {
+				t.Fatalf("ParseConfig: %v", err)
+			}
+			if parsed.Host != tt.host || parsed.Port != tt.port || parsed.User != tt.user || parsed.Database != tt.database {
+				t.Errorf("got %s:%d user %s db %s, want %s:%d user %s db %s",
+					parsed.Host, parsed.Port, parsed.User, parsed.Database, tt.host, tt.port, tt.user, tt.database)
+			}
+			if tt.password != "" && parsed.Password != tt.password {
+				t.Errorf("password = %q, want %q", parsed.Password, tt.password)
+			}
+		})
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	base := &DatabaseConfig{
+		Host:             "file-host",
+		User:             "file-user",
+		Port:             5432,
+		LazyConnect:      true,
+		StatementTimeout: 30 * time.Second,
+		RuntimeParams:    map[string]string{"work_mem": "64MB"},
+	}
+
+	tests := []struct {
+		name     string
+		base     *DatabaseConfig
+		override *DatabaseConfig
+		check    func(t *testing.T, merged *DatabaseConfig)
+	}{
+		{"set fields win", base, &DatabaseConfig{Host: "env-host", Port: 6432}, func(t *testing.T, merged *DatabaseConfig) {
+			if merged.Host != "env-host" || merged.Port != 6432 || merged.User != "file-user" {
+				t.Errorf("merged = %s:%d user %s, want env-host:6432 user file-user", merged.Host, merged.Port, merged.User)
+			}
+		}},
+		{"zero values cannot reset", base, &DatabaseConfig{LazyConnect: false, StatementTimeout: 0}, func(t *testing.T, merged *DatabaseConfig) {
+			if !merged.LazyConnect || merged.StatementTimeout != 30*time.Second {
+				t.Errorf("LazyConnect = %v, StatementTimeout = %s, want the base values", merged.LazyConnect, merged.StatementTimeout)
+			}
+		}},
+		{"maps are replaced", base, &DatabaseConfig{RuntimeParams: map[string]string{"jit": "off"}}, func(t *testing.T, merged *DatabaseConfig) {
+			if len(merged.RuntimeParams) != 1 || merged.RuntimeParams["jit"] != "off" {
+				t.Errorf("RuntimeParams = %v, want only the override", merged.RuntimeParams)
+			}
+		}},
+		{"nil override", base, nil, func(t *testing.T, merged *DatabaseConfig) {
+			if merged == base || merged.Host != "file-host" {
+				t.Errorf("merged = %+v, want a copy of base", merged)
+			}
+		}},
+		{"nil base", nil, &DatabaseConfig{Host: "env-host"}, func(t *testing.T, merged *DatabaseConfig) {
+			if merged.Host != "env-host" {
+				t.Errorf("Host = %q, want env-host", merged.Host)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, MergeConfig(tt.base, tt.override))
+		})
+	}
+
+	if base.Host != "file-host" || base.Port != 5432 {
+		t.Error("MergeConfig modified base")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://app@db/trades")
+	t.Setenv("PGHOST", "env-host")
+	t.Setenv("PGPORT", "6432")
+	t.Setenv("PGUSER", "")
+
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatalf("ConfigFromEnv: %v", err)
+	}
+	if config.ConnString != "postgres://app@db/trades" || config.Host != "env-host" || config.Port != 6432 || config.User != "" {
+		t.Errorf("ConfigFromEnv() = %+v", config)
+	}
+
+	t.Setenv("PGPORT", "not-a-port")
+	if _, err := ConfigFromEnv(); err == nil {
+		t.Error("ConfigFromEnv accepted an invalid PGPORT")
+	}
+}
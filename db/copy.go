@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// CopyInsert COPYs data straight into table on the package-level Pool; see DB.CopyInsert
+func CopyInsert(ctx context.Context, data []map[string]interface{}, table string, opts ...BulkOption) (int64, error) {
+	return defaultDB().CopyInsert(ctx, data, table, opts...)
+}
+
+// CopyInsert writes data into table with a single COPY, skipping the temporary
+// table and ON CONFLICT merge of InsertBulkData. Use it for tables without a
+// primary key or for rows known to be new: a duplicate key fails the whole
+// COPY and nothing is written. Values are converted like InsertBulkData, and
+// the timestamp options apply; chunking and conflict options are ignored. It
+// returns the number of rows copied.
+func (d *DB) CopyInsert(ctx context.Context, data []map[string]interface{}, table string, opts ...BulkOption) (int64, error) {
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	return d.bulkCall(ctx, table, len(data), func(ctx context.Context) (int64, error) {
+		return d.copyInsert(ctx, data, table, opts)
+	})
+}
+
+// copyInsert runs CopyInsert
+func (d *DB) copyInsert(ctx context.Context, data []map[string]interface{}, table string, opts []BulkOption) (int64, error) {
+	options := &bulkOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	// COPY writes NULLs as they are, there is no merge to fill in the defaults
+	if options.nullPolicy == NullDefault {
+		return 0, errors.New("db: CopyInsert does not support NullDefault")
+	}
+
+	columns, err := d.bulkColumns(ctx, table, data[0], options)
+	if err != nil {
+		return 0, err
+	}
+	if err := validateRows(data, columns, options.nullPolicy); err != nil {
+		return 0, err
+	}
+
+	if err := d.prepareBulk(ctx, table, columns, options); err != nil {
+		return 0, err
+	}
+
+	data, err = formatBulkRows(data, 0, columns, options)
+	if err != nil {
+		return 0, err
+	}
+
+	if options.dryRun != nil {
+		d.recordDryRun(ctx, options, buildCopyStatement(tableIdentifier(table), columns))
+		return 0, nil
+	}
+
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	return conn.CopyFrom(ctx, tableIdentifier(table), columns, withProgress(newMapCopyFromSource(data, columns), options))
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// DefaultCredentialRefreshWindow is how long before expiry credentials are refreshed
+const DefaultCredentialRefreshWindow = time.Minute
+
+// Credentials are a user name and password issued by a CredentialProvider
+type Credentials struct {
+	User     string
+	Password string
+	// ExpiresAt is when the credentials stop working; zero means never
+	ExpiresAt time.Time
+}
+
+// CredentialProvider supplies database credentials at connect time, for
+// example from HashiCorp Vault or AWS Secrets Manager. Credentials are cached
+// and requested again shortly before they expire.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialProviderFunc adapts a function to the CredentialProvider interface
+type CredentialProviderFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials implements CredentialProvider
+func (f CredentialProviderFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// credentialCache caches the credentials of a provider until they are about to expire
+type credentialCache struct {
+	provider      CredentialProvider
+	refreshWindow time.Duration
+
+	mu    sync.Mutex
+	creds *Credentials
+}
+
+// get returns the cached credentials, fetching new ones when missing or close to expiry
+func (c *credentialCache) get(ctx context.Context) (Credentials, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.creds != nil && (c.creds.ExpiresAt.IsZero() || time.Now().Add(c.refreshWindow).Before(c.creds.ExpiresAt)) {
+		return *c.creds, nil
+	}
+
+	creds, err := c.provider.Credentials(ctx)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("error fetching database credentials: %w", err)
+	}
+	c.creds = &creds
+
+	return creds, nil
+}
+
+// applyCredentials makes every new connection authenticate with credentials
+// from the config's CredentialProvider, if one is set
+func (c *DatabaseConfig) applyCredentials(poolConfig *pgxpool.Config) {
+	if c.CredentialProvider == nil {
+		return
+	}
+
+	refreshWindow := c.CredentialRefreshWindow
+	if refreshWindow <= 0 {
+		refreshWindow = DefaultCredentialRefreshWindow
+	}
+	cache := &credentialCache{provider: c.CredentialProvider, refreshWindow: refreshWindow}
+
+	poolConfig.BeforeConnect = chainBeforeConnect(poolConfig.BeforeConnect, func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		creds, err := cache.get(ctx)
+		if err != nil {
+			return err
+		}
+		if creds.User != "" {
+			connConfig.User = creds.User
+		}
+		connConfig.Password = creds.Password
+		return nil
+	})
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// CSVOptions configures ImportCSV
+type CSVOptions struct {
+	// Header skips the first line of the input
+	Header bool
+	// ColumnsFromHeader takes the column list from the first line of the
+	// input, which is then skipped. It overrides Columns.
+	ColumnsFromHeader bool
+	// Columns are the table columns the CSV fields are loaded into, in order.
+	// Empty means every column of the table in table order.
+	Columns []string
+	// Delimiter separates the fields; the default is a comma
+	Delimiter rune
+	// Null is the string that stands for NULL; the default is an unquoted empty field
+	Null string
+}
+
+// ImportCSV streams r into table on the package-level Pool; see DB.ImportCSV
+func ImportCSV(ctx context.Context, table string, r io.Reader, opts CSVOptions) (int64, error) {
+	return defaultDB().ImportCSV(ctx, table, r, opts)
+}
+
+// ImportCSV streams the CSV read from r into table with COPY ... FROM STDIN
+// WITH (FORMAT csv), so the input is parsed by the server and never held in
+// memory. Like CopyInsert the rows are appended as they are, without an
+// ON CONFLICT merge, and a bad line fails the whole import. It returns the
+// number of rows copied.
+func (d *DB) ImportCSV(ctx context.Context, table string, r io.Reader, opts CSVOptions) (int64, error) {
+	delimiter := opts.Delimiter
+	if delimiter == 0 {
+		delimiter = ','
+	}
+	if delimiter >= utf8.RuneSelf {
+		return 0, fmt.Errorf("db: CSV delimiter must be a single-byte character, got %q", delimiter)
+	}
+
+	columns := opts.Columns
+	header := opts.Header
+	if opts.ColumnsFromHeader {
+		buffered := bufio.NewReader(r)
+		names, err := readCSVHeader(buffered, delimiter)
+		if err != nil {
+			return 0, fmt.Errorf("error reading CSV header: %w", err)
+		}
+		columns, header, r = names, false, buffered
+	}
+
+	sql := buildCopyCSVStatement(table, columns, header, delimiter, opts.Null)
+
+	return d.bulkCall(ctx, table, 0, func(ctx context.Context) (int64, error) {
+		return d.copyCSV(ctx, r, sql)
+	})
+}
+
+// copyCSV runs the COPY statement sql of ImportCSV with r as its input
+func (d *DB) copyCSV(ctx context.Context, r io.Reader, sql string) (int64, error) {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	tag, err := conn.Conn().PgConn().CopyFrom(ctx, r, d.tagSQL(ctx, sql))
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// readCSVHeader reads the first line of r and returns its fields
+func readCSVHeader(r *bufio.Reader, delimiter rune) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return nil, err
+	}
+
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.Comma = delimiter
+	reader.TrimLeadingSpace = true
+	fields, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, field := range fields {
+		fields[i] = strings.TrimSpace(field)
+	}
+	return fields, nil
+}
+
+// buildCopyCSVStatement constructs the COPY statement of ImportCSV
+func buildCopyCSVStatement(table string, columns []string, header bool, delimiter rune, null string) string {
+	var sql strings.Builder
+
+	sql.WriteString("COPY ")
+	sql.WriteString(quoteTable(table))
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, col := range columns {
+			quoted[i] = quoteIdent(col)
+		}
+		sql.WriteString(" (" + strings.Join(quoted, ", ") + ")")
+	}
+
+	sql.WriteString(" FROM STDIN WITH (FORMAT csv")
+	if header {
+		sql.WriteString(", HEADER true")
+	}
+	sql.WriteString(", DELIMITER " + quoteLiteral(string(delimiter)))
+	if null != "" {
+		sql.WriteString(", NULL " + quoteLiteral(null))
+	}
+	sql.WriteString(")")
+
+	return sql.String()
+}
+
+// quoteLiteral quotes s as a SQL string literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
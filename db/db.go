@@ -2,14 +2,19 @@ package db
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"math/rand"
+	"net"
 	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,19 +25,20 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+// Pool is the connection pool used by the package-level functions. It is set
+// by InitDB; new code should prefer a Client returned by Connect. Reconnect and
+// ReloadConfig do not assign it, since that would race with its readers: the
+// package-level functions move to the new pool, and CurrentPool returns it.
 var Pool *pgxpool.Pool
-var columns []string
 
-// DatabaseConfig represents the structure of the YAML file
-type DatabaseConfig struct {
-	User     string `yaml:"user"`
-	Password string `yaml:"password"`
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	DBName   string `yaml:"dbname"`
-	SSLMode  string `yaml:"sslmode"`
-	LogLevel string `yaml:"logLevel"`
-	NATSURL  string `yaml:"nats_url"`
+// Map log level values from the config file to pgx.LogLevel constants
+var logLevelMapping = map[string]pgx.LogLevel{
+	"trace": pgx.LogLevelTrace,
+	"debug": pgx.LogLevelDebug,
+	"info":  pgx.LogLevelInfo,
+	"warn":  pgx.LogLevelWarn,
+	"error": pgx.LogLevelError,
+	"none":  pgx.LogLevelNone,
 }
 
 // CustomLogger is a custom logger that satisfies the pgx.Logger interface
@@ -44,74 +50,207 @@ type CustomLogger struct {
 // Log implements the pgx.Logger interface
 func (cl *CustomLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
 	if level <= cl.level {
-		cl.logger.Printf("%s: %s %s\n", level, msg, data)
+		cl.logger.Printf("%s: %s %v\n", level, msg, data)
 	}
 }
 
-func InitDB(config *DatabaseConfig) error {
-	ctx := context.Background()
+// InitDB connects the package-level Pool using config. The config and options
+// are retained so the connection can later be rebuilt with Reconnect.
+func InitDB(config *DatabaseConfig, opts ...PoolOption) error {
+	d, err := openDB(context.Background(), config, newPoolOptions(opts))
+	if err != nil {
+		return err
+	}
+
+	d.published = d.Pool()
+	Pool = d.published
+	std.Store(d)
+
+	return nil
+}
+
+// CurrentPool returns the pool the package-level functions run on: the pool
+// of the DB created by InitDB, which Reconnect and ReloadConfig replace, or
+// Pool when it was set directly
+func CurrentPool() *pgxpool.Pool {
+	return defaultDB().Pool()
+}
+
+// Close closes the current pool of the DB created by InitDB and the pools of
+// its replicas
+func Close() {
+	defaultDB().Close()
+}
+
+// InitDBFromURL connects the package-level Pool using a postgres:// URL,
+// as handed out by platforms such as Heroku, Render or Supabase
+func InitDBFromURL(url string, opts ...PoolOption) error {
+	return InitDB(&DatabaseConfig{ConnString: url}, opts...)
+}
 
-	// Map log level values from the config file to pgx.LogLevel constants
-	logLevelMapping := map[string]pgx.LogLevel{
-		"debug": pgx.LogLevelDebug,
-		"info":  pgx.LogLevelInfo,
-		"warn":  pgx.LogLevelWarn,
-		"error": pgx.LogLevelError,
-		// Add other mappings as needed
+// newPool creates a connection pool from config and options
+func newPool(ctx context.Context, config *DatabaseConfig, options *poolOptions) (*pgxpool.Pool, error) {
+	// Report every config problem before trying to connect
+	if err := config.Validate(); err != nil {
+		return nil, err
 	}
 
 	// Get the log level value from the config file
 	configLogLevel, ok := logLevelMapping[config.LogLevel]
 	if !ok {
-		// Default to LogLevelError or handle the error accordingly
+		// Default to LogLevelError when no level is configured
 		configLogLevel = pgx.LogLevelError
 	}
 
 	// Create a connection pool configuration
 	poolConfig, err := pgxpool.ParseConfig(buildConnString(config))
 	if err != nil {
-		return fmt.Errorf("error parsing connection string: %v", err)
+		return nil, fmt.Errorf("error parsing connection string: %v", err)
 	}
 
-	// Create a custom logger with the desired log level
-	customLogger := &CustomLogger{
-		logger: log.New(os.Stdout, "pgxpool:", log.LstdFlags),
-		level:  configLogLevel,
+	if options.logger != nil {
+		// Use the caller's logger, filtered by pgx at the configured level
+		poolConfig.ConnConfig.Logger = options.logger
+		poolConfig.ConnConfig.LogLevel = configLogLevel
+	} else {
+		// Create a custom logger with the desired log level
+		customLogger := &CustomLogger{
+			logger: log.New(os.Stdout, "pgxpool:", log.LstdFlags),
+			level:  configLogLevel,
+		}
+
+		// Set the custom logger for the connection pool
+		poolConfig.ConnConfig.Logger = customLogger
 	}
 
-	// Set the custom logger for the connection pool
-	poolConfig.ConnConfig.Logger = customLogger
+	// Apply the pool sizing and lifetime settings
+	config.applyPoolSettings(poolConfig)
+
+	// Dial lazily and retry failed dials if configured
+	config.applyConnectMode(poolConfig)
+
+	// Send the session parameters with every connection
+	config.applyRuntimeParams(poolConfig)
+
+	// Use the caller's TLS config if one was provided
+	config.applyTLS(poolConfig)
+
+	// Fetch credentials per connection when a provider is configured
+	config.applyCredentials(poolConfig)
+
+	// Generate a password per connection for token-based auth
+	if err := config.applyAuthToken(poolConfig); err != nil {
+		return nil, err
+	}
+
+	// Install the caller's connection hooks
+	options.apply(poolConfig)
+
+	// Register the enum and composite types, and hstore and PostGIS if enabled, on every connection
+	config.applyTypes(poolConfig)
+
+	// Point replica pools at their host
+	options.applyHost(poolConfig)
 
 	// Create a connection pool
-	Pool, err = pgxpool.ConnectConfig(ctx, poolConfig)
+	pool, err := pgxpool.ConnectConfig(ctx, poolConfig)
 	if err != nil {
-		return fmt.Errorf("unable to connect to the database: %v", err)
+		return nil, fmt.Errorf("unable to connect to the database: %v", err)
 	}
 
-	return nil
+	return pool, nil
+}
+
+// FetchData executes query on the package-level Pool and returns every row as
+// a map. Values in args are bound to the $1, $2, ... placeholders of query by
+// the driver, so they never have to be interpolated into the SQL.
+func FetchData(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return defaultDB().FetchDataFromTable(ctx, query, args...)
 }
 
-// buildConnString builds the PostgreSQL connection string from the DatabaseConfig
-func buildConnString(config *DatabaseConfig) string {
-	return fmt.Sprintf("user=%s password=%s host=%s port=%d dbname=%s sslmode=%s",
-		config.User, config.Password, config.Host, config.Port, config.DBName, config.SSLMode)
+// FetchDataFromTable executes query on the package-level Pool and returns every
+// row as a map. It is equivalent to FetchData: ctx cancellation and deadlines
+// apply to acquiring the connection and to the query itself.
+//
+// Deprecated: Use FetchData, which does the same under its new name.
+func FetchDataFromTable(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return defaultDB().FetchDataFromTable(ctx, query, args...)
 }
 
-func FetchDataFromTable(query string, wg *sync.WaitGroup) ([]map[string]interface{}, error) {
+// FetchDataFromTable executes query with args and returns every row as a map keyed by column name
+func (d *DB) FetchDataFromTable(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return d.fetchData(ctx, query, args, nil)
+}
+
+// fetchData runs the query of FetchDataFromTable, on replicas as FetchReadOnly
+// does when there are any. Either way the call is traced, counted, logged and
+// cached by d.
+func (d *DB) fetchData(ctx context.Context, query string, args []interface{}, replicas []*replica) ([]map[string]interface{}, error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, err
+	}
+
+	call, args := d.startCall(ctx, OpFetch, query, args)
+	defer call.done()
+
+	cache := call.options.cache
+	var queryKey, key string
+	if cache != nil {
+		queryKey, key = d.cacheKey(query, args, call.options.decode)
+		if len(replicas) > 0 {
+			key += "\x00replica"
+		}
+		if result, ok := cache.get(key); ok {
+			call.span.SetAttribute("db.cache_hit", true)
+			call.finish(len(result), nil)
+			return result, nil
+		}
+	}
+
+	var result []map[string]interface{}
+	var err error
+	if len(replicas) > 0 {
+		result, err = d.fetchReadOnly(call.ctx, query, args, call.options.decode, replicas)
+	} else {
+		result, err = d.fetchDataFromTable(call.ctx, query, args, call.options.decode)
+	}
+	call.finish(len(result), err)
+	if err != nil {
+		return result, call.wrapErr(err)
+	}
+	if err := d.transformRows(result); err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		cache.put(queryKey, key, result)
+	}
+	return result, nil
+}
+
+// fetchDataFromTable runs the query of FetchDataFromTable
+func (d *DB) fetchDataFromTable(ctx context.Context, query string, args []interface{}, decode decodeOptions) ([]map[string]interface{}, error) {
 	//inicio := time.Now()
 
 	// Acquire a connection from the pool
-	conn, err := Pool.Acquire(context.Background())
+	conn, release, err := d.acquire(ctx)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Release()
+	defer release()
 
 	// Execute the query
-	rows, err := conn.Query(context.Background(), query)
+	rows, err := conn.Query(ctx, d.tagSQL(ctx, query), args...)
 	if err != nil {
 		return nil, err
 	}
+
+	return collectRows(rows, decode)
+}
+
+// collectRows reads every row of rows into maps converted to native types as
+// set by decode and closes rows
+func collectRows(rows pgx.Rows, decode decodeOptions) ([]map[string]interface{}, error) {
 	defer rows.Close()
 
 	// Get information about the columns
@@ -126,32 +265,25 @@ func FetchDataFromTable(query string, wg *sync.WaitGroup) ([]map[string]interfac
 	result := make([]map[string]interface{}, 0)
 
 	for rows.Next() {
-		columnPointers := make([]interface{}, len(columns))
 		columnData := make([]interface{}, len(columns))
-
-		for i := range columnData {
-			columnPointers[i] = &columnData[i]
-		}
-
-		if err := rows.Scan(columnPointers...); err != nil {
-			return nil, err
+		if err := scanValues(rows, columnData, decode); err != nil {
+			return nil, newPartialResultError(result, err, decode)
 		}
 
 		entry := make(map[string]interface{})
 
 		for i, colName := range columns {
-			val := columnData[i]
-
-			if b, ok := val.([]byte); ok {
-				entry[colName] = string(b)
-			} else {
-				entry[colName] = val
-			}
+			entry[colName] = scannedValue(rows, i, columnData[i], decode)
 		}
 
 		result = append(result, entry)
 	}
 
+	// Surface errors that ended the iteration early
+	if err := rows.Err(); err != nil {
+		return nil, newPartialResultError(result, err, decode)
+	}
+
 	/*
 		fim := time.Now()
 		// Calculate the time difference
@@ -160,11 +292,135 @@ func FetchDataFromTable(query string, wg *sync.WaitGroup) ([]map[string]interfac
 		// Display the elapsed time
 		fmt.Printf("Select took %s to execute\n", tempoDecorrido)*/
 
-	result = formataToNativeType(result)
+	if !decode.raw {
+		result = formataToNativeType(result, decode)
+	}
 
 	return result, nil
 }
 
+// PartialResultError is returned by FetchDataFromTable when reading the result
+// set fails after some rows were already read. Rows holds those rows, converted
+// to native types, for callers that can make use of an incomplete result.
+type PartialResultError struct {
+	Rows []map[string]interface{}
+	Err  error
+}
+
+// Error implements the error interface
+func (e *PartialResultError) Error() string {
+	return fmt.Sprintf("query failed after %d rows: %v", len(e.Rows), e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *PartialResultError) Unwrap() error {
+	return e.Err
+}
+
+// newPartialResultError wraps err together with the rows read before it occurred
+func newPartialResultError(rows []map[string]interface{}, err error, decode decodeOptions) error {
+	if !decode.raw {
+		rows = formataToNativeType(rows, decode)
+	}
+	return &PartialResultError{Rows: rows, Err: err}
+}
+
+// FetchRows executes query and returns the column names once and every row as a
+// slice of values in column order, avoiding a map allocation per row. Values go
+// through the same native-type conversion as FetchDataFromTable; values that are
+// not present are returned as nil.
+func FetchRows(ctx context.Context, query string, args ...interface{}) ([]string, [][]interface{}, error) {
+	return defaultDB().FetchRows(ctx, query, args...)
+}
+
+// FetchRows executes query with args and returns the column names and positional rows
+func (d *DB) FetchRows(ctx context.Context, query string, args ...interface{}) ([]string, [][]interface{}, error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, nil, err
+	}
+
+	call, args := d.startCall(ctx, OpFetch, query, args)
+	defer call.done()
+
+	columns, result, err := d.fetchRows(call.ctx, query, args, call.options.decode)
+	call.finish(len(result), err)
+	return columns, result, call.wrapErr(err)
+}
+
+// fetchRows runs the query of FetchRows
+func (d *DB) fetchRows(ctx context.Context, query string, args []interface{}, decode decodeOptions) ([]string, [][]interface{}, error) {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	// Execute the query
+	rows, err := conn.Query(ctx, d.tagSQL(ctx, query), args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	// Extract column names from FieldDescriptions
+	colDescs := rows.FieldDescriptions()
+	columns := make([]string, len(colDescs))
+	for i, colDesc := range colDescs {
+		columns[i] = string(colDesc.Name)
+	}
+
+	result := make([][]interface{}, 0)
+
+	for rows.Next() {
+		columnData := make([]interface{}, len(columns))
+		if err := scanValues(rows, columnData, decode); err != nil {
+			return columns, nil, err
+		}
+
+		for i, val := range columnData {
+			columnData[i], _ = toNativeValue(scannedValue(rows, i, val, decode), decode)
+		}
+
+		result = append(result, columnData)
+	}
+
+	if err := rows.Err(); err != nil {
+		return columns, nil, err
+	}
+
+	return columns, result, nil
+}
+
+// columnNames returns the column names of rows in order
+func columnNames(rows pgx.Rows) []string {
+	colDescs := rows.FieldDescriptions()
+	columns := make([]string, len(colDescs))
+	for i, colDesc := range colDescs {
+		columns[i] = string(colDesc.Name)
+	}
+	return columns
+}
+
+// scanRowMap scans the current row into a map converted to native types.
+// decode sets how they are converted.
+func scanRowMap(rows pgx.Rows, columns []string, decode decodeOptions) (map[string]interface{}, error) {
+	columnData := make([]interface{}, len(columns))
+	if err := scanValues(rows, columnData, decode); err != nil {
+		return nil, err
+	}
+
+	entry := make(map[string]interface{}, len(columns))
+	for i, colName := range columns {
+		val := scannedValue(rows, i, columnData[i], decode)
+		if native, ok := toNativeValue(val, decode); ok || decode.keepNulls {
+			entry[colName] = native
+		}
+	}
+
+	return entry, nil
+}
+
 func IsPoolConnected(pool *pgxpool.Pool) bool {
 	ctx := context.Background()
 	err := pool.Ping(ctx)
@@ -178,49 +434,477 @@ func generateRandomNumber(min, max int) int {
 	return rnd.Intn(max-min+1) + min
 }
 
+// generateUniqueTempTableName returns a new staging table name for table,
+// without its schema
 func generateUniqueTempTableName(table string) string {
 	uniqueID := uuid.New()
 	// Remove hyphens from the UUID string
 	cleanedUUID := strings.ReplaceAll(uniqueID.String(), "-", "")
-	return fmt.Sprintf("temp_%s_%s", table, cleanedUUID)
+	name := tableIdentifier(table)
+	return fmt.Sprintf("temp_%s_%s", name[len(name)-1], cleanedUUID)
+}
+
+// BulkOption configures optional behavior of InsertBulkData
+type BulkOption func(*bulkOptions)
+
+// bulkOptions holds the settings applied by BulkOption values
+type bulkOptions struct {
+	chunkSize       int
+	onChunk         func(ChunkProgress)
+	continueOnError bool
+	timeColumns     map[string]string // "timestamp" or "date" per column
+	detectTypes     bool
+	conflictAction  ConflictAction
+	copyWorkers     int
+	updateColumns   []string
+	columns         []string
+	schemaColumns   bool
+	conflictOn      string
+	conflictWhere   string
+	updateWhere     string
+	skipUnchanged   bool
+	nullPolicy      NullPolicy
+	dedup           DedupStrategy
+	schema          *columnSchema // Set by prepareBulk with WithColumnTypeDetection
+	progress        *progressCounter
+	result          *resultCollector // Set by InsertBulkDataResult
+	dryRun          *[]string
+	backend         UpsertBackend
+	deleteMissing   bool
+	partitions      PartitionInterval
+	isolation       RowIsolation
+	rounding        map[string]Rounding
+	columnTypes     ColumnTypes
+
+	stagingUnlogged     bool
+	stagingOnCommitDrop bool
+	stagingTablespace   string
+}
+
+// ConflictAction is what InsertBulkData does with a row whose primary key already exists
+type ConflictAction int
+
+const (
+	// DoUpdate overwrites the existing row with the new values (the default)
+	DoUpdate ConflictAction = iota
+	// DoNothing keeps the existing row and skips the new one
+	DoNothing
+)
+
+// ChunkProgress describes the outcome of a single chunk written by InsertBulkData
+type ChunkProgress struct {
+	Chunk     int   // Zero-based index of the chunk
+	Chunks    int   // Total number of chunks
+	Rows      int   // Rows in this chunk
+	RowsDone  int   // Rows processed so far, including this chunk
+	TotalRows int   // Rows passed to InsertBulkData
+	Err       error // Error returned by the chunk, nil on success
+}
+
+// ChunkError is returned when a chunk fails to insert. Rows [Start, End) of the
+// input belong to the failed chunk and were rolled back; other chunks are unaffected.
+type ChunkError struct {
+	Chunk int
+	Start int
+	End   int
+	Err   error
+}
+
+// Error implements the error interface
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d (rows %d-%d) failed: %v", e.Chunk, e.Start, e.End-1, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// WithChunkSize splits the input into chunks of at most size rows, each one
+// staged and merged in its own transaction, so the converted copy of the rows,
+// the temporary table and the WAL of a transaction stay bounded by size on
+// multi-million row loads. A size <= 0 disables chunking. When chunking, the
+// timeout passed to InsertBulkData applies to each chunk; see
+// WithContinueOnError for what happens when a chunk fails.
+func WithChunkSize(size int) BulkOption {
+	return func(o *bulkOptions) {
+		o.chunkSize = size
+	}
+}
+
+// WithBatchSize loads the input in batches of at most size rows, each one in
+// its own staging table and transaction; it is WithChunkSize, under the name
+// used by other bulk loaders. By default the load stops at the first failed
+// batch, keeping the batches committed before it; WithContinueOnError loads
+// the remaining batches and reports every failure.
+func WithBatchSize(size int) BulkOption {
+	return WithChunkSize(size)
+}
+
+// WithChunkProgress registers a callback invoked after every chunk, successful or not
+func WithChunkProgress(fn func(ChunkProgress)) BulkOption {
+	return func(o *bulkOptions) {
+		o.onChunk = fn
+	}
+}
+
+// WithContinueOnError keeps processing the remaining chunks after a chunk fails.
+// All chunk failures are joined into the returned error. By default
+// InsertBulkData stops at the first failed chunk; chunks committed before it are kept.
+func WithContinueOnError() BulkOption {
+	return func(o *bulkOptions) {
+		o.continueOnError = true
+	}
+}
+
+// WithTimestampColumns declares columns of type timestamp without time zone.
+// time.Time values for these columns are written as their wall-clock time in
+// the value's own location instead of being converted through timestamptz.
+func WithTimestampColumns(columns ...string) BulkOption {
+	return func(o *bulkOptions) {
+		o.setTimeColumns("timestamp", columns)
+	}
+}
+
+// WithDateColumns declares columns of type date. time.Time values for these
+// columns are written as their calendar date in the value's own location, and
+// strings are parsed as "2006-01-02".
+func WithDateColumns(columns ...string) BulkOption {
+	return func(o *bulkOptions) {
+		o.setTimeColumns("date", columns)
+	}
+}
+
+// setTimeColumns records columns as being of the time type typ
+func (o *bulkOptions) setTimeColumns(typ string, columns []string) {
+	if o.timeColumns == nil {
+		o.timeColumns = make(map[string]string, len(columns))
+	}
+	for _, col := range columns {
+		o.timeColumns[col] = typ
+	}
+}
+
+// WithColumnTypeDetection looks up the target table's column types before
+// inserting and converts every value to the type of its column instead of
+// guessing from the Go value: numeric strings go to numeric columns without
+// losing precision, strings are parsed for timestamp, date and uuid columns,
+// integers are range-checked, and so on. A value that cannot be converted
+// fails with a *ColumnTypeError before its rows are copied, and a key that is
+// not a column of the table fails the call up front. Timestamp without time
+// zone columns are treated as if passed to WithTimestampColumns. This costs one
+// extra query per call.
+func WithColumnTypeDetection() BulkOption {
+	return func(o *bulkOptions) {
+		o.detectTypes = true
+	}
+}
+
+// WithConflictAction sets what happens to rows whose primary key already
+// exists. DoNothing skips them, which suits append-only event ingestion
+// where replayed events must be ignored; with an empty primaryKey it skips
+// rows conflicting with any unique constraint.
+func WithConflictAction(action ConflictAction) BulkOption {
+	return func(o *bulkOptions) {
+		o.conflictAction = action
+	}
+}
+
+// WithUpdateColumns restricts the SET clause of the ON CONFLICT update to
+// columns, so existing rows only get those columns overwritten while new rows
+// are still inserted with every column. Each column must be present in the data.
+func WithUpdateColumns(columns ...string) BulkOption {
+	return func(o *bulkOptions) {
+		o.updateColumns = append(o.updateColumns, columns...)
+	}
+}
+
+// WithConflictConstraint makes the named unique or exclusion constraint the
+// conflict target, as ON CONFLICT ON CONSTRAINT name, instead of primaryKey
+func WithConflictConstraint(name string) BulkOption {
+	return func(o *bulkOptions) {
+		o.conflictOn = name
+	}
+}
+
+// WithConflictWhere adds predicate to the ON CONFLICT (primaryKey) target so it
+// matches a partial unique index, e.g. "deleted_at IS NULL"
+func WithConflictWhere(predicate string) BulkOption {
+	return func(o *bulkOptions) {
+		o.conflictWhere = predicate
+	}
+}
+
+// WithUpdateWhere only updates an existing row when predicate holds, adding it
+// as the WHERE of DO UPDATE. The new values are EXCLUDED.column and the
+// existing ones are qualified with the table name, e.g.
+// "EXCLUDED.updated_at > trades.updated_at" so stale replays never overwrite
+// newer rows. Rows that fail the predicate are left as they are.
+func WithUpdateWhere(predicate string) BulkOption {
+	return func(o *bulkOptions) {
+		o.updateWhere = predicate
+	}
+}
+
+// WithSkipUnchanged leaves existing rows whose updated columns already hold
+// the new values untouched, instead of rewriting them. Identical rows then
+// produce no dead tuples and fire no update triggers, which keeps autovacuum
+// quiet on repeated full reloads. It combines with WithUpdateWhere.
+func WithSkipUnchanged() BulkOption {
+	return func(o *bulkOptions) {
+		o.skipUnchanged = true
+	}
 }
 
 // InsertBulkData inserts data in bulk into a PostgreSQL table with ON CONFLICT UPDATE clause
-func InsertBulkData(ctx context.Context, data []map[string]interface{}, table string, primaryKey []string, timeout time.Duration) error {
+func InsertBulkData(ctx context.Context, data []map[string]interface{}, table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) error {
+	return defaultDB().InsertBulkData(ctx, data, table, primaryKey, timeout, opts...)
+}
+
+// InsertBulkData inserts data in bulk into table with ON CONFLICT UPDATE clause.
+// The table, which may be schema-qualified as "schema.table", and the column
+// names are quoted in the generated SQL, so they must match the catalog exactly.
+func (d *DB) InsertBulkData(ctx context.Context, data []map[string]interface{}, table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) error {
 	if len(data) == 0 {
 		return nil
 	}
 
+	call, _ := d.startCall(ctx, OpBulk, "", nil)
+	defer call.done()
+	call.setTable(table, len(data))
+
+	copied, err := d.insertBulkData(call.ctx, data, table, primaryKey, timeout, opts)
+	call.finish(copied, err)
+	return err
+}
+
+// insertBulkData runs InsertBulkData and returns the number of rows loaded
+func (d *DB) insertBulkData(ctx context.Context, data []map[string]interface{}, table string, primaryKey []string, timeout time.Duration, opts []BulkOption) (int, error) {
+	options := &bulkOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	columns, err := d.bulkColumns(ctx, table, data[0], options)
+	if err != nil {
+		return 0, err
+	}
+	if err := validateRows(data, columns, options.nullPolicy); err != nil {
+		return 0, err
+	}
+	if err := options.checkDeleteMissing(len(data)); err != nil {
+		return 0, err
+	}
+
+	if err := d.prepareBulk(ctx, table, columns, options); err != nil {
+		return 0, err
+	}
+
+	copied := 0
+	err = insertChunks(ctx, len(data), options, func(start, end int) error {
+		n, err := d.insertBulkChunk(ctx, data[start:end], start, columns, table, primaryKey, timeout, options)
+		copied += n
+		return err
+	})
+	return copied, err
+}
+
+// prepareBulk checks options against columns and resolves the column types
+// of table when WithColumnTypeDetection is set
+func (d *DB) prepareBulk(ctx context.Context, table string, columns []string, options *bulkOptions) error {
+	for _, col := range options.updateColumns {
+		if !contains(columns, col) {
+			return fmt.Errorf("db: update column %s is not in the data", col)
+		}
+	}
+
+	if options.detectTypes || options.nullPolicy == NullDefault {
+		schema, err := d.columnSchema(ctx, table)
+		if err != nil {
+			return fmt.Errorf("error detecting column types of %s: %v", table, err)
+		}
+		for _, col := range columns {
+			if _, ok := schema.types[col]; !ok {
+				return fmt.Errorf("db: %s has no column %s", table, col)
+			}
+		}
+
+		options.schema = schema
+		for col, typ := range schema.types {
+			if typ == "timestamp" || typ == "date" {
+				options.setTimeColumns(typ, []string{col})
+			}
+		}
+	}
+
+	return nil
+}
+
+// insertChunks splits total rows into chunks of the configured size and calls
+// load for each one, reporting progress and collecting errors as set by options
+func insertChunks(ctx context.Context, total int, options *bulkOptions, load func(start, end int) error) error {
+	chunkSize := options.chunkSize
+	if chunkSize <= 0 || chunkSize > total {
+		chunkSize = total
+	}
+	chunks := (total + chunkSize - 1) / chunkSize
+
+	var errs []error
+	for i := 0; i < chunks; i++ {
+		start := i * chunkSize
+		end := start + chunkSize
+		if end > total {
+			end = total
+		}
+
+		// Don't start a new chunk once the caller gave up
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		err := load(start, end)
+		if err != nil && chunks > 1 {
+			err = &ChunkError{Chunk: i, Start: start, End: end, Err: err}
+		}
+
+		if options.onChunk != nil {
+			options.onChunk(ChunkProgress{
+				Chunk:     i,
+				Chunks:    chunks,
+				Rows:      end - start,
+				RowsDone:  end,
+				TotalRows: total,
+				Err:       err,
+			})
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+			if !options.continueOnError {
+				break
+			}
+		}
+	}
+
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// insertBulkChunk stages a single chunk in a temporary table and merges it into
+// table in one transaction. It returns the number of rows of the chunk that
+// were committed: all of them, or with WithRowIsolation those that were good.
+func (d *DB) insertBulkChunk(ctx context.Context, data []map[string]interface{}, offset int, columns []string, table string, primaryKey []string, timeout time.Duration, options *bulkOptions) (int, error) {
 	// Create a new context with timeout
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	columns := getColumns(data)
+	// Convert the values before inserting
+	formatted, err := formatBulkRows(data, offset, columns, options)
+	if err != nil {
+		return 0, err
+	}
 
-	// Format timestamps before inserting
-	data = formatTimestamps(data, columns)
+	// Drop rows with a duplicate primary key
+	rows := len(data)
+	data, keep, err := dedupMaps(data, formatted, offset, primaryKey, options.dedupFor(primaryKey))
+	if err != nil {
+		return 0, err
+	}
 
-	data = formatToBinaryData(data, columns)
+	if workers := options.workersFor(len(data)); workers > 1 {
+		err = d.parallelStageAndMerge(ctxWithTimeout, data, columns, table, primaryKey, workers, options)
+	} else {
+		_, err = d.mergeInTx(ctxWithTimeout, table, columns, primaryKey, newMapCopyFromSource(data, columns), options)
+	}
 
-	// Begin the transaction
-	tx, err := Pool.Begin(ctxWithTimeout)
+	if err != nil && options.isolation != IsolateNone && !options.deleteMissing && isRowError(err) {
+		return d.isolateBadRows(ctxWithTimeout, data, inputRows(keep, offset, len(data)), err, columns, table, primaryKey, options)
+	}
 	if err != nil {
-		return err
+		return 0, err
+	}
+	return rows, nil
+}
+
+// mergeInTx runs stageAndMerge in a transaction of its own and commits it
+func (d *DB) mergeInTx(ctx context.Context, table string, columns []string, primaryKey []string, src pgx.CopyFromSource, options *bulkOptions) (int64, error) {
+	ctx, span := d.startSpan(ctx, "transaction")
+	span.SetAttribute("db.sql.table", table)
+
+	copied, err := d.mergeTx(ctx, table, columns, primaryKey, src, options)
+	span.SetAttribute("db.rows_affected", copied)
+	span.End(err)
+	return copied, err
+}
+
+// mergeTx is mergeInTx without the span
+func (d *DB) mergeTx(ctx context.Context, table string, columns []string, primaryKey []string, src pgx.CopyFromSource, options *bulkOptions) (int64, error) {
+	if options.dryRun != nil {
+		return 0, d.dryRunMerge(ctx, table, columns, primaryKey, options)
+	}
+
+	options.result.begin()
+
+	// Begin the transaction; most of its time is spent acquiring the connection
+	start := time.Now()
+	tx, err := d.Pool().Begin(ctx)
+	addPoolWait(ctx, start)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	copied, err := d.stageAndMerge(ctx, tx, table, columns, primaryKey, src, options)
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
 	}
-	defer tx.Rollback(ctxWithTimeout)
+	options.result.commit()
 
+	return copied, nil
+}
+
+// stageAndMerge copies src into a new temporary table shaped like table and
+// merges it into table with ON CONFLICT, returning the number of rows copied
+func (d *DB) stageAndMerge(ctx context.Context, tx pgx.Tx, table string, columns []string, primaryKey []string, src pgx.CopyFromSource, options *bulkOptions) (int64, error) {
 	tempTable := generateUniqueTempTableName(table)
 
 	// Create a temporary table
-	_, err = tx.Exec(ctxWithTimeout, fmt.Sprintf("CREATE TEMPORARY TABLE %s AS TABLE %s WITH NO DATA", tempTable, table))
+	create := buildStagingTable(tempTable, table, options.stagingUnlogged, options.stagingOnCommitDrop, options.stagingTablespace)
+	_, err := tx.Exec(ctx, d.tagSQL(ctx, create))
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	dataToInsert := newMapCopyFromSource(data, columns)
+	copied, err := d.copyAndMerge(ctx, tx, table, tempTable, columns, primaryKey, src, options)
+	if err != nil {
+		return copied, err
+	}
 
+	// An unlogged staging table is a regular table, drop it with the transaction
+	if options.stagingUnlogged {
+		if _, err := tx.Exec(ctx, d.tagSQL(ctx, "DROP TABLE "+quoteIdent(tempTable))); err != nil {
+			return copied, err
+		}
+	}
+
+	return copied, nil
+}
+
+// copyAndMerge copies src into the existing staging table tempTable and
+// merges it into table with ON CONFLICT, returning the number of rows copied
+func (d *DB) copyAndMerge(ctx context.Context, tx pgx.Tx, table, tempTable string, columns []string, primaryKey []string, src pgx.CopyFromSource, options *bulkOptions) (int64, error) {
 	// Copy data into the temporary table using the COPY command
-	_, err = tx.CopyFrom(ctxWithTimeout, pgx.Identifier{tempTable}, columns, dataToInsert)
+	start := time.Now()
+	copied, err := tx.CopyFrom(ctx, pgx.Identifier{tempTable}, columns, withProgress(src, options))
+	options.result.copied(copied, time.Since(start))
 
 	if err != nil {
 		log.Printf("Error during COPY operation: %v", err)
@@ -230,32 +914,119 @@ func InsertBulkData(ctx context.Context, data []map[string]interface{}, table st
 			log.Printf("Error details:\n%s\n", pgErr.Error())
 			// ... (rest of the error details extraction)
 		}
-		return err
+		return copied, err
 	}
 
-	// Construct the final INSERT statement with ON CONFLICT UPDATE
-	insertStmt := fmt.Sprintf("INSERT INTO %s (%s) SELECT DISTINCT %s FROM %s ON CONFLICT (%s) DO UPDATE SET %s",
-		table,
-		strings.Join(columns, ", "),
-		strings.Join(columns, ", "),
-		tempTable,
-		strings.Join(primaryKey, ", "),
-		buildUpdateValuesWithExcluded(columns, primaryKey),
-	)
-
 	// Execute the final INSERT statement
-	_, err = tx.Exec(ctxWithTimeout, insertStmt)
+	err = d.runMerge(ctx, tx, table, tempTable, columns, primaryKey, options)
 	if err != nil {
-		return err
+		return copied, err
 	}
 
-	// Commit the transaction
-	err = tx.Commit(ctxWithTimeout)
-	if err != nil {
-		return err
+	return copied, nil
+}
+
+// buildMergeStatement constructs the final INSERT statement with its ON CONFLICT clause
+func buildMergeStatement(table, tempTable string, columns []string, primaryKey []string, options *bulkOptions) string {
+	insert := fmt.Sprintf("INSERT INTO %s (%s) SELECT DISTINCT %s FROM %s",
+		quoteTable(table),
+		strings.Join(quoteIdents(columns), ", "),
+		strings.Join(buildSelectList(columns, options), ", "),
+		quoteIdent(tempTable),
+	)
+
+	target := buildConflictTarget(primaryKey, options)
+
+	if options.conflictAction == DoNothing {
+		if target == "" {
+			return insert + " ON CONFLICT DO NOTHING"
+		}
+		return fmt.Sprintf("%s ON CONFLICT %s DO NOTHING", insert, target)
 	}
 
-	return nil
+	updateColumns := updateColumnsFor(columns, options)
+
+	merge := fmt.Sprintf("%s ON CONFLICT %s DO UPDATE SET %s",
+		insert,
+		target,
+		buildUpdateValuesWithExcluded(updateColumns, primaryKey),
+	)
+
+	if conditions := buildUpdateConditions(table, updateColumns, primaryKey, options); len(conditions) > 0 {
+		merge += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return merge
+}
+
+// updateColumnsFor returns the columns an existing row gets updated with
+func updateColumnsFor(columns []string, options *bulkOptions) []string {
+	if len(options.updateColumns) > 0 {
+		return options.updateColumns
+	}
+	return columns
+}
+
+// buildUpdateConditions returns the conditions an existing row must meet to be updated
+func buildUpdateConditions(table string, updateColumns []string, primaryKey []string, options *bulkOptions) []string {
+	var conditions []string
+	if options.updateWhere != "" {
+		conditions = append(conditions, "("+options.updateWhere+")")
+	}
+	if options.skipUnchanged {
+		if changed := buildChangedCondition(table, updateColumns, primaryKey); changed != "" {
+			conditions = append(conditions, changed)
+		}
+	}
+	return conditions
+}
+
+// buildChangedCondition returns the condition that the updated columns of the
+// existing row differ from the new values, or "" when only keys are loaded
+func buildChangedCondition(table string, columns []string, primaryKey []string) string {
+	var existing, excluded []string
+	for _, col := range columns {
+		if !contains(primaryKey, col) {
+			existing = append(existing, quoteTable(table)+"."+quoteIdent(col))
+			excluded = append(excluded, "EXCLUDED."+quoteIdent(col))
+		}
+	}
+	if len(existing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("(%s) IS DISTINCT FROM (%s)", strings.Join(existing, ", "), strings.Join(excluded, ", "))
+}
+
+// buildSelectList returns the expressions selected from the staging table,
+// which fill in the column defaults for NULLs under NullDefault
+func buildSelectList(columns []string, options *bulkOptions) []string {
+	selected := quoteIdents(columns)
+	if options.nullPolicy != NullDefault || options.schema == nil {
+		return selected
+	}
+
+	for i, col := range columns {
+		if def, ok := options.schema.defaults[col]; ok {
+			selected[i] = fmt.Sprintf("COALESCE(%s, %s) AS %s", selected[i], def, selected[i])
+		}
+	}
+	return selected
+}
+
+// buildConflictTarget returns the conflict target of the ON CONFLICT clause,
+// or "" when there is none
+func buildConflictTarget(primaryKey []string, options *bulkOptions) string {
+	if options.conflictOn != "" {
+		return "ON CONSTRAINT " + quoteIdent(options.conflictOn)
+	}
+	if len(primaryKey) == 0 {
+		return ""
+	}
+
+	target := "(" + strings.Join(quoteIdents(primaryKey), ", ") + ")"
+	if options.conflictWhere != "" {
+		target += " WHERE " + options.conflictWhere
+	}
+	return target
 }
 
 // ...
@@ -265,64 +1036,22 @@ func buildUpdateValuesWithExcluded(columns []string, primaryKey []string) string
 	for _, col := range columns {
 		// Exclude primary key columns from the update
 		if !contains(primaryKey, col) {
-			updateAssignments = append(updateAssignments, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
+			updateAssignments = append(updateAssignments, fmt.Sprintf("%s = EXCLUDED.%s", quoteIdent(col), quoteIdent(col)))
 		}
 	}
 	return strings.Join(updateAssignments, ", ")
 }
 
-func formataToNativeType(data []map[string]interface{}) []map[string]interface{} {
+// formataToNativeType converts every value of data to its native Go type as
+// set by decode. Values that are not present are dropped, or kept as nil with keepNulls.
+func formataToNativeType(data []map[string]interface{}, decode decodeOptions) []map[string]interface{} {
 	newData := make([]map[string]interface{}, len(data))
 
 	for i, row := range data {
 		newRow := make(map[string]interface{}, len(row))
 		for col, value := range row {
-			switch v := value.(type) {
-			case pgtype.Timestamptz:
-				if v.Status == pgtype.Present {
-					newRow[col] = v.Time
-				}
-			case pgtype.Float8:
-				if v.Status == pgtype.Present {
-					newRow[col] = v.Float
-				}
-			case pgtype.Int4:
-				if v.Status == pgtype.Present {
-					newRow[col] = int(v.Int)
-				}
-			case pgtype.Bool:
-				if v.Status == pgtype.Present {
-					newRow[col] = v.Bool
-				}
-			case pgtype.Text:
-				if v.Status == pgtype.Present {
-					newRow[col] = v.String
-				}
-			case pgtype.Numeric:
-				if v.Status == pgtype.Present {
-					// Convert the Numeric value to a decimal.Decimal
-					decimalVal, err := v.Value()
-					if err != nil {
-						// Handle the error
-						fmt.Printf("Error converting Numeric to decimal.Decimal: %v\n", err)
-						continue
-					}
-
-					// Convert driver.Value (string) to decimal.Decimal
-					decimalValue, err := decimal.NewFromString(decimalVal.(string))
-					if err != nil {
-						// Handle the error
-						fmt.Printf("Error converting string to decimal.Decimal: %v\n", err)
-						continue
-					}
-
-					// Convert decimal.Decimal to float64
-					floatVal, _ := decimalValue.Float64()
-					newRow[col] = floatVal
-				}
-
-			default:
-				newRow[col] = value
+			if native, ok := toNativeValue(value, decode); ok || decode.keepNulls {
+				newRow[col] = native
 			}
 		}
 		newData[i] = newRow
@@ -331,38 +1060,249 @@ func formataToNativeType(data []map[string]interface{}) []map[string]interface{}
 	return newData
 }
 
-func formatToBinaryData(data []map[string]interface{}, columnOrder []string) []map[string]interface{} {
+// toNativeValue converts a single scanned value to its native Go type.
+// It returns false when the value is a pgtype value that is not present.
+func toNativeValue(value interface{}, decode decodeOptions) (interface{}, bool) {
+	if decode.raw {
+		return value, true
+	}
+	switch v := value.(type) {
+	case pgtype.JSON:
+		if v.Status == pgtype.Present {
+			return nativeJSON(v.Bytes, decode.json)
+		}
+	case pgtype.JSONB:
+		if v.Status == pgtype.Present {
+			return nativeJSON(v.Bytes, decode.json)
+		}
+	case map[string]pgtype.Text:
+		return nativeHstore(v), true
+	case compositeRow:
+		return nativeComposite(v, decode), true
+	case pgtype.Hstore:
+		if v.Status == pgtype.Present {
+			return nativeHstore(v.Map), true
+		}
+	case pgtype.Timestamptz:
+		if v.Status == pgtype.Present {
+			return decode.inZone(v.Time), true
+		}
+	case pgtype.Float8:
+		if v.Status == pgtype.Present {
+			return v.Float, true
+		}
+	case pgtype.Int4:
+		if v.Status == pgtype.Present {
+			return int(v.Int), true
+		}
+	case pgtype.Bool:
+		if v.Status == pgtype.Present {
+			return v.Bool, true
+		}
+	case pgtype.Inet:
+		if v.Status == pgtype.Present {
+			return v.IPNet, true
+		}
+	case pgtype.Tstzrange:
+		if v.Status == pgtype.Present {
+			return nativeRange(v.LowerType, v.UpperType,
+				func() time.Time { return decode.inZone(v.Lower.Time) },
+				func() time.Time { return decode.inZone(v.Upper.Time) }), true
+		}
+	case pgtype.Tsrange:
+		if v.Status == pgtype.Present {
+			return nativeRange(v.LowerType, v.UpperType,
+				func() time.Time { return decode.inLocation(v.Lower.Time) },
+				func() time.Time { return decode.inLocation(v.Upper.Time) }), true
+		}
+	case pgtype.Daterange:
+		if v.Status == pgtype.Present {
+			return nativeRange(v.LowerType, v.UpperType,
+				func() time.Time { return decode.inLocation(v.Lower.Time) },
+				func() time.Time { return decode.inLocation(v.Upper.Time) }), true
+		}
+	case pgtype.Int4range:
+		if v.Status == pgtype.Present {
+			return nativeRange(v.LowerType, v.UpperType,
+				func() int32 { return v.Lower.Int },
+				func() int32 { return v.Upper.Int }), true
+		}
+	case pgtype.Int8range:
+		if v.Status == pgtype.Present {
+			return nativeRange(v.LowerType, v.UpperType,
+				func() int64 { return v.Lower.Int },
+				func() int64 { return v.Upper.Int }), true
+		}
+	case pgtype.Numrange:
+		if v.Status == pgtype.Present {
+			return nativeNumrange(v, decode.numeric), true
+		}
+	case pgtype.Macaddr:
+		if v.Status == pgtype.Present {
+			return v.Addr, true
+		}
+	case pgtype.Text:
+		if v.Status == pgtype.Present {
+			return v.String, true
+		}
+	case pgtype.Timestamp:
+		if v.Status == pgtype.Present {
+			if v.InfinityModifier != pgtype.None {
+				return v.InfinityModifier.String(), true
+			}
+			return decode.inLocation(v.Time), true
+		}
+	case pgtype.Date:
+		if v.Status == pgtype.Present {
+			if v.InfinityModifier != pgtype.None {
+				return v.InfinityModifier.String(), true
+			}
+			return decode.inLocation(v.Time), true
+		}
+	case pgtype.Interval:
+		if v.Status == pgtype.Present {
+			return nativeInterval(v), true
+		}
+	case pgtype.InfinityModifier:
+		// Infinite dates and timestamps scan to their modifier
+		return v.String(), true
+	case pgtype.UUID:
+		if v.Status == pgtype.Present {
+			return uuid.UUID(v.Bytes), true
+		}
+	case [16]byte:
+		// uuid columns scan to their bytes
+		return uuid.UUID(v), true
+	case pgtype.TextArray:
+		if v.Status == pgtype.Present {
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(""))
+		}
+	case pgtype.VarcharArray:
+		if v.Status == pgtype.Present {
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(""))
+		}
+	case pgtype.Int2Array:
+		if v.Status == pgtype.Present {
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(int16(0)))
+		}
+	case pgtype.Int4Array:
+		if v.Status == pgtype.Present {
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(0))
+		}
+	case pgtype.Int8Array:
+		if v.Status == pgtype.Present {
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(int64(0)))
+		}
+	case pgtype.Float4Array:
+		if v.Status == pgtype.Present {
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(float32(0)))
+		}
+	case pgtype.Float8Array:
+		if v.Status == pgtype.Present {
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(float64(0)))
+		}
+	case pgtype.NumericArray:
+		if v.Status == pgtype.Present {
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(float64(0)))
+		}
+	case pgtype.BoolArray:
+		if v.Status == pgtype.Present {
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(false))
+		}
+	case pgtype.TimestamptzArray:
+		if v.Status == pgtype.Present {
+			for i := range v.Elements {
+				v.Elements[i].Time = decode.inZone(v.Elements[i].Time)
+			}
+			return nativeArray(&v, len(v.Dimensions), reflect.TypeOf(time.Time{}))
+		}
+	case pgtype.Numeric:
+		if v.Status == pgtype.Present {
+			if decode.numeric != NumericFloat {
+				return exactNumeric(v, decode.numeric), true
+			}
+
+			// Convert the Numeric value to a decimal.Decimal
+			decimalVal, err := v.Value()
+			if err != nil {
+				// Handle the error
+				fmt.Printf("Error converting Numeric to decimal.Decimal: %v\n", err)
+				return nil, false
+			}
+
+			// Convert driver.Value (string) to decimal.Decimal
+			decimalValue, err := decimal.NewFromString(decimalVal.(string))
+			if err != nil {
+				// Handle the error
+				fmt.Printf("Error converting string to decimal.Decimal: %v\n", err)
+				return nil, false
+			}
+
+			// Convert decimal.Decimal to float64
+			floatVal, _ := decimalValue.Float64()
+			return floatVal, true
+		}
+
+	default:
+		return value, true
+	}
+
+	return nil, false
+}
+
+// nativeArray assigns array, which has dims dimensions, to nested slices of
+// elem, one level per dimension. Arrays holding NULLs get pointer elements,
+// nil for the NULLs.
+func nativeArray(array pgtype.Value, dims int, elem reflect.Type) (interface{}, bool) {
+	if dims == 0 {
+		dims = 1
+	}
+
+	for _, elemType := range []reflect.Type{elem, reflect.PointerTo(elem)} {
+		sliceType := elemType
+		for i := 0; i < dims; i++ {
+			sliceType = reflect.SliceOf(sliceType)
+		}
+
+		dst := reflect.New(sliceType)
+		if err := array.AssignTo(dst.Interface()); err == nil {
+			return dst.Elem().Interface(), true
+		}
+	}
+
+	// Keep the pgtype value when it fits neither
+	return array.Get(), true
+}
+
+// exactNumeric returns v as a decimal.Decimal or, with NumericString or when
+// it is NaN or infinite, as its text
+func exactNumeric(v pgtype.Numeric, mode NumericMode) interface{} {
+	switch {
+	case v.NaN:
+		return "NaN"
+	case v.InfinityModifier != pgtype.None:
+		return v.InfinityModifier.String()
+	}
+
+	value := decimal.Zero
+	if v.Int != nil {
+		value = decimal.NewFromBigInt(v.Int, v.Exp)
+	}
+	if mode == NumericString {
+		return value.String()
+	}
+	return value
+}
+
+// formatToBinaryData converts the values of data, whose first row is at index
+// offset of the input, to pgtype values
+func formatToBinaryData(data []map[string]interface{}, offset int, columnOrder []string, timeColumns map[string]string) ([]map[string]interface{}, error) {
 	newData := make([]map[string]interface{}, len(data))
 
 	for i, row := range data {
-		newRow := make(map[string]interface{}, len(row))
-		for _, col := range columnOrder {
-			switch v := row[col].(type) {
-			case time.Time:
-				newRow[col] = pgtype.Timestamptz{Time: v, Status: pgtype.Present}
-			case float64:
-				newRow[col] = pgtype.Float8{Float: v, Status: pgtype.Present}
-			case int:
-				newRow[col] = int32(v)
-			case bool:
-				//newRow[col] = boolToInt(v)
-				newRow[col] = pgtype.Bool{Bool: v, Status: pgtype.Present}
-			case string:
-				if col == "time" {
-					// Parse the string as time
-					t, err := time.Parse(time.RFC3339, v)
-					if err != nil {
-						// Handle the error
-						fmt.Printf("Error parsing time: %v\n", err)
-						continue
-					}
-					newRow[col] = pgtype.Timestamptz{Time: t, Status: pgtype.Present}
-				} else {
-					newRow[col] = pgtype.Text{String: v, Status: pgtype.Present}
-				}
-			default:
-				newRow[col] = row[col]
-			}
+		newRow, err := formatRowToBinary(offset+i, row, columnOrder, timeColumns)
+		if err != nil {
+			return nil, err
 		}
 		newData[i] = newRow
 	}
@@ -372,7 +1312,174 @@ func formatToBinaryData(data []map[string]interface{}, columnOrder []string) []m
 		fmt.Printf("Row: %+v\n", row)
 	}*/
 
-	return newData
+	return newData, nil
+}
+
+// formatRowToBinary converts the values of the row at index i of the input to
+// pgtype values. A value that cannot be encoded is reported as a
+// ColumnTypeError rather than loaded as NULL.
+func formatRowToBinary(i int, row map[string]interface{}, columnOrder []string, timeColumns map[string]string) (map[string]interface{}, error) {
+	newRow := make(map[string]interface{}, len(row))
+	for _, col := range columnOrder {
+		switch v := row[col].(type) {
+		case time.Time:
+			newRow[col] = timeValue(v, timeColumns[col])
+		case float64:
+			newRow[col] = pgtype.Float8{Float: v, Status: pgtype.Present}
+		case Range[time.Time], Range[int32], Range[int], Range[int64], Range[float64], Range[decimal.Decimal]:
+			encoded, _, err := rangeValue(v, "")
+			if err != nil {
+				return nil, &ColumnTypeError{Row: i, Column: col, Type: "range", Value: v, Err: err}
+			}
+			newRow[col] = encoded
+		case net.IP:
+			newRow[col] = inetValue(v)
+		case *net.IPNet:
+			newRow[col] = ipNetValue(v)
+		case net.IPNet:
+			newRow[col] = ipNetValue(&v)
+		case net.HardwareAddr:
+			newRow[col] = macaddrValue(v)
+		case uuid.UUID:
+			newRow[col] = pgtype.UUID{Bytes: v, Status: pgtype.Present}
+		case decimal.Decimal:
+			newRow[col] = pgtype.Numeric{Int: v.Coefficient(), Exp: v.Exponent(), Status: pgtype.Present}
+		case []byte:
+			if v == nil {
+				newRow[col] = pgtype.Bytea{Status: pgtype.Null}
+			} else {
+				newRow[col] = pgtype.Bytea{Bytes: v, Status: pgtype.Present}
+			}
+		case time.Duration:
+			newRow[col] = pgtype.Interval{Microseconds: v.Microseconds(), Status: pgtype.Present}
+		case Interval:
+			newRow[col] = intervalValue(v)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			integer, err := integerValue(v)
+			if err != nil {
+				return nil, &ColumnTypeError{Row: i, Column: col, Type: "int8", Value: v, Err: err}
+			}
+			newRow[col] = integer
+		case bool:
+			//newRow[col] = boolToInt(v)
+			newRow[col] = pgtype.Bool{Bool: v, Status: pgtype.Present}
+		case string:
+			if timeColumns[col] == "date" {
+				t, err := time.Parse("2006-01-02", v)
+				if err != nil {
+					return nil, &ColumnTypeError{Row: i, Column: col, Type: "date", Value: v, Err: err}
+				}
+				newRow[col] = timeValue(t, "date")
+			} else {
+				newRow[col] = pgtype.Text{String: v, Status: pgtype.Present}
+			}
+		case map[string]interface{}, []interface{}:
+			// Documents such as event payloads go to jsonb columns
+			var document pgtype.JSONB
+			if err := document.Set(v); err != nil {
+				return nil, &ColumnTypeError{Row: i, Column: col, Type: "jsonb", Value: v, Err: err}
+			}
+			newRow[col] = document
+		case map[string]*string, map[string]string:
+			var pairs pgtype.Hstore
+			if err := pairs.Set(v); err != nil {
+				return nil, &ColumnTypeError{Row: i, Column: col, Type: "hstore", Value: v, Err: err}
+			}
+			newRow[col] = pairs
+		case json.RawMessage:
+			if v == nil {
+				newRow[col] = pgtype.JSONB{Status: pgtype.Null}
+			} else {
+				newRow[col] = pgtype.JSONB{Bytes: v, Status: pgtype.Present}
+			}
+		case []string, [][]string, []int, [][]int, []int32, [][]int32, []int64, [][]int64, []float64, [][]float64, []bool, [][]bool:
+			array, err := arrayValue(v)
+			if err != nil {
+				return nil, &ColumnTypeError{Row: i, Column: col, Type: "array", Value: v, Err: err}
+			}
+			newRow[col] = array
+		default:
+			newRow[col] = row[col]
+		}
+	}
+	return newRow, nil
+}
+
+// arrayValue checks that v, a slice or a slice of slices, makes a valid array,
+// one that is not ragged, and returns it as it is. Like single integers, it
+// stays a plain value so pgx converts it to the array type of the target
+// column: []int64 loads into int4[] and []int into int8[] alike.
+func arrayValue(v interface{}) (interface{}, error) {
+	var array pgtype.Value
+	switch v.(type) {
+	case []string, [][]string:
+		array = &pgtype.TextArray{}
+	case []int, [][]int, []int32, [][]int32, []int64, [][]int64:
+		// The widest integer array, so no value is rejected before the column
+		// type is known
+		array = &pgtype.Int8Array{}
+	case []float64, [][]float64:
+		array = &pgtype.Float8Array{}
+	case []bool, [][]bool:
+		array = &pgtype.BoolArray{}
+	default:
+		return nil, fmt.Errorf("db: no array type for %T", v)
+	}
+
+	if err := array.Set(v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// integerValue converts v, a Go integer, to an int64. It stays a plain value
+// rather than a pgtype integer, so pgx encodes it in the width of the target
+// column, whichever that is, and reports values out of its range.
+func integerValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint:
+		return integerValue(uint64(v))
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("db: %d does not fit in a bigint", v)
+		}
+		return int64(v), nil
+	}
+	return nil, fmt.Errorf("db: %T is not an integer", v)
+}
+
+// timeValue converts t to the pgtype value of a column of type typ: a
+// timestamp, a date or, by default, a timestamptz
+func timeValue(t time.Time, typ string) interface{} {
+	switch typ {
+	case "timestamp":
+		return pgtype.Timestamp{Time: wallClockUTC(t), Status: pgtype.Present}
+	case "date":
+		return pgtype.Date{Time: time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), Status: pgtype.Present}
+	}
+	return pgtype.Timestamptz{Time: t, Status: pgtype.Present}
+}
+
+// wallClockUTC keeps the wall-clock reading of t but moves it to UTC, which is
+// how pgtype.Timestamp expects values for timestamp without time zone columns
+func wallClockUTC(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
 }
 
 // Helper function to convert bool to int
@@ -383,31 +1490,38 @@ func boolToInt(b bool) int32 {
 	return 0
 }
 
-// formatTimestamps formats timestamp values in the data to strings
+// formatTimestamps converts numeric strings in the data to float64.
+// time.Time values are left as they are, so they are copied as timestamptz, or as the
+// timestamp or date type their column was declared with, rather than as text.
 func formatTimestamps(data []map[string]interface{}, columnOrder []string) []map[string]interface{} {
 	newData := make([]map[string]interface{}, len(data))
 
 	for i, row := range data {
-		newRow := make(map[string]interface{}, len(row))
-		for _, col := range columnOrder {
-			if timestamp, ok := row[col].(time.Time); ok {
-				newRow[col] = timestamp.Format(time.RFC3339)
-			} else if strNum, ok := row[col].(string); ok {
-				// Try to convert string number to float64
-				if num, err := strconv.ParseFloat(strNum, 64); err == nil {
-					newRow[col] = num
-				} else {
-					// If conversion fails, keep the original string value
-					newRow[col] = row[col]
-				}
+		newData[i] = formatRowTimestamps(row, columnOrder)
+	}
+
+	return newData
+}
+
+// formatRowTimestamps applies formatTimestamps to a single row
+func formatRowTimestamps(row map[string]interface{}, columnOrder []string) map[string]interface{} {
+	newRow := make(map[string]interface{}, len(row))
+	for _, col := range columnOrder {
+		if _, ok := row[col].(time.Time); ok {
+			newRow[col] = row[col]
+		} else if strNum, ok := row[col].(string); ok {
+			// Try to convert string number to float64
+			if num, err := strconv.ParseFloat(strNum, 64); err == nil {
+				newRow[col] = num
 			} else {
+				// If conversion fails, keep the original string value
 				newRow[col] = row[col]
 			}
+		} else {
+			newRow[col] = row[col]
 		}
-		newData[i] = newRow
 	}
-
-	return newData
+	return newRow
 }
 
 // getColumns returns the columns as a slice of strings
@@ -416,11 +1530,12 @@ func getColumns(data []map[string]interface{}) []string {
 		return nil
 	}
 
-	// Use the keys of the first map as the order of columns
+	// Use the sorted keys of the first map, so the order is the same on every call
 	columns := make([]string, 0, len(data[0]))
 	for column := range data[0] {
 		columns = append(columns, column)
 	}
+	sort.Strings(columns)
 
 	return columns
 }
@@ -428,7 +1543,7 @@ func getColumns(data []map[string]interface{}) []string {
 // buildUpdateValues constructs the SET clause for ON CONFLICT UPDATE
 func buildUpdateValues(primaryKey []string, updateAssignments []string) string {
 	updateClause := fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s",
-		strings.Join(primaryKey, ", "),
+		strings.Join(quoteIdents(primaryKey), ", "),
 		strings.Join(updateAssignments, ", "),
 	)
 	return updateClause
@@ -444,43 +1559,70 @@ func contains(slice []string, item string) bool {
 	return false
 }
 
-// mapCopyFromSource is an implementation of pgx.CopyFromSource for a slice of maps
+// mapCopyFromSource is an implementation of pgx.CopyFromSource that pulls maps from an iterator
 type mapCopyFromSource struct {
-	data    []map[string]interface{}
-	pos     int
+	next    func() (map[string]interface{}, error) // Returns io.EOF once exhausted
+	row     map[string]interface{}
+	err     error
 	columns []string // Explicitly define the order of columns
 }
 
-// newMapCopyFromSource creates a new mapCopyFromSource
+// newMapCopyFromSource creates a new mapCopyFromSource over a slice of maps
 func newMapCopyFromSource(data []map[string]interface{}, columnsOrder []string) *mapCopyFromSource {
+	pos := 0
+	return newIteratorCopyFromSource(func() (map[string]interface{}, error) {
+		if pos >= len(data) {
+			return nil, io.EOF
+		}
+		row := data[pos]
+		pos++
+		return row, nil
+	}, columnsOrder)
+}
+
+// newIteratorCopyFromSource creates a new mapCopyFromSource that calls next for
+// every row, so rows never have to be materialized all at once
+func newIteratorCopyFromSource(next func() (map[string]interface{}, error), columnsOrder []string) *mapCopyFromSource {
 	return &mapCopyFromSource{
-		data:    data,
-		pos:     0,
+		next:    next,
 		columns: columnsOrder,
 	}
 }
 
 // Next implements the pgx.CopyFromSource interface
 func (m *mapCopyFromSource) Next() bool {
-	return m.pos < len(m.data)
+	if m.err != nil {
+		return false
+	}
+
+	row, err := m.next()
+	if err != nil {
+		if err != io.EOF {
+			m.err = err
+		}
+		m.row = nil
+		return false
+	}
+
+	m.row = row
+	return true
 }
 
 // Values implements the pgx.CopyFromSource interface
 func (m *mapCopyFromSource) Values() ([]interface{}, error) {
-	if !m.Next() {
+	if m.row == nil {
 		return nil, io.EOF
 	}
 
 	values := make([]interface{}, len(m.columns))
 	for i, col := range m.columns {
-		values[i] = m.data[m.pos][col]
+		values[i] = m.row[col]
 	}
 
-	m.pos++
 	return values, nil
 }
 
 // Err implements the pgx.CopyFromSource interface
 func (m *mapCopyFromSource) Err() error {
-	return nil
+	return m.err
 }
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/jackc/pgtype"
+)
+
+func TestIntegerValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"int", -3, int64(-3), false},
+		{"int8", int8(-5), int64(-5), false},
+		{"int16", int16(math.MaxInt16), int64(math.MaxInt16), false},
+		{"uint8", uint8(255), int64(255), false},
+		{"int32", int32(math.MinInt32), int64(math.MinInt32), false},
+		{"uint16", uint16(math.MaxUint16), int64(math.MaxUint16), false},
+		{"int64", int64(math.MaxInt64), int64(math.MaxInt64), false},
+		{"uint32", uint32(math.MaxUint32), int64(math.MaxUint32), false},
+		{"uint", uint(7), int64(7), false},
+		{"uint64 max bigint", uint64(math.MaxInt64), int64(math.MaxInt64), false},
+		{"uint64 overflow", uint64(math.MaxInt64) + 1, nil, true},
+		{"not an integer", "1", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := integerValue(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("integerValue(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("integerValue(%v) = %#v, want %#v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// encodeCopyValue encodes value for a column of type typ the way pgx's
+// CopyFrom does: a pgtype.BinaryEncoder encodes itself, anything else is set
+// into the DataType of the column first
+func encodeCopyValue(t *testing.T, value interface{}, typ string) ([]byte, error) {
+	t.Helper()
+	if encoder, ok := value.(pgtype.BinaryEncoder); ok {
+		return encoder.EncodeBinary(copyConnInfo, nil)
+	}
+
+	dt, ok := copyConnInfo.DataTypeForName(typ)
+	if !ok {
+		t.Fatalf("no data type %s", typ)
+	}
+	column := pgtype.NewValue(dt.Value)
+	if err := column.Set(value); err != nil {
+		return nil, err
+	}
+	return column.(pgtype.BinaryEncoder).EncodeBinary(copyConnInfo, nil)
+}
+
+func TestFormatRowToBinaryIntegersFitEveryIntegerColumn(t *testing.T) {
+	widths := map[string]int{"int2": 2, "int4": 4, "int8": 8}
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		typ     string
+		wantErr bool
+	}{
+		{"int64 into int4", int64(42), "int4", false},
+		{"int64 into int2", int64(-7), "int2", false},
+		{"int32 into int8", int32(42), "int8", false},
+		{"uint8 into int4", uint8(200), "int4", false},
+		{"int16 into int8", int16(3), "int8", false},
+		{"uint32 into int4", uint32(5), "int4", false},
+		{"int into int8", 1 << 40, "int8", false},
+		{"int into int2", 12, "int2", false},
+		{"int64 out of int4 range", int64(math.MaxInt32) + 1, "int4", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := formatRowToBinary(0, map[string]interface{}{"v": tt.value}, []string{"v"}, nil)
+			if err != nil {
+				t.Fatalf("formatRowToBinary: %v", err)
+			}
+
+			encoded, err := encodeCopyValue(t, row["v"], tt.typ)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encoding %T into %s: error = %v, wantErr %v", row["v"], tt.typ, err, tt.wantErr)
+			}
+			if !tt.wantErr && len(encoded) != widths[tt.typ] {
+				t.Errorf("%T encoded into %d bytes for %s, want %d", row["v"], len(encoded), tt.typ, widths[tt.typ])
+			}
+		})
+	}
+}
+
+func TestFormatRowToBinaryArraysFitTheColumnType(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		typ     string
+		wantErr bool
+	}{
+		{"[]int64 into int4[]", []int64{1, 2}, "_int4", false},
+		{"[]int into int8[]", []int{1 << 40, 2}, "_int8", false},
+		{"[]int32 into int2[]", []int32{1, 2}, "_int2", false},
+		{"[][]int64 into int4[]", [][]int64{{1, 2}, {3, 4}}, "_int4", false},
+		{"[]float64 into numeric[]", []float64{1.5}, "_numeric", false},
+		{"[]string into text[]", []string{"a", "b"}, "_text", false},
+		{"[]int64 out of int4 range", []int64{math.MaxInt32 + 1}, "_int4", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := formatRowToBinary(0, map[string]interface{}{"v": tt.value}, []string{"v"}, nil)
+			if err != nil {
+				t.Fatalf("formatRowToBinary: %v", err)
+			}
+
+			encoded, err := encodeCopyValue(t, row["v"], tt.typ)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encoding %T into %s: error = %v, wantErr %v", row["v"], tt.typ, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			// The binary array header is the dimensions, the null flag and the element OID
+			dt, _ := copyConnInfo.DataTypeForName(tt.typ[1:])
+			if len(encoded) < 12 || binary.BigEndian.Uint32(encoded[8:12]) != dt.OID {
+				t.Errorf("%T encoded for %s with the wrong element type: % x", row["v"], tt.typ, encoded)
+			}
+		})
+	}
+}
+
+func TestFormatRowToBinaryRejectsUnencodableValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       interface{}
+		timeColumns map[string]string
+		wantType    string
+	}{
+		{"uint64 overflow", uint64(math.MaxUint64), nil, "int8"},
+		{"unencodable JSON", map[string]interface{}{"f": func() {}}, nil, "jsonb"},
+		{"ragged array", [][]int{{1, 2}, {3}}, nil, "array"},
+		{"unparsable date", "14/10/2026", map[string]string{"v": "date"}, "date"},
+		{"invalid range bounds", Range[int64]{Lower: 1, Upper: 10, Bounds: "<>"}, nil, "range"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := map[string]interface{}{"id": 1, "v": tt.value}
+			got, err := formatRowToBinary(3, row, []string{"id", "v"}, tt.timeColumns)
+			if got != nil {
+				t.Errorf("formatRowToBinary returned a row for an unencodable value: %v", got)
+			}
+
+			var typeErr *ColumnTypeError
+			if !errors.As(err, &typeErr) {
+				t.Fatalf("formatRowToBinary error = %v, want a *ColumnTypeError", err)
+			}
+			if typeErr.Row != 3 || typeErr.Column != "v" || typeErr.Type != tt.wantType {
+				t.Errorf("ColumnTypeError = {Row: %d, Column: %s, Type: %s}, want {Row: 3, Column: v, Type: %s}",
+					typeErr.Row, typeErr.Column, typeErr.Type, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestFormatToBinaryDataOffsetsRows(t *testing.T) {
+	data := []map[string]interface{}{
+		{"v": uint64(1)},
+		{"v": uint64(math.MaxUint64)},
+	}
+	_, err := formatToBinaryData(data, 10, []string{"v"}, nil)
+
+	var typeErr *ColumnTypeError
+	if !errors.As(err, &typeErr) || typeErr.Row != 11 {
+		t.Fatalf("formatToBinaryData error = %v, want a *ColumnTypeError for row 11", err)
+	}
+}
+
+func TestWithBatchSize(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []BulkOption
+		want int
+	}{
+		{"batch size", []BulkOption{WithBatchSize(50000)}, 50000},
+		{"same as chunk size", []BulkOption{WithChunkSize(10), WithBatchSize(20)}, 20},
+		{"disabled", []BulkOption{WithBatchSize(0)}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &bulkOptions{}
+			for _, opt := range tt.opts {
+				opt(options)
+			}
+			if options.chunkSize != tt.want {
+				t.Errorf("chunkSize = %d, want %d", options.chunkSize, tt.want)
+			}
+		})
+	}
+}
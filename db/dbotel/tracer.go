@@ -0,0 +1,65 @@
+// Package dbotel traces the calls of a db.DB with OpenTelemetry. It lives in
+// a package of its own so that package db does not depend on OpenTelemetry.
+//
+//	err := db.InitDB(config, db.WithTracer(dbotel.NewTracer(otel.Tracer("db"))))
+package dbotel
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/siqueiraa/postgres-connect-go/db"
+)
+
+// NewTracer returns a db.Tracer starting client spans with tracer
+func NewTracer(tracer trace.Tracer) db.Tracer {
+	return otelTracer{tracer}
+}
+
+// otelTracer is the db.Tracer of NewTracer
+type otelTracer struct {
+	tracer trace.Tracer
+}
+
+// Start implements db.Tracer
+func (t otelTracer) Start(ctx context.Context, name string) (context.Context, db.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	return ctx, otelSpan{span}
+}
+
+// otelSpan is a db.Span backed by an OpenTelemetry span
+type otelSpan struct {
+	span trace.Span
+}
+
+// SetAttribute implements db.Span. Values of other types than the ones the
+// db package sets are recorded as strings.
+func (s otelSpan) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		s.span.SetAttributes(attribute.String(key, v))
+	case int64:
+		s.span.SetAttributes(attribute.Int64(key, v))
+	case int:
+		s.span.SetAttributes(attribute.Int(key, v))
+	case bool:
+		s.span.SetAttributes(attribute.Bool(key, v))
+	case float64:
+		s.span.SetAttributes(attribute.Float64(key, v))
+	default:
+		s.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+// End implements db.Span, recording err as the status of the span
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,72 @@
+package dbotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value interface{}
+		want  attribute.Value
+		err   error
+	}{
+		{"string", "db.statement", "SELECT 1", attribute.StringValue("SELECT 1"), nil},
+		{"int64", "db.rows_affected", int64(3), attribute.Int64Value(3), nil},
+		{"int", "db.rows_affected", 3, attribute.IntValue(3), nil},
+		{"bool", "db.cache_hit", true, attribute.BoolValue(true), nil},
+		{"float64", "ratio", 0.5, attribute.Float64Value(0.5), nil},
+		{"other", "db.sql.table", []string{"trades"}, attribute.StringValue("[trades]"), nil},
+		{"error", "db.statement", "SELECT 1", attribute.StringValue("SELECT 1"), errors.New("boom")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			tracer := NewTracer(provider.Tracer("test"))
+
+			_, span := tracer.Start(context.Background(), "db.fetch")
+			span.SetAttribute(tt.key, tt.value)
+			span.End(tt.err)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("got %d ended spans, want 1", len(spans))
+			}
+			got := spans[0]
+			if got.Name() != "db.fetch" || got.SpanKind() != trace.SpanKindClient {
+				t.Errorf("span %q of kind %v, want a client span db.fetch", got.Name(), got.SpanKind())
+			}
+
+			var found bool
+			for _, attr := range got.Attributes() {
+				if string(attr.Key) == tt.key {
+					found = true
+					if attr.Value != tt.want {
+						t.Errorf("%s = %v, want %v", tt.key, attr.Value.Emit(), tt.want.Emit())
+					}
+				}
+			}
+			if !found {
+				t.Errorf("span has no %s attribute", tt.key)
+			}
+
+			wantStatus := codes.Unset
+			if tt.err != nil {
+				wantStatus = codes.Error
+			}
+			if got.Status().Code != wantStatus {
+				t.Errorf("status = %v, want %v", got.Status().Code, wantStatus)
+			}
+		})
+	}
+}
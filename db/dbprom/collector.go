@@ -0,0 +1,140 @@
+// Package dbprom exports the Metrics of a db.DB to Prometheus. It lives in a
+// package of its own so that package db does not depend on the Prometheus
+// client.
+//
+//	metrics := db.NewMetrics()
+//	err := db.InitDB(config, db.WithMetrics(metrics))
+//	prometheus.MustRegister(dbprom.NewCollector(metrics))
+package dbprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/siqueiraa/postgres-connect-go/db"
+)
+
+// Option configures a Collector
+type Option func(*options)
+
+type options struct {
+	namespace   string
+	constLabels prometheus.Labels
+}
+
+// WithNamespace prefixes the metric names with namespace instead of "db"
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithConstLabels adds labels to every metric, e.g. to tell apart the
+// collectors of several databases registered together
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		o.constLabels = labels
+	}
+}
+
+// Collector is a prometheus.Collector reading a db.Metrics on every scrape.
+// Its metrics, with the default "db" namespace, are
+//
+//	db_pool_acquired_connections            gauge
+//	db_pool_idle_connections                gauge
+//	db_pool_total_connections               gauge
+//	db_pool_max_connections                 gauge
+//	db_pool_waiting_calls                   gauge
+//	db_pool_acquires_total                  counter
+//	db_pool_empty_acquires_total            counter
+//	db_pool_acquire_duration_seconds        counter
+//	db_operation_duration_seconds{op}       histogram
+//	db_operation_rows_total{op}             counter
+//	db_operation_errors_total{op,sqlstate}  counter
+//
+// where op is db.OpFetch, db.OpExec or db.OpBulk.
+type Collector struct {
+	metrics *db.Metrics
+
+	acquired        *prometheus.Desc
+	idle            *prometheus.Desc
+	total           *prometheus.Desc
+	max             *prometheus.Desc
+	waiting         *prometheus.Desc
+	acquires        *prometheus.Desc
+	emptyAcquires   *prometheus.Desc
+	acquireDuration *prometheus.Desc
+	duration        *prometheus.Desc
+	rows            *prometheus.Desc
+	errors          *prometheus.Desc
+}
+
+// NewCollector returns a Collector exporting m
+func NewCollector(m *db.Metrics, opts ...Option) *Collector {
+	o := &options{namespace: "db"}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	desc := func(subsystem, name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(o.namespace, subsystem, name), help, labels, o.constLabels)
+	}
+	return &Collector{
+		metrics:         m,
+		acquired:        desc("pool", "acquired_connections", "Connections of the pool in use."),
+		idle:            desc("pool", "idle_connections", "Idle connections of the pool."),
+		total:           desc("pool", "total_connections", "Connections of the pool, in use, idle or being opened."),
+		max:             desc("pool", "max_connections", "Maximum size of the pool."),
+		waiting:         desc("pool", "waiting_calls", "Calls waiting for a connection."),
+		acquires:        desc("pool", "acquires_total", "Connections acquired from the pool."),
+		emptyAcquires:   desc("pool", "empty_acquires_total", "Acquires that waited because the pool had no idle connection."),
+		acquireDuration: desc("pool", "acquire_duration_seconds", "Total time spent acquiring connections."),
+		duration:        desc("operation", "duration_seconds", "Duration of database calls.", "op"),
+		rows:            desc("operation", "rows_total", "Rows returned, affected or copied by database calls.", "op"),
+		errors:          desc("operation", "errors_total", "Failed database calls by SQLSTATE, empty for errors not from the server.", "op", "sqlstate"),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquired
+	ch <- c.idle
+	ch <- c.total
+	ch <- c.max
+	ch <- c.waiting
+	ch <- c.acquires
+	ch <- c.emptyAcquires
+	ch <- c.acquireDuration
+	ch <- c.duration
+	ch <- c.rows
+	ch <- c.errors
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	s := c.metrics.Snapshot()
+
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	gauge(c.acquired, float64(s.Pool.AcquiredConns))
+	gauge(c.idle, float64(s.Pool.IdleConns))
+	gauge(c.total, float64(s.Pool.TotalConns))
+	gauge(c.max, float64(s.Pool.MaxConns))
+	gauge(c.waiting, float64(s.Pool.Waiting))
+
+	ch <- prometheus.MustNewConstMetric(c.acquires, prometheus.CounterValue, float64(s.Pool.AcquireCount))
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(s.Pool.EmptyAcquireCount))
+	ch <- prometheus.MustNewConstMetric(c.acquireDuration, prometheus.CounterValue, s.Pool.AcquireDuration.Seconds())
+
+	for op, m := range s.Operations {
+		buckets := make(map[float64]uint64, len(m.Buckets))
+		for i, bound := range m.Buckets {
+			buckets[bound.Seconds()] = m.BucketCounts[i]
+		}
+		ch <- prometheus.MustNewConstHistogram(c.duration, uint64(m.Count), m.Duration.Seconds(), buckets, op)
+		ch <- prometheus.MustNewConstMetric(c.rows, prometheus.CounterValue, float64(m.Rows), op)
+		for code, n := range m.Errors {
+			ch <- prometheus.MustNewConstMetric(c.errors, prometheus.CounterValue, float64(n), op, code)
+		}
+	}
+}
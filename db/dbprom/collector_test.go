@@ -0,0 +1,103 @@
+package dbprom
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/siqueiraa/postgres-connect-go/db"
+)
+
+func TestCollector(t *testing.T) {
+	metrics := db.NewMetrics()
+	// Nothing listens on the port, so every call fails without a server
+	config := &db.DatabaseConfig{Host: "127.0.0.1", Port: 1, User: "test", DBName: "test", MaxConns: 3, LazyConnect: true}
+	client, err := db.Connect(context.Background(), config, db.WithMetrics(metrics))
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client.FetchJSON(ctx, "SELECT 1")
+	client.Explain(ctx, "SELECT 1")
+	client.FetchPage(ctx, "SELECT 1", 1, 10)
+	if _, err := client.FetchStream(ctx, "SELECT 1"); err == nil {
+		t.Fatal("FetchStream succeeded without a server")
+	}
+	client.Exec(ctx, "SELECT 1")
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewCollector(metrics, WithNamespace("app"), WithConstLabels(prometheus.Labels{"db": "primary"})))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"app_operation_duration_seconds", map[string]string{"op": db.OpFetch}, 4},
+		{"app_operation_duration_seconds", map[string]string{"op": db.OpExec}, 1},
+		{"app_operation_errors_total", map[string]string{"op": db.OpFetch, "sqlstate": ""}, 4},
+		{"app_operation_rows_total", map[string]string{"op": db.OpFetch}, 0},
+		{"app_pool_max_connections", nil, 3},
+		{"app_pool_waiting_calls", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := findMetric(families, tt.name, tt.labels)
+			if metric == nil {
+				t.Fatalf("no %s%v metric", tt.name, tt.labels)
+			}
+			if got := metricValue(metric); got != tt.want {
+				t.Errorf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+// findMetric returns the metric of the family name with labels and the
+// "db" const label, or nil
+func findMetric(families []*dto.MetricFamily, name string, labels map[string]string) *dto.Metric {
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			got := make(map[string]string)
+			for _, pair := range metric.GetLabel() {
+				got[pair.GetName()] = pair.GetValue()
+			}
+			if got["db"] != "primary" || len(got) != len(labels)+1 {
+				continue
+			}
+			for k, v := range labels {
+				if got[k] != v {
+					continue metrics
+				}
+			}
+			return metric
+		}
+	}
+	return nil
+}
+
+// metricValue returns the value of a gauge or counter, or the sample count of a histogram
+func metricValue(metric *dto.Metric) float64 {
+	switch {
+	case metric.GetHistogram() != nil:
+		return float64(metric.GetHistogram().GetSampleCount())
+	case metric.GetCounter() != nil:
+		return metric.GetCounter().GetValue()
+	default:
+		return metric.GetGauge().GetValue()
+	}
+}
@@ -0,0 +1,267 @@
+package db
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+// JSONMode is how json and jsonb columns are returned by the fetch functions
+type JSONMode int
+
+const (
+	// JSONDecoded unmarshals them into map[string]interface{}, []interface{}
+	// or a scalar (the default)
+	JSONDecoded JSONMode = iota
+	// JSONRaw returns their text as a json.RawMessage, for API layers that
+	// embed the document as it is
+	JSONRaw
+)
+
+// NumericMode is how numeric columns are returned by the fetch functions
+type NumericMode int
+
+const (
+	// NumericFloat converts them to float64, which loses precision past 15
+	// significant digits (the default)
+	NumericFloat NumericMode = iota
+	// NumericDecimal returns them as decimal.Decimal, without loss
+	NumericDecimal
+	// NumericString returns their text, without loss
+	NumericString
+)
+
+// WithNumericMode sets how numeric columns are returned by the call. Use
+// NumericDecimal or NumericString for money and quantities.
+func WithNumericMode(mode NumericMode) QueryOption {
+	return func(o *queryOptions) {
+		o.decode.numeric = mode
+	}
+}
+
+// WithJSONMode sets how json and jsonb columns are returned by the call
+func WithJSONMode(mode JSONMode) QueryOption {
+	return func(o *queryOptions) {
+		o.decode.json = mode
+	}
+}
+
+// WithTimestampLocation returns the values of timestamp without time zone and
+// date columns with their wall-clock reading in loc instead of UTC, so they
+// compare correctly with times of that zone
+func WithTimestampLocation(loc *time.Location) QueryOption {
+	return func(o *queryOptions) {
+		o.decode.location = loc
+	}
+}
+
+// WithBytes returns bytea columns as []byte instead of string, so binary
+// payloads are not mangled. With columns, only those columns are returned as
+// []byte, whatever their type.
+func WithBytes(columns ...string) QueryOption {
+	return func(o *queryOptions) {
+		o.decode.bytes = true
+		if len(columns) > 0 && o.decode.byteColumns == nil {
+			o.decode.byteColumns = make(map[string]bool, len(columns))
+		}
+		for _, col := range columns {
+			o.decode.byteColumns[col] = true
+		}
+	}
+}
+
+// WithRawTypes returns the values of the call as the pgtype values the driver
+// decodes, such as pgtype.Int4 or pgtype.Timestamptz, skipping the conversion
+// to native types. Their Status tells NULL from a value, so every column is
+// present in the result; columns of types the connection does not know come
+// back as pgtype.GenericBinary or pgtype.GenericText. The other decoding
+// options have no effect on such a call.
+func WithRawTypes() QueryOption {
+	return func(o *queryOptions) {
+		o.decode.raw = true
+	}
+}
+
+// decodeOptions controls how scanned values are converted to native types
+type decodeOptions struct {
+	// raw returns the pgtype values as they are scanned
+	raw bool
+	// keepNulls keeps values that are not present as nil entries
+	keepNulls bool
+	json      JSONMode
+	numeric   NumericMode
+	// location of the naive timestamps and dates, UTC when nil
+	location *time.Location
+	// zone every timestamptz is moved to, from DatabaseConfig.FetchLocation
+	zone *time.Location
+	// bytes keeps bytea columns, or only byteColumns when set, as []byte
+	bytes       bool
+	byteColumns map[string]bool
+	// rounding rounds the numbers of the columns it names
+	rounding map[string]Rounding
+}
+
+// keepBytes reports whether column name, of type oid, is returned as []byte
+func (o decodeOptions) keepBytes(name string, oid uint32) bool {
+	if !o.bytes {
+		return false
+	}
+	if o.byteColumns != nil {
+		return o.byteColumns[name]
+	}
+	return oid == pgtype.ByteaOID
+}
+
+// inZone returns the timestamptz t in the configured zone, if any
+func (o decodeOptions) inZone(t time.Time) time.Time {
+	if o.zone == nil {
+		return t
+	}
+	return t.In(o.zone)
+}
+
+// inLocation returns the wall-clock reading of t in the location of naive values
+func (o decodeOptions) inLocation(t time.Time) time.Time {
+	if o.location == nil {
+		return wallClockUTC(t)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), o.location)
+}
+
+// scannedValue returns column i of the current row of rows, scanned as value.
+// Bytes become a string, naive timestamps and dates move to the location of
+// options, timestamptz values to its zone and, with JSONRaw, json columns keep their text in a pgtype value
+// for toNativeValue. inet host addresses become a net.IP and the numbers of
+// columns with a rounding rule are rounded.
+func scannedValue(rows pgx.Rows, i int, value interface{}, options decodeOptions) interface{} {
+	if options.raw {
+		return value
+	}
+	field := rows.FieldDescriptions()[i]
+	if network, ok := value.(*net.IPNet); ok {
+		return nativeInet(network, field.DataTypeOID)
+	}
+	if t, ok := value.(time.Time); ok {
+		switch field.DataTypeOID {
+		case pgtype.TimestampOID, pgtype.DateOID:
+			if options.location != nil {
+				return options.inLocation(t)
+			}
+		case pgtype.TimestamptzOID:
+			return options.inZone(t)
+		}
+	}
+
+	if raw := rows.RawValues()[i]; options.json == JSONRaw && raw != nil {
+		switch field.DataTypeOID {
+		case pgtype.JSONOID:
+			var document pgtype.JSON
+			if err := document.DecodeText(nil, raw); err == nil {
+				return document
+			}
+		case pgtype.JSONBOID:
+			var document pgtype.JSONB
+			var err error
+			if field.Format == pgtype.BinaryFormatCode {
+				err = document.DecodeBinary(nil, raw)
+			} else {
+				err = document.DecodeText(nil, raw)
+			}
+			if err == nil {
+				return document
+			}
+		}
+	}
+
+	if b, ok := value.([]byte); ok {
+		if options.keepBytes(string(field.Name), field.DataTypeOID) {
+			// The driver reuses the buffer for the next row
+			return append([]byte(nil), b...)
+		}
+		return string(b)
+	}
+
+	if rule, ok := options.rounding[string(field.Name)]; ok {
+		return roundValue(value, rule)
+	}
+	return value
+}
+
+// scanValues scans the current row of rows into values, as the pgtype values
+// of the columns with WithRawTypes
+func scanValues(rows pgx.Rows, values []interface{}, options decodeOptions) error {
+	pointers := make([]interface{}, len(values))
+	if !options.raw {
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		return rows.Scan(pointers...)
+	}
+
+	raws := make([]rawValue, len(values))
+	for i, field := range rows.FieldDescriptions() {
+		raws[i].oid = field.DataTypeOID
+		pointers[i] = &raws[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return err
+	}
+	for i := range raws {
+		values[i] = raws[i].value
+	}
+	return nil
+}
+
+// rawValue scans a column into a new pgtype value of its type
+type rawValue struct {
+	oid   uint32
+	value interface{}
+}
+
+// DecodeBinary implements pgtype.BinaryDecoder
+func (r *rawValue) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error {
+	var decoder pgtype.BinaryDecoder = &pgtype.GenericBinary{}
+	if dt, ok := ci.DataTypeForOID(r.oid); ok {
+		if d, ok := pgtype.NewValue(dt.Value).(pgtype.BinaryDecoder); ok {
+			decoder = d
+		}
+	}
+	if err := decoder.DecodeBinary(ci, src); err != nil {
+		return err
+	}
+	r.value = reflect.ValueOf(decoder).Elem().Interface()
+	return nil
+}
+
+// DecodeText implements pgtype.TextDecoder
+func (r *rawValue) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	var decoder pgtype.TextDecoder = &pgtype.GenericText{}
+	if dt, ok := ci.DataTypeForOID(r.oid); ok {
+		if d, ok := pgtype.NewValue(dt.Value).(pgtype.TextDecoder); ok {
+			decoder = d
+		}
+	}
+	if err := decoder.DecodeText(ci, src); err != nil {
+		return err
+	}
+	r.value = reflect.ValueOf(decoder).Elem().Interface()
+	return nil
+}
+
+// nativeJSON returns the json document text as set by mode
+func nativeJSON(text []byte, mode JSONMode) (interface{}, bool) {
+	if mode == JSONRaw {
+		return json.RawMessage(text), true
+	}
+
+	var document interface{}
+	if err := json.Unmarshal(text, &document); err != nil {
+		// Keep the text when it does not parse
+		return json.RawMessage(text), true
+	}
+	return document, true
+}
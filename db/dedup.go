@@ -0,0 +1,124 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DedupStrategy is what the bulk functions do with rows of a chunk that share
+// a primary key. Without deduplication such a chunk fails to merge, because
+// ON CONFLICT cannot update the same row twice in one statement.
+type DedupStrategy int
+
+const (
+	// DedupNone loads the rows as they are (the default)
+	DedupNone DedupStrategy = iota
+	// DedupKeepFirst keeps the first row of each primary key
+	DedupKeepFirst
+	// DedupKeepLast keeps the last row of each primary key, so later rows win
+	DedupKeepLast
+	// DedupError fails the chunk with a *DuplicateKeyError
+	DedupError
+)
+
+// DuplicateKeyError is returned with DedupError when two rows share a primary key
+type DuplicateKeyError struct {
+	Row      int // Index of the duplicate row in the input
+	Previous int // Index of the earlier row with the same key
+	Key      []interface{}
+}
+
+// Error implements the error interface
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("db: row %d has the same primary key as row %d: %v", e.Row, e.Previous, e.Key)
+}
+
+// WithDedup removes rows with a duplicate primary key from each chunk before
+// it is staged, following strategy. Rows are compared by the formatted values
+// of their primary key columns. It has no effect without a primaryKey.
+func WithDedup(strategy DedupStrategy) BulkOption {
+	return func(o *bulkOptions) {
+		o.dedup = strategy
+	}
+}
+
+// dedupFor returns the deduplication strategy for loads on primaryKey
+func (o *bulkOptions) dedupFor(primaryKey []string) DedupStrategy {
+	if len(primaryKey) == 0 {
+		return DedupNone
+	}
+	return o.dedup
+}
+
+// dedupMaps applies strategy to data, whose first row is at index offset of
+// the input, and returns the rows of formatted, the converted copy of data,
+// that are kept, along with their indexes in data, nil when every row is
+// kept. Keys are compared by the values of data.
+func dedupMaps(data, formatted []map[string]interface{}, offset int, primaryKey []string, strategy DedupStrategy) ([]map[string]interface{}, []int, error) {
+	keep, err := dedupIndexes(len(data), offset, strategy, func(i int) []interface{} {
+		key := make([]interface{}, len(primaryKey))
+		for j, col := range primaryKey {
+			key[j] = data[i][col]
+		}
+		return key
+	})
+	if err != nil || keep == nil {
+		return formatted, nil, err
+	}
+
+	deduped := make([]map[string]interface{}, len(keep))
+	for i, index := range keep {
+		deduped[i] = formatted[index]
+	}
+	return deduped, keep, nil
+}
+
+// dedupIndexes returns the indexes of the n rows to keep under strategy, or
+// nil when every row is kept. key returns the primary key values of row i.
+func dedupIndexes(n, offset int, strategy DedupStrategy, key func(int) []interface{}) ([]int, error) {
+	if strategy == DedupNone {
+		return nil, nil
+	}
+
+	keys := make([]string, n)
+	seen := make(map[string]int, n)
+	duplicates := false
+	for i := 0; i < n; i++ {
+		values := key(i)
+		keys[i] = keyString(values)
+
+		previous, ok := seen[keys[i]]
+		switch {
+		case !ok:
+			seen[keys[i]] = i
+		case strategy == DedupError:
+			return nil, &DuplicateKeyError{Row: offset + i, Previous: offset + previous, Key: values}
+		default:
+			duplicates = true
+			if strategy == DedupKeepLast {
+				seen[keys[i]] = i
+			}
+		}
+	}
+	if !duplicates {
+		return nil, nil
+	}
+
+	keep := make([]int, 0, len(seen))
+	for i := 0; i < n; i++ {
+		if seen[keys[i]] == i {
+			keep = append(keep, i)
+		}
+	}
+	return keep, nil
+}
+
+// keyString returns the primary key values as a string, equal for keys whose
+// values print the same
+func keyString(values []interface{}) string {
+	parts := make([]string, len(values))
+	for j, value := range values {
+		parts[j] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// DeleteBulk deletes rows from table by primary key on the package-level Pool; see DB.DeleteBulk
+func DeleteBulk(ctx context.Context, table string, primaryKey []string, keys []map[string]interface{}, timeout time.Duration) (int64, error) {
+	return defaultDB().DeleteBulk(ctx, table, primaryKey, keys, timeout)
+}
+
+// DeleteBulk deletes the rows of table whose primaryKey columns match one of
+// keys. Like InsertBulkData it COPYs the keys into a temporary table and
+// deletes with a single DELETE ... USING in one transaction bounded by
+// timeout, which is far cheaper than one DELETE per key. Every key must hold
+// all primaryKey columns; other entries are ignored. It returns the number of
+// rows deleted.
+func (d *DB) DeleteBulk(ctx context.Context, table string, primaryKey []string, keys []map[string]interface{}, timeout time.Duration) (int64, error) {
+	return d.execWithStagedKeys(ctx, "DeleteBulk", table, primaryKey, keys, timeout, func(tempTable string) string {
+		return buildDeleteStatement(table, tempTable, primaryKey)
+	})
+}
+
+// SoftDeleteBulk marks rows of table as deleted by primary key on the package-level Pool; see DB.SoftDeleteBulk
+func SoftDeleteBulk(ctx context.Context, table string, primaryKey []string, keys []map[string]interface{}, deletedAtColumn string, timeout time.Duration) (int64, error) {
+	return defaultDB().SoftDeleteBulk(ctx, table, primaryKey, keys, deletedAtColumn, timeout)
+}
+
+// SoftDeleteBulk is DeleteBulk for soft deletes: instead of deleting the rows
+// of table whose primaryKey columns match one of keys, it sets their
+// deletedAtColumn to the transaction's now() with a single UPDATE ... FROM.
+// Rows already marked keep their original timestamp. It returns the number
+// of rows marked.
+func (d *DB) SoftDeleteBulk(ctx context.Context, table string, primaryKey []string, keys []map[string]interface{}, deletedAtColumn string, timeout time.Duration) (int64, error) {
+	if deletedAtColumn == "" {
+		return 0, fmt.Errorf("db: SoftDeleteBulk needs a deleted at column")
+	}
+	return d.execWithStagedKeys(ctx, "SoftDeleteBulk", table, primaryKey, keys, timeout, func(tempTable string) string {
+		return buildSoftDeleteStatement(table, tempTable, primaryKey, deletedAtColumn)
+	})
+}
+
+// execWithStagedKeys COPYs keys into a temporary table and executes the
+// statement built by statement for it, in one transaction bounded by timeout.
+// It returns the number of rows affected.
+func (d *DB) execWithStagedKeys(ctx context.Context, caller, table string, primaryKey []string, keys []map[string]interface{}, timeout time.Duration, statement func(tempTable string) string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	return d.bulkCall(ctx, table, len(keys), func(ctx context.Context) (int64, error) {
+		return d.stageKeysAndExec(ctx, caller, table, primaryKey, keys, timeout, statement)
+	})
+}
+
+// stageKeysAndExec runs execWithStagedKeys for a non-empty list of keys
+func (d *DB) stageKeysAndExec(ctx context.Context, caller, table string, primaryKey []string, keys []map[string]interface{}, timeout time.Duration, statement func(tempTable string) string) (int64, error) {
+	if len(primaryKey) == 0 {
+		return 0, fmt.Errorf("db: %s needs at least one primary key column", caller)
+	}
+
+	for i, key := range keys {
+		for _, col := range primaryKey {
+			if _, ok := key[col]; !ok {
+				return 0, fmt.Errorf("db: key %d has no value for %s", i, col)
+			}
+		}
+	}
+
+	keys, err := formatBulkRows(keys, 0, primaryKey, &bulkOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	// Create a new context with timeout
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Begin the transaction
+	tx, err := d.Pool().Begin(ctxWithTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctxWithTimeout)
+
+	tempTable := generateUniqueTempTableName(table)
+
+	// Create a temporary table with the key columns only
+	_, err = tx.Exec(ctxWithTimeout, d.tagSQL(ctx, fmt.Sprintf("CREATE TEMPORARY TABLE %s AS SELECT %s FROM %s WITH NO DATA", quoteIdent(tempTable), strings.Join(quoteIdents(primaryKey), ", "), quoteTable(table))))
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.CopyFrom(ctxWithTimeout, pgx.Identifier{tempTable}, primaryKey, newMapCopyFromSource(keys, primaryKey))
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctxWithTimeout, d.tagSQL(ctx, statement(tempTable)))
+	if err != nil {
+		return 0, err
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(ctxWithTimeout); err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// buildDeleteStatement constructs the DELETE ... USING statement matching table to the staged keys
+func buildDeleteStatement(table, tempTable string, primaryKey []string) string {
+	return fmt.Sprintf("DELETE FROM %s AS target USING %s AS staged WHERE %s",
+		quoteTable(table),
+		quoteIdent(tempTable),
+		buildKeyMatch("target", "staged", primaryKey),
+	)
+}
+
+// buildSoftDeleteStatement constructs the UPDATE ... FROM statement setting
+// deletedAtColumn on the rows of table matching the staged keys
+func buildSoftDeleteStatement(table, tempTable string, primaryKey []string, deletedAtColumn string) string {
+	return fmt.Sprintf("UPDATE %s AS target SET %s = now() FROM %s AS staged WHERE %s AND target.%s IS NULL",
+		quoteTable(table),
+		quoteIdent(deletedAtColumn),
+		quoteIdent(tempTable),
+		buildKeyMatch("target", "staged", primaryKey),
+		quoteIdent(deletedAtColumn),
+	)
+}
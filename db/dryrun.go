@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// WithDryRun makes the bulk functions append the statements they would run
+// to statements instead of running them, so the generated DDL, COPY and
+// ON CONFLICT merge can be reviewed. Nothing is written; column lookups such
+// as WithColumnTypeDetection still query the database, and the input is
+// validated and converted as usual, so errors in the rows are still reported.
+// The COPY data itself is not recorded. BulkWriter does not support it.
+func WithDryRun(statements *[]string) BulkOption {
+	return func(o *bulkOptions) {
+		o.dryRun = statements
+	}
+}
+
+// recordDryRun appends statements, tagged like they would be sent, to the dry run output
+func (d *DB) recordDryRun(ctx context.Context, options *bulkOptions, statements ...string) {
+	for _, sql := range statements {
+		*options.dryRun = append(*options.dryRun, d.tagSQL(ctx, sql))
+	}
+}
+
+// dryRunMerge records the statements of mergeInTx. The server version is not
+// known, so AutoBackend is recorded as ON CONFLICT.
+func (d *DB) dryRunMerge(ctx context.Context, table string, columns []string, primaryKey []string, options *bulkOptions) error {
+	tempTable := generateUniqueTempTableName(table)
+
+	merge, err := buildMergeStatements(table, tempTable, columns, primaryKey, options, 0)
+	if err != nil {
+		return err
+	}
+
+	statements := []string{
+		"BEGIN",
+		buildStagingTable(tempTable, table, options.stagingUnlogged, options.stagingOnCommitDrop, options.stagingTablespace),
+		buildCopyStatement(pgx.Identifier{tempTable}, columns),
+	}
+	statements = append(statements, merge...)
+	if options.stagingUnlogged {
+		statements = append(statements, "DROP TABLE "+quoteIdent(tempTable))
+	}
+	statements = append(statements, "COMMIT")
+
+	d.recordDryRun(ctx, options, statements...)
+	return nil
+}
+
+// dryRunParallelMerge records the statements of parallelStageAndMerge
+func (d *DB) dryRunParallelMerge(ctx context.Context, table string, columns []string, primaryKey []string, workers int, options *bulkOptions) error {
+	staging := generateUniqueTempTableName(table)
+
+	merge, err := buildMergeStatements(table, staging, columns, primaryKey, options, 0)
+	if err != nil {
+		return err
+	}
+
+	// Every worker runs the same statements on a staging table of its own
+	var statements []string
+	for i := 0; i < workers; i++ {
+		statements = append(statements,
+			"BEGIN",
+			buildStagingTable(staging, table, false, true, options.stagingTablespace),
+			buildCopyStatement(pgx.Identifier{staging}, columns))
+		statements = append(statements, merge...)
+	}
+	for i := 0; i < workers; i++ {
+		statements = append(statements, "COMMIT")
+	}
+
+	d.recordDryRun(ctx, options, statements...)
+	return nil
+}
+
+// buildCopyStatement constructs the COPY statement pgx runs for CopyFrom into table
+func buildCopyStatement(table pgx.Identifier, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdent(col)
+	}
+	return fmt.Sprintf("COPY %s (%s) FROM STDIN BINARY", table.Sanitize(), strings.Join(quoted, ", "))
+}
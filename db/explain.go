@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// QueryPlan is the plan of a query as reported by EXPLAIN (FORMAT JSON)
+type QueryPlan struct {
+	Plan PlanNode `json:"Plan"`
+	// PlanningTime and ExecutionTime are in milliseconds, set by ExplainAnalyze only
+	PlanningTime  float64 `json:"Planning Time"`
+	ExecutionTime float64 `json:"Execution Time"`
+	// Raw is the complete JSON document returned by the server
+	Raw json.RawMessage `json:"-"`
+}
+
+// PlanNode is one node of a query plan. The Actual fields are set by ExplainAnalyze only.
+type PlanNode struct {
+	NodeType        string     `json:"Node Type"`
+	RelationName    string     `json:"Relation Name"`
+	Alias           string     `json:"Alias"`
+	IndexName       string     `json:"Index Name"`
+	IndexCond       string     `json:"Index Cond"`
+	Filter          string     `json:"Filter"`
+	StartupCost     float64    `json:"Startup Cost"`
+	TotalCost       float64    `json:"Total Cost"`
+	PlanRows        float64    `json:"Plan Rows"`
+	PlanWidth       int        `json:"Plan Width"`
+	ActualRows      float64    `json:"Actual Rows"`
+	ActualLoops     float64    `json:"Actual Loops"`
+	ActualTotalTime float64    `json:"Actual Total Time"`
+	Plans           []PlanNode `json:"Plans"`
+}
+
+// UsesIndex reports whether any node of the plan reads an index
+func (n *PlanNode) UsesIndex() bool {
+	if n.IndexName != "" {
+		return true
+	}
+	for i := range n.Plans {
+		if n.Plans[i].UsesIndex() {
+			return true
+		}
+	}
+	return false
+}
+
+// Explain returns the plan of query on the package-level Pool without running it
+func Explain(ctx context.Context, query string, args ...interface{}) (*QueryPlan, error) {
+	return defaultDB().Explain(ctx, query, args...)
+}
+
+// ExplainAnalyze runs query on the package-level Pool and returns its plan with actual timings
+func ExplainAnalyze(ctx context.Context, query string, args ...interface{}) (*QueryPlan, error) {
+	return defaultDB().ExplainAnalyze(ctx, query, args...)
+}
+
+// Explain returns the plan the server would use for query with args, without running it
+func (d *DB) Explain(ctx context.Context, query string, args ...interface{}) (*QueryPlan, error) {
+	return d.explain(ctx, "EXPLAIN (FORMAT JSON) ", query, args)
+}
+
+// ExplainAnalyze runs query with args and returns its plan with the actual
+// row counts and timings. The statement really executes, so an UPDATE or
+// DELETE changes data; wrap it in a transaction that is rolled back if that
+// is not wanted.
+func (d *DB) ExplainAnalyze(ctx context.Context, query string, args ...interface{}) (*QueryPlan, error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, err
+	}
+
+	return d.explain(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) ", query, args)
+}
+
+// explain runs query prefixed with the EXPLAIN command explain as an OpFetch
+// call and decodes the plan
+func (d *DB) explain(ctx context.Context, explain, query string, args []interface{}) (*QueryPlan, error) {
+	call, args := d.startCall(ctx, OpFetch, explain+query, args)
+	defer call.done()
+
+	plan, err := d.queryPlan(call.ctx, explain, query, args)
+	if err != nil {
+		call.finish(0, err)
+		return nil, call.wrapErr(err)
+	}
+	call.finish(1, nil)
+	return plan, nil
+}
+
+// queryPlan runs the statement of explain
+func (d *DB) queryPlan(ctx context.Context, explain, query string, args []interface{}) (*QueryPlan, error) {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var raw []byte
+	if err := conn.QueryRow(ctx, d.tagSQL(ctx, explain+query), args...).Scan(&raw); err != nil {
+		return nil, err
+	}
+
+	var plans []QueryPlan
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return nil, fmt.Errorf("error reading query plan: %w", err)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("db: empty query plan")
+	}
+
+	plan := &plans[0]
+	plan.Raw = raw
+	return plan, nil
+}
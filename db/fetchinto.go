@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// scanBufferPool holds scan buffers reused across FetchInto calls
+var scanBufferPool = sync.Pool{
+	New: func() interface{} { return new(scanBuffer) },
+}
+
+// scanBuffer is a row of scan destinations and the values they point to
+type scanBuffer struct {
+	values   []interface{}
+	pointers []interface{}
+}
+
+// reset sizes the buffer for n columns
+func (b *scanBuffer) reset(n int) {
+	if cap(b.values) < n {
+		b.values = make([]interface{}, n)
+		b.pointers = make([]interface{}, n)
+	}
+	b.values = b.values[:n]
+	b.pointers = b.pointers[:n]
+	for i := range b.values {
+		b.values[i] = nil
+		b.pointers[i] = &b.values[i]
+	}
+}
+
+// scan scans the current row of rows into the buffer's values, decoded like
+// scanValues does for FetchDataFromTable
+func (b *scanBuffer) scan(rows pgx.Rows, decode decodeOptions) error {
+	if decode.raw {
+		return scanValues(rows, b.values, decode)
+	}
+	return rows.Scan(b.pointers...)
+}
+
+// FetchInto executes query on the package-level Pool and calls fn for every
+// row; see DB.FetchInto
+func FetchInto(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error {
+	return defaultDB().FetchInto(ctx, query, fn, args...)
+}
+
+// FetchInto executes query with args and calls fn with every row, converted
+// like FetchDataFromTable. To avoid allocations the same map is passed for
+// every row and overwritten afterwards, so fn must copy anything it keeps.
+// An error returned by fn stops the iteration and is returned.
+func (d *DB) FetchInto(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error {
+	if err := d.checkSQL(query); err != nil {
+		return err
+	}
+
+	call, args := d.startCall(ctx, OpFetch, query, args)
+	defer call.done()
+
+	rows := 0
+	err := d.fetchInto(call.ctx, query, func(row map[string]interface{}) error {
+		rows++
+		return fn(row)
+	}, args, call.options.decode)
+	call.finish(rows, err)
+	return call.wrapErr(err)
+}
+
+// fetchInto runs the query of FetchInto
+func (d *DB) fetchInto(ctx context.Context, query string, fn func(row map[string]interface{}) error, args []interface{}, decode decodeOptions) error {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// Execute the query
+	rows, err := conn.Query(ctx, d.tagSQL(ctx, query), args...)
+	if err != nil {
+		return err
+	}
+	return d.eachRow(rows, fn, decode)
+}
+
+// eachRow calls fn with every row of rows, converted as set by decode, and
+// closes rows
+func (d *DB) eachRow(rows pgx.Rows, fn func(row map[string]interface{}) error, decode decodeOptions) error {
+	defer rows.Close()
+
+	columns := columnNames(rows)
+
+	buf := scanBufferPool.Get().(*scanBuffer)
+	defer scanBufferPool.Put(buf)
+	buf.reset(len(columns))
+
+	row := make(map[string]interface{}, len(columns))
+
+	for rows.Next() {
+		if err := buf.scan(rows, decode); err != nil {
+			return err
+		}
+
+		// Transforms may have renamed entries of the previous row
+		clear(row)
+		for i, colName := range columns {
+			val := scannedValue(rows, i, buf.values[i], decode)
+			buf.values[i] = nil
+			if native, ok := toNativeValue(val, decode); ok || decode.keepNulls {
+				row[colName] = native
+			}
+		}
+
+		if err := d.transformRow(row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+)
+
+// int4Rows is a pgx.Rows with one int4 column "n" holding values, scanned
+// like pgx does: decoders decode the raw value, *interface{} gets an int32
+type int4Rows struct {
+	values []int32
+	pos    int
+}
+
+func (r *int4Rows) Close()                         {}
+func (r *int4Rows) Err() error                     { return nil }
+func (r *int4Rows) CommandTag() pgconn.CommandTag  { return nil }
+func (r *int4Rows) Values() ([]interface{}, error) { return []interface{}{r.values[r.pos-1]}, nil }
+
+func (r *int4Rows) FieldDescriptions() []pgproto3.FieldDescription {
+	return []pgproto3.FieldDescription{{Name: []byte("n"), DataTypeOID: pgtype.Int4OID, Format: pgtype.BinaryFormatCode}}
+}
+
+func (r *int4Rows) Next() bool {
+	r.pos++
+	return r.pos <= len(r.values)
+}
+
+func (r *int4Rows) RawValues() [][]byte {
+	return [][]byte{binary.BigEndian.AppendUint32(nil, uint32(r.values[r.pos-1]))}
+}
+
+func (r *int4Rows) Scan(dest ...interface{}) error {
+	switch d := dest[0].(type) {
+	case pgtype.BinaryDecoder:
+		return d.DecodeBinary(copyConnInfo, r.RawValues()[0])
+	case *interface{}:
+		*d = r.values[r.pos-1]
+	}
+	return nil
+}
+
+func TestEachRowAppliesDecodeOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		decode decodeOptions
+		want   []interface{}
+	}{
+		{"native", decodeOptions{}, []interface{}{int32(1), int32(2)}},
+		{"raw types", decodeOptions{raw: true}, []interface{}{
+			pgtype.Int4{Int: 1, Status: pgtype.Present},
+			pgtype.Int4{Int: 2, Status: pgtype.Present},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []interface{}
+			err := (&DB{}).eachRow(&int4Rows{values: []int32{1, 2}}, func(row map[string]interface{}) error {
+				got = append(got, row["n"])
+				return nil
+			}, tt.decode)
+			if err != nil {
+				t.Fatalf("eachRow: %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("eachRow passed %d rows, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("row %d n = %#v, want %#v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"strings"
+)
+
+// FetchJSON executes query on the package-level Pool and returns its rows as a
+// JSON array; see DB.FetchJSON
+func FetchJSON(ctx context.Context, query string, args ...interface{}) ([]byte, error) {
+	return defaultDB().FetchJSON(ctx, query, args...)
+}
+
+// FetchJSON executes query with args and returns its rows as a JSON array of
+// objects keyed by column name, serialized by the server with row_to_json and
+// json_agg, ready to be written to an HTTP response. A query without rows
+// gives []. Values follow PostgreSQL's JSON encoding, e.g. timestamps are
+// ISO 8601 strings and numeric values keep their full precision.
+func (d *DB) FetchJSON(ctx context.Context, query string, args ...interface{}) ([]byte, error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, err
+	}
+
+	call, args := d.startCall(ctx, OpFetch, query, args)
+	defer call.done()
+
+	out, rows, err := d.fetchJSON(call.ctx, query, args)
+	call.finish(rows, err)
+	if err != nil {
+		return nil, call.wrapErr(err)
+	}
+	return out, nil
+}
+
+// fetchJSON runs the query of FetchJSON and returns the JSON array along
+// with its number of rows
+func (d *DB) fetchJSON(ctx context.Context, query string, args []interface{}) ([]byte, int, error) {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer release()
+
+	var out []byte
+	var rows int
+	err = conn.QueryRow(ctx, d.tagSQL(ctx, "SELECT coalesce(json_agg(row_to_json(json_rows)), '[]'::json), count(*) FROM "+subquery(query)+" AS json_rows"), args...).Scan(&out, &rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, rows, nil
+}
+
+// subquery returns query parenthesized for use in a FROM clause. Trailing
+// semicolons are dropped, and the closing parenthesis goes on its own line so
+// a trailing -- comment does not swallow it.
+func subquery(query string) string {
+	query = strings.TrimRight(query, " \t\r\n\f;")
+	return "(" + query + "\n)"
+}
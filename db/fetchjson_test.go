@@ -0,0 +1,43 @@
+package db
+
+import "testing"
+
+func TestSubquery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"plain", "SELECT 1", "(SELECT 1\n)"},
+		{"trailing semicolon", "SELECT 1;", "(SELECT 1\n)"},
+		{"semicolons and whitespace", "SELECT 1 ; ;\n\t", "(SELECT 1\n)"},
+		{"trailing comment", "SELECT 1 -- one", "(SELECT 1 -- one\n)"},
+		{"comment then newline", "SELECT 1 -- one\n", "(SELECT 1 -- one\n)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := subquery(tt.query); got != tt.want {
+				t.Errorf("subquery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubqueryTokensBalance(t *testing.T) {
+	// The closing parenthesis must survive as a token after a trailing comment
+	tokens := sqlTokens("SELECT * FROM " + subquery("SELECT 1 -- one;") + " AS q")
+
+	depth := 0
+	for _, token := range tokens {
+		switch token.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+	}
+	if depth != 0 {
+		t.Errorf("unbalanced parentheses in %v", tokens)
+	}
+}
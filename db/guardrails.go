@@ -0,0 +1,288 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Guardrails rejects dangerous statements before they reach the server, for
+// pools exposed to semi-trusted tooling. By default it rejects every DROP and
+// TRUNCATE and every UPDATE or DELETE without a WHERE clause of its own; a
+// WHERE in a subquery does not count. Statements are recognized by their first
+// keyword after any EXPLAIN, so a data-modifying CTE (WITH ... DELETE) is only
+// caught by a Deny pattern.
+type Guardrails struct {
+	// AllowDrop lists the objects DROP and TRUNCATE may target, as name or
+	// schema.name, compared case-insensitively
+	AllowDrop []string
+	// AllowUnfilteredWrites permits UPDATE and DELETE without WHERE
+	AllowUnfilteredWrites bool
+	// Deny rejects any statement matching one of the patterns
+	Deny []*regexp.Regexp
+}
+
+// GuardrailError is returned for a statement rejected by Guardrails
+type GuardrailError struct {
+	SQL    string
+	Reason string
+}
+
+// Error implements the error interface
+func (e *GuardrailError) Error() string {
+	return "db: statement rejected: " + e.Reason
+}
+
+// WithGuardrails checks every statement run through the DB's fetch, exec,
+// batch and prepare functions against g and fails the call with a
+// *GuardrailError instead of running a rejected statement. Bulk inserts,
+// which only run statements generated by this package, are not checked.
+func WithGuardrails(g *Guardrails) PoolOption {
+	return func(o *poolOptions) {
+		o.guardrails = g
+	}
+}
+
+// checkSQL applies the DB's guardrails, if any, to sql
+func (d *DB) checkSQL(sql string) error {
+	d.mu.RLock()
+	options := d.poolOpts
+	d.mu.RUnlock()
+
+	if options == nil || options.guardrails == nil {
+		return nil
+	}
+	return options.guardrails.Check(sql)
+}
+
+// dropKeywords are the words of DROP and TRUNCATE statements that are not object names
+var dropKeywords = map[string]bool{
+	"table": true, "index": true, "view": true, "materialized": true, "foreign": true,
+	"sequence": true, "schema": true, "database": true, "type": true, "function": true,
+	"procedure": true, "trigger": true, "extension": true, "role": true, "user": true,
+	"concurrently": true, "if": true, "exists": true, "only": true, "cascade": true,
+	"restrict": true, "restart": true, "continue": true, "identity": true, "on": true,
+}
+
+// Check returns a *GuardrailError when sql, which may hold several statements
+// separated by semicolons, contains a statement g rejects
+func (g *Guardrails) Check(sql string) error {
+	for _, pattern := range g.Deny {
+		if pattern.MatchString(sql) {
+			return &GuardrailError{SQL: sql, Reason: fmt.Sprintf("matches denied pattern %s", pattern)}
+		}
+	}
+
+	for _, stmt := range splitStatements(sqlTokens(sql)) {
+		// EXPLAIN ANALYZE runs the statement it explains
+		stmt = skipExplain(stmt)
+		if len(stmt) == 0 {
+			continue
+		}
+
+		switch strings.ToLower(stmt[0].text) {
+		case "drop", "truncate":
+			for _, name := range statementNames(stmt[1:]) {
+				if !g.dropAllowed(name) {
+					return &GuardrailError{SQL: sql, Reason: fmt.Sprintf("%s of %s is not allowed", strings.ToUpper(stmt[0].text), name)}
+				}
+			}
+		case "update", "delete":
+			if !g.AllowUnfilteredWrites && !hasTopLevelKeyword(stmt, "where") {
+				return &GuardrailError{SQL: sql, Reason: fmt.Sprintf("%s without WHERE is not allowed", strings.ToUpper(stmt[0].text))}
+			}
+		}
+	}
+
+	return nil
+}
+
+// dropAllowed reports whether name, as schema.name or name, is in AllowDrop
+func (g *Guardrails) dropAllowed(name string) bool {
+	bare := name
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		bare = name[i+1:]
+	}
+	for _, allowed := range g.AllowDrop {
+		if strings.EqualFold(allowed, name) || strings.EqualFold(allowed, bare) {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlToken is a word, quoted identifier or punctuation character of a statement
+type sqlToken struct {
+	text   string
+	quoted bool // a "quoted identifier"
+}
+
+// sqlTokens splits sql into tokens, dropping comments, string literals and
+// $n placeholders. Unquoted words are lower-cased.
+func sqlTokens(sql string) []sqlToken {
+	var tokens []sqlToken
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return tokens
+			}
+			i += end + 1
+
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return tokens
+			}
+			i += end + 4
+
+		case c == '\'':
+			i = skipQuoted(sql, i, c)
+
+		case c == '"':
+			end := skipQuoted(sql, i, c)
+			inner := strings.TrimSuffix(sql[i+1:end], `"`)
+			tokens = append(tokens, sqlToken{text: strings.ReplaceAll(inner, `""`, `"`), quoted: true})
+			i = end
+
+		case c == '$':
+			end := skipDollarQuoted(sql, i)
+			if end == i+1 {
+				// A $n placeholder
+				for end < len(sql) && sql[end] >= '0' && sql[end] <= '9' {
+					end++
+				}
+			}
+			i = end
+
+		case isNamePart(c):
+			end := i
+			for end < len(sql) && (isNamePart(sql[end]) || sql[end] == '$') {
+				end++
+			}
+			tokens = append(tokens, sqlToken{text: strings.ToLower(sql[i:end])})
+			i = end
+
+		default:
+			tokens = append(tokens, sqlToken{text: string(c)})
+			i++
+		}
+	}
+
+	return tokens
+}
+
+// splitStatements splits tokens at semicolons, dropping empty statements
+func splitStatements(tokens []sqlToken) [][]sqlToken {
+	var stmts [][]sqlToken
+	start := 0
+	for i := 0; i <= len(tokens); i++ {
+		if i == len(tokens) || (!tokens[i].quoted && tokens[i].text == ";") {
+			if i > start {
+				stmts = append(stmts, tokens[start:i])
+			}
+			start = i + 1
+		}
+	}
+	return stmts
+}
+
+// statementNames returns the dotted object names of a DROP or TRUNCATE
+// statement, leaving out its keywords
+func statementNames(tokens []sqlToken) []string {
+	var names []string
+	var current []string
+	afterDot := false
+
+	flush := func() {
+		if len(current) > 0 {
+			names = append(names, strings.Join(current, "."))
+			current = nil
+		}
+	}
+
+	for _, token := range tokens {
+		isName := token.quoted || (isNameStart(token.text[0]) && !dropKeywords[token.text])
+		switch {
+		case isName:
+			// A name not joined to the previous one by a dot starts a new name
+			if !afterDot {
+				flush()
+			}
+			current = append(current, token.text)
+			afterDot = false
+		case token.text == ".":
+			afterDot = true
+		default:
+			flush()
+			afterDot = false
+		}
+	}
+	flush()
+
+	return names
+}
+
+// explainOptions are the words EXPLAIN takes before the statement without parentheses
+var explainOptions = map[string]bool{"analyze": true, "analyse": true, "verbose": true}
+
+// skipExplain returns the statement explained by stmt, an EXPLAIN, or stmt itself
+func skipExplain(stmt []sqlToken) []sqlToken {
+	if len(stmt) == 0 || stmt[0].quoted || stmt[0].text != "explain" {
+		return stmt
+	}
+	stmt = stmt[1:]
+
+	// EXPLAIN (ANALYZE, FORMAT JSON) ...
+	if len(stmt) > 0 && !stmt[0].quoted && stmt[0].text == "(" {
+		depth := 0
+		for i, token := range stmt {
+			if token.quoted {
+				continue
+			}
+			switch token.text {
+			case "(":
+				depth++
+			case ")":
+				depth--
+			}
+			if depth == 0 {
+				return stmt[i+1:]
+			}
+		}
+		return nil
+	}
+
+	for len(stmt) > 0 && !stmt[0].quoted && explainOptions[stmt[0].text] {
+		stmt = stmt[1:]
+	}
+	return stmt
+}
+
+// hasTopLevelKeyword reports whether the statement contains the unquoted word
+// keyword outside of parentheses, that is not in a subquery
+func hasTopLevelKeyword(tokens []sqlToken, keyword string) bool {
+	depth := 0
+	for _, token := range tokens {
+		if token.quoted {
+			continue
+		}
+		switch token.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case keyword:
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
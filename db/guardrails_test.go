@@ -0,0 +1,80 @@
+package db
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestGuardrailsCheck(t *testing.T) {
+	defaults := &Guardrails{}
+	tests := []struct {
+		name    string
+		g       *Guardrails
+		sql     string
+		allowed bool
+	}{
+		{"select", defaults, "SELECT * FROM trades", true},
+		{"filtered update", defaults, "UPDATE trades SET price = 1 WHERE id = $1", true},
+		{"filtered delete", defaults, "DELETE FROM trades WHERE id = $1", true},
+		{"unfiltered update", defaults, "UPDATE trades SET price = 1", false},
+		{"unfiltered delete", defaults, "delete from trades", false},
+		{"where in a string", defaults, "UPDATE trades SET note = 'where'", false},
+		{"where in a comment", defaults, "DELETE FROM trades -- WHERE id = 1\n", false},
+		{"where as a quoted name", defaults, `UPDATE trades SET "where" = 1`, false},
+		{"where in a subquery", defaults, "UPDATE t SET a = (SELECT x FROM y WHERE z)", false},
+		{"where in a using subquery", defaults, "DELETE FROM t USING (SELECT id FROM s WHERE s.old) s", false},
+		{"subquery and top-level where", defaults, "UPDATE t SET a = (SELECT x FROM y WHERE z) WHERE id = 1", true},
+		{"where after a subquery", defaults, "DELETE FROM t USING (SELECT id FROM s) s WHERE t.id = s.id", true},
+		{"explain analyze delete", defaults, "EXPLAIN ANALYZE DELETE FROM t", false},
+		{"explain analyze verbose update", defaults, "EXPLAIN ANALYZE VERBOSE UPDATE t SET a = 1", false},
+		{"explain with options", defaults, "EXPLAIN (ANALYZE, FORMAT JSON) DELETE FROM t", false},
+		{"explain filtered delete", defaults, "EXPLAIN ANALYZE DELETE FROM t WHERE id = 1", true},
+		{"explain select", defaults, "EXPLAIN SELECT 1", true},
+		{"explain drop stays rejected", defaults, "EXPLAIN (ANALYZE) DROP TABLE t", false},
+		{"second statement", defaults, "SELECT 1; DELETE FROM trades", false},
+		{"unfiltered writes allowed", &Guardrails{AllowUnfilteredWrites: true}, "DELETE FROM trades", true},
+		{"drop", defaults, "DROP TABLE trades", false},
+		{"truncate", defaults, "TRUNCATE trades", false},
+		{"allowed drop", &Guardrails{AllowDrop: []string{"scratch"}}, "DROP TABLE IF EXISTS public.scratch", true},
+		{"drop of an allowed and a denied table", &Guardrails{AllowDrop: []string{"scratch"}}, "DROP TABLE scratch, trades", false},
+		{"deny pattern", &Guardrails{Deny: []*regexp.Regexp{regexp.MustCompile(`(?i)pg_sleep`)}}, "SELECT pg_sleep(10)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.g.Check(tt.sql)
+			if tt.allowed {
+				if err != nil {
+					t.Errorf("Check(%q) = %v, want nil", tt.sql, err)
+				}
+				return
+			}
+			var guardErr *GuardrailError
+			if !errors.As(err, &guardErr) {
+				t.Errorf("Check(%q) = %v, want a *GuardrailError", tt.sql, err)
+			}
+		})
+	}
+}
+
+func TestSqlTokens(t *testing.T) {
+	tokens := sqlTokens(`SELECT "Mixed ""Name""", 'it''s' /* note */ FROM $tag$body$tag$ WHERE a = $1 -- trailing`)
+
+	var got []string
+	for _, token := range tokens {
+		got = append(got, token.text)
+	}
+	want := []string{"select", `Mixed "Name"`, ",", "from", "where", "a", "="}
+	if len(got) != len(want) {
+		t.Fatalf("sqlTokens = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sqlTokens = %q, want %q", got, want)
+		}
+	}
+	if !tokens[1].quoted {
+		t.Error("the quoted identifier is not marked quoted")
+	}
+}
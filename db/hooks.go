@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"io"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// PoolOption customizes how InitDB and Manager.Open build a connection pool.
+// Options are kept with the DB and applied again by Reconnect and ReloadConfig.
+type PoolOption func(*poolOptions)
+
+// poolOptions holds the settings applied by PoolOption values
+type poolOptions struct {
+	afterConnect  []func(context.Context, *pgx.Conn) error
+	beforeAcquire []func(context.Context, *pgx.Conn) bool
+	afterRelease  []func(*pgx.Conn) bool
+
+	// tagging and staticTags are set by WithQueryTagging
+	tagging    bool
+	staticTags map[string]string
+
+	// Set by WithColumnTransform and WithRowTransform
+	columnTransforms map[string][]func(interface{}) (interface{}, error)
+	rowTransforms    []func(map[string]interface{}) error
+
+	// guardrails is set by WithGuardrails
+	guardrails *Guardrails
+
+	// metrics is set by WithMetrics and tracer by WithTracer
+	metrics *Metrics
+	tracer  Tracer
+
+	// logger is set by WithLogger
+	logger pgx.Logger
+
+	// Set by WithAuditWriter, WithAuditTable and WithAuditIdentity
+	auditWriter   io.Writer
+	auditTable    string
+	auditIdentity func(context.Context) string
+
+	// host overrides the address of the pool; set for replica pools
+	host *HostConfig
+}
+
+// newPoolOptions applies opts to a fresh poolOptions
+func newPoolOptions(opts []PoolOption) *poolOptions {
+	options := &poolOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// WithAfterConnect runs fn on every new connection before it joins the pool,
+// e.g. to register custom types or set session GUCs. An error discards the
+// connection. Hooks registered more than once run in order.
+func WithAfterConnect(fn func(context.Context, *pgx.Conn) error) PoolOption {
+	return func(o *poolOptions) {
+		o.afterConnect = append(o.afterConnect, fn)
+	}
+}
+
+// WithBeforeAcquire runs fn before a pooled connection is handed out.
+// Returning false destroys the connection and another one is tried.
+func WithBeforeAcquire(fn func(context.Context, *pgx.Conn) bool) PoolOption {
+	return func(o *poolOptions) {
+		o.beforeAcquire = append(o.beforeAcquire, fn)
+	}
+}
+
+// WithAfterRelease runs fn when a connection is returned to the pool.
+// Returning false destroys the connection instead of reusing it.
+func WithAfterRelease(fn func(*pgx.Conn) bool) PoolOption {
+	return func(o *poolOptions) {
+		o.afterRelease = append(o.afterRelease, fn)
+	}
+}
+
+// apply installs the registered hooks on poolConfig
+func (o *poolOptions) apply(poolConfig *pgxpool.Config) {
+	if len(o.afterConnect) > 0 {
+		hooks := o.afterConnect
+		poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+			for _, hook := range hooks {
+				if err := hook(ctx, conn); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	if len(o.beforeAcquire) > 0 {
+		hooks := o.beforeAcquire
+		poolConfig.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+			for _, hook := range hooks {
+				if !hook(ctx, conn) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	if len(o.afterRelease) > 0 {
+		hooks := o.afterRelease
+		poolConfig.AfterRelease = func(conn *pgx.Conn) bool {
+			for _, hook := range hooks {
+				if !hook(conn) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+}
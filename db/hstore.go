@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+// registerHstore looks up the OID of the hstore extension type and registers
+// it with conn so hstore values are sent and received in binary
+func registerHstore(ctx context.Context, conn *pgx.Conn) error {
+	var oid uint32
+	err := conn.QueryRow(ctx, "SELECT oid FROM pg_type WHERE typname = 'hstore'").Scan(&oid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New("db: hstore is enabled but the hstore extension is not installed")
+	}
+	if err != nil {
+		return fmt.Errorf("error looking up the hstore type: %w", err)
+	}
+
+	conn.ConnInfo().RegisterDataType(pgtype.DataType{Value: &pgtype.Hstore{}, Name: "hstore", OID: oid})
+	return nil
+}
+
+// nativeHstore converts the pairs of an hstore value to a map whose NULL
+// values are nil
+func nativeHstore(pairs map[string]pgtype.Text) map[string]*string {
+	m := make(map[string]*string, len(pairs))
+	for key, value := range pairs {
+		if value.Status == pgtype.Present {
+			s := value.String
+			m[key] = &s
+		} else {
+			m[key] = nil
+		}
+	}
+	return m
+}
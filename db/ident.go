@@ -0,0 +1,32 @@
+package db
+
+import (
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// tableIdentifier splits a table name such as "schema.table" into its parts
+func tableIdentifier(table string) pgx.Identifier {
+	return pgx.Identifier(strings.Split(table, "."))
+}
+
+// quoteTable returns table, a name such as "schema.table", quoted for use in
+// SQL. Every part is taken literally, so mixed case is kept.
+func quoteTable(table string) string {
+	return tableIdentifier(table).Sanitize()
+}
+
+// quoteIdent returns name quoted as a single identifier
+func quoteIdent(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// quoteIdents returns names quoted as identifiers
+func quoteIdents(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteIdent(name)
+	}
+	return quoted
+}
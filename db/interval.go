@@ -0,0 +1,30 @@
+package db
+
+import (
+	"math"
+	"time"
+
+	"github.com/jackc/pgtype"
+)
+
+// Interval is an interval value that does not fit a time.Duration: months and
+// days have no fixed length, so they are kept apart from the rest
+type Interval struct {
+	Months       int32
+	Days         int32
+	Microseconds int64
+}
+
+// nativeInterval returns v as a time.Duration, or as an Interval when it has
+// month or day components or is too long for a time.Duration
+func nativeInterval(v pgtype.Interval) interface{} {
+	if v.Months == 0 && v.Days == 0 && v.Microseconds <= math.MaxInt64/1000 && v.Microseconds >= math.MinInt64/1000 {
+		return time.Duration(v.Microseconds) * time.Microsecond
+	}
+	return Interval{Months: v.Months, Days: v.Days, Microseconds: v.Microseconds}
+}
+
+// intervalValue converts an Interval to its pgtype value
+func intervalValue(v Interval) pgtype.Interval {
+	return pgtype.Interval{Months: v.Months, Days: v.Days, Microseconds: v.Microseconds, Status: pgtype.Present}
+}
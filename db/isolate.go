@@ -0,0 +1,161 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+)
+
+// RowIsolation is how InsertBulkData finds the rows that make a chunk fail
+type RowIsolation int
+
+const (
+	// IsolateNone fails the whole chunk (the default)
+	IsolateNone RowIsolation = iota
+	// IsolateBisect retries each half of a failed chunk until the failing rows
+	// are found, which takes few transactions when bad rows are rare
+	IsolateBisect
+	// IsolateRowByRow retries every row of a failed chunk on its own
+	IsolateRowByRow
+)
+
+// WithRowIsolation makes InsertBulkData retry a chunk that the server rejects
+// because of its data, such as a bad value or a violated constraint, in
+// smaller transactions as set by mode: the good rows are committed and the
+// offending ones returned in a BadRowsError. Rows are retried within the
+// chunk's timeout, and the committed parts of a chunk are no longer atomic.
+// It is ignored with WithDeleteMissing.
+func WithRowIsolation(mode RowIsolation) BulkOption {
+	return func(o *bulkOptions) {
+		o.isolation = mode
+	}
+}
+
+// RowError is a row rejected by the server, with the error of its own insert
+type RowError struct {
+	Row int // Index of the row in the input
+	Err error
+}
+
+// BadRowsError is returned by InsertBulkData with WithRowIsolation when some
+// rows were rejected; every other row was committed
+type BadRowsError struct {
+	Rows []RowError
+}
+
+// Error implements the error interface
+func (e *BadRowsError) Error() string {
+	if len(e.Rows) == 1 {
+		return fmt.Sprintf("db: row %d was rejected: %v", e.Rows[0].Row, e.Rows[0].Err)
+	}
+	return fmt.Sprintf("db: %d rows were rejected, the first one (row %d): %v", len(e.Rows), e.Rows[0].Row, e.Rows[0].Err)
+}
+
+// Unwrap returns the errors of the rejected rows
+func (e *BadRowsError) Unwrap() []error {
+	errs := make([]error, len(e.Rows))
+	for i, row := range e.Rows {
+		errs[i] = row.Err
+	}
+	return errs
+}
+
+// isRowError reports whether err is the server rejecting the data, a data
+// exception or an integrity constraint violation, which retrying fewer rows
+// can get around
+func isRowError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || len(pgErr.Code) < 2 {
+		return false
+	}
+	class := pgErr.Code[:2]
+	return class == "22" || class == "23"
+}
+
+// inputRows returns the index in the input of each of n rows of a chunk that
+// starts at offset, keep being the indexes dedupMaps kept in the chunk
+func inputRows(keep []int, offset, n int) []int {
+	rows := make([]int, n)
+	for i := range rows {
+		if keep != nil {
+			rows[i] = offset + keep[i]
+		} else {
+			rows[i] = offset + i
+		}
+	}
+	return rows
+}
+
+// isolateBadRows merges data, whose rows are at indexes rows of the input and
+// which failed with err, in smaller transactions as set by options. It returns
+// the number of rows committed and a BadRowsError with the rows that still fail.
+func (d *DB) isolateBadRows(ctx context.Context, data []map[string]interface{}, rows []int, err error, columns []string, table string, primaryKey []string, options *bulkOptions) (int, error) {
+	return isolateRows(data, rows, err, options.isolation, func(part []map[string]interface{}) error {
+		return d.mergeRows(ctx, part, columns, table, primaryKey, options)
+	})
+}
+
+// isolateRows is isolateBadRows with merge committing each part of data. The
+// count includes the parts committed before an error that is not a row error.
+func isolateRows(data []map[string]interface{}, rows []int, err error, mode RowIsolation, merge func(part []map[string]interface{}) error) (int, error) {
+	var bad []RowError
+	committed := 0
+
+	// bisect finds the bad rows of part, which failed with err, by merging its halves
+	var bisect func(part []map[string]interface{}, index []int, err error) error
+	bisect = func(part []map[string]interface{}, index []int, err error) error {
+		if len(part) == 1 {
+			bad = append(bad, RowError{Row: index[0], Err: err})
+			return nil
+		}
+
+		mid := len(part) / 2
+		for _, half := range [][2]int{{0, mid}, {mid, len(part)}} {
+			start, end := half[0], half[1]
+			err := merge(part[start:end])
+			if err == nil {
+				committed += end - start
+				continue
+			}
+			if !isRowError(err) {
+				return err
+			}
+			if err := bisect(part[start:end], index[start:end], err); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch mode {
+	case IsolateBisect:
+		if err := bisect(data, rows, err); err != nil {
+			return committed, err
+		}
+	default:
+		for i, row := range data {
+			err := merge([]map[string]interface{}{row})
+			if err == nil {
+				committed++
+				continue
+			}
+			if !isRowError(err) {
+				return committed, err
+			}
+			bad = append(bad, RowError{Row: rows[i], Err: err})
+		}
+	}
+
+	if len(bad) == 0 {
+		return committed, nil
+	}
+	return committed, &BadRowsError{Rows: bad}
+}
+
+// mergeRows stages and merges data in a transaction of its own
+func (d *DB) mergeRows(ctx context.Context, data []map[string]interface{}, columns []string, table string, primaryKey []string, options *bulkOptions) error {
+	_, err := d.mergeInTx(ctx, table, columns, primaryKey, newMapCopyFromSource(data, columns), options)
+	return err
+}
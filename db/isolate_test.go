@@ -0,0 +1,79 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgconn"
+)
+
+func TestIsolateRowsCountsCommittedRows(t *testing.T) {
+	rowErr := &pgconn.PgError{Code: "23505"}
+	connErr := errors.New("connection reset")
+
+	tests := []struct {
+		name          string
+		mode          RowIsolation
+		bad           map[int]bool
+		failAfter     int // merges that succeed before every merge fails with connErr; 0 never
+		wantCommitted int
+		wantBad       []int
+		wantErr       error
+	}{
+		{"bisect, one bad row", IsolateBisect, map[int]bool{13: true}, 0, 5, []int{13}, nil},
+		{"bisect, two bad rows", IsolateBisect, map[int]bool{10: true, 15: true}, 0, 4, []int{10, 15}, nil},
+		{"row by row, one bad row", IsolateRowByRow, map[int]bool{11: true}, 0, 5, []int{11}, nil},
+		{"row by row, all bad", IsolateRowByRow, map[int]bool{10: true, 11: true, 12: true, 13: true, 14: true, 15: true}, 0, 0, []int{10, 11, 12, 13, 14, 15}, nil},
+		{"row by row, connection lost", IsolateRowByRow, map[int]bool{11: true}, 2, 2, nil, connErr},
+		{"bisect, connection lost", IsolateBisect, map[int]bool{10: true}, 1, 2, nil, connErr},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var data []map[string]interface{}
+			var rows []int
+			for i := 10; i < 16; i++ {
+				data = append(data, map[string]interface{}{"id": i})
+				rows = append(rows, i)
+			}
+
+			merges := 0
+			merge := func(part []map[string]interface{}) error {
+				for _, row := range part {
+					if tt.bad[row["id"].(int)] {
+						return rowErr
+					}
+				}
+				merges++
+				if tt.failAfter > 0 && merges > tt.failAfter {
+					return connErr
+				}
+				return nil
+			}
+
+			committed, err := isolateRows(data, rows, rowErr, tt.mode, merge)
+			if committed != tt.wantCommitted {
+				t.Errorf("isolateRows committed %d rows, want %d", committed, tt.wantCommitted)
+			}
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("isolateRows error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			var badRows *BadRowsError
+			if !errors.As(err, &badRows) {
+				t.Fatalf("isolateRows error = %v, want a *BadRowsError", err)
+			}
+			if len(badRows.Rows) != len(tt.wantBad) {
+				t.Fatalf("isolateRows rejected %v, want rows %v", badRows.Rows, tt.wantBad)
+			}
+			for i, row := range badRows.Rows {
+				if row.Row != tt.wantBad[i] {
+					t.Errorf("rejected row %d = %d, want %d", i, row.Row, tt.wantBad[i])
+				}
+			}
+		})
+	}
+}
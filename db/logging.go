@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"sort"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// WithLogger sends the log events of the pool's connections to logger instead
+// of printing them to stdout. The logLevel of the config still sets which
+// events are logged.
+func WithLogger(logger pgx.Logger) PoolOption {
+	return func(o *poolOptions) {
+		o.logger = logger
+	}
+}
+
+// eventLogger returns logger, or a logger printing to stdout when it is nil,
+// for the events the package logs itself whatever the logLevel
+func eventLogger(logger pgx.Logger) pgx.Logger {
+	if logger != nil {
+		return logger
+	}
+	return &CustomLogger{
+		logger: log.New(os.Stdout, "db:", log.LstdFlags),
+		level:  pgx.LogLevelInfo,
+	}
+}
+
+// SlogLogger is a pgx.Logger that writes the events of pgx to a slog.Logger,
+// with the data of each event as attributes
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a SlogLogger writing to logger, or to slog.Default()
+// when logger is nil. Wrap any slog.Handler with slog.New to use it.
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{logger: logger}
+}
+
+// Log implements the pgx.Logger interface
+func (l *SlogLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	slogLevel := slogLevel(level)
+	if !l.logger.Enabled(ctx, slogLevel) {
+		return
+	}
+
+	// Sort the keys so the attributes come out in the same order every time
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.Any(key, data[key]))
+	}
+	l.logger.LogAttrs(ctx, slogLevel, msg, attrs...)
+}
+
+// slogLevel returns the slog level of a pgx level; trace is below debug
+func slogLevel(level pgx.LogLevel) slog.Level {
+	switch level {
+	case pgx.LogLevelTrace:
+		return slog.LevelDebug - 4
+	case pgx.LogLevelDebug:
+		return slog.LevelDebug
+	case pgx.LogLevelInfo:
+		return slog.LevelInfo
+	case pgx.LogLevelWarn:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Manager holds one DB per named database, for services that talk to several
+// Postgres databases. Each DB owns its own pool and DatabaseConfig.
+type Manager struct {
+	mu  sync.RWMutex
+	dbs map[string]*DB
+}
+
+// NewManager returns an empty Manager
+func NewManager() *Manager {
+	return &Manager{dbs: make(map[string]*DB)}
+}
+
+// Open connects to the database described by config and registers it under name
+func (m *Manager) Open(ctx context.Context, name string, config *DatabaseConfig, opts ...PoolOption) (*DB, error) {
+	m.mu.RLock()
+	_, exists := m.dbs[name]
+	m.mu.RUnlock()
+	if exists {
+		return nil, fmt.Errorf("db: database %q is already registered", name)
+	}
+
+	d, err := openDB(ctx, config, newPoolOptions(opts))
+	if err != nil {
+		return nil, fmt.Errorf("error opening database %q: %w", name, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Another caller may have registered the name while we were connecting
+	if _, exists := m.dbs[name]; exists {
+		d.Close()
+		return nil, fmt.Errorf("db: database %q is already registered", name)
+	}
+	m.dbs[name] = d
+
+	return d, nil
+}
+
+// Get returns the DB registered under name
+func (m *Manager) Get(name string) (*DB, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	d, ok := m.dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("db: unknown database %q", name)
+	}
+	return d, nil
+}
+
+// Names returns the names of the registered databases in sorted order
+func (m *Manager) Names() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.dbs))
+	for name := range m.dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Remove closes the pool of the database registered under name and forgets it
+func (m *Manager) Remove(name string) {
+	m.mu.Lock()
+	d, ok := m.dbs[name]
+	delete(m.dbs, name)
+	m.mu.Unlock()
+
+	if ok {
+		d.Close()
+	}
+}
+
+// Close closes every registered pool
+func (m *Manager) Close() {
+	m.mu.Lock()
+	dbs := m.dbs
+	m.dbs = make(map[string]*DB)
+	m.mu.Unlock()
+
+	for _, d := range dbs {
+		d.Close()
+	}
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// UpsertBackend is the statement the bulk functions merge the staged rows with
+type UpsertBackend int
+
+const (
+	// OnConflictBackend uses INSERT ... ON CONFLICT (the default)
+	OnConflictBackend UpsertBackend = iota
+	// MergeBackend uses MERGE, which needs PostgreSQL 15 or later
+	MergeBackend
+	// AutoBackend uses MERGE when the server supports it and ON CONFLICT otherwise
+	AutoBackend
+)
+
+// WithUpsertBackend sets the statement that merges the staged rows into the
+// table. MERGE matches rows on primaryKey, so WithConflictConstraint and
+// WithConflictWhere cannot be used with it; WithUpdateWhere and
+// WithSkipUnchanged work the same, the staged row still being EXCLUDED.
+func WithUpsertBackend(backend UpsertBackend) BulkOption {
+	return func(o *bulkOptions) {
+		o.backend = backend
+	}
+}
+
+// WithDeleteMissing deletes the rows of the table whose primary key is not
+// among the loaded rows, in the same transaction as the merge, for full-table
+// syncs. On PostgreSQL 17 and later the MERGE backend does it with WHEN NOT
+// MATCHED BY SOURCE; otherwise a DELETE follows the merge. Every row has to be
+// merged at once, so it cannot be combined with a chunk size smaller than the
+// input, nor used with BulkWriter.
+func WithDeleteMissing() BulkOption {
+	return func(o *bulkOptions) {
+		o.deleteMissing = true
+	}
+}
+
+// checkDeleteMissing reports whether WithDeleteMissing can be used for a load of rows rows
+func (o *bulkOptions) checkDeleteMissing(rows int) error {
+	if o.deleteMissing && o.chunkSize > 0 && o.chunkSize < rows {
+		return errors.New("db: WithDeleteMissing needs every row in one chunk")
+	}
+	return nil
+}
+
+// serverMajorVersion returns the major version of the server of conn, or 0 if unknown
+func serverMajorVersion(conn *pgconn.PgConn) int {
+	version := conn.ParameterStatus("server_version")
+	end := 0
+	for end < len(version) && version[end] >= '0' && version[end] <= '9' {
+		end++
+	}
+	major, _ := strconv.Atoi(version[:end])
+	return major
+}
+
+// buildMergeStatements returns the statements merging tempTable into table on
+// a server of major version serverVersion, 0 if unknown. The first one merges
+// the rows and any following one deletes the rows missing from tempTable.
+func buildMergeStatements(table, tempTable string, columns []string, primaryKey []string, options *bulkOptions, serverVersion int) ([]string, error) {
+	useMerge := options.backend == MergeBackend || (options.backend == AutoBackend && serverVersion >= 15)
+	if options.deleteMissing && len(primaryKey) == 0 {
+		return nil, errors.New("db: WithDeleteMissing needs a primary key")
+	}
+
+	if !useMerge {
+		statements := []string{buildMergeStatement(table, tempTable, columns, primaryKey, options)}
+		if options.deleteMissing {
+			statements = append(statements, buildDeleteMissing(table, tempTable, primaryKey))
+		}
+		return statements, nil
+	}
+
+	if serverVersion > 0 && serverVersion < 15 {
+		return nil, fmt.Errorf("db: MERGE needs PostgreSQL 15 or later, the server is %d", serverVersion)
+	}
+
+	notMatchedBySource := options.deleteMissing && serverVersion >= 17
+	merge, err := buildMergeCommand(table, tempTable, columns, primaryKey, options, notMatchedBySource)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := []string{merge}
+	if options.deleteMissing && !notMatchedBySource {
+		statements = append(statements, buildDeleteMissing(table, tempTable, primaryKey))
+	}
+	return statements, nil
+}
+
+// buildMergeCommand constructs the MERGE statement merging tempTable into
+// table. The staged rows are aliased EXCLUDED like in ON CONFLICT.
+func buildMergeCommand(table, tempTable string, columns []string, primaryKey []string, options *bulkOptions, notMatchedBySource bool) (string, error) {
+	if len(primaryKey) == 0 {
+		return "", errors.New("db: the MERGE backend needs a primary key")
+	}
+	if options.conflictOn != "" || options.conflictWhere != "" {
+		return "", errors.New("db: the MERGE backend matches on the primary key, it does not support conflict targets")
+	}
+
+	var sql strings.Builder
+	fmt.Fprintf(&sql, "MERGE INTO %s USING (SELECT DISTINCT %s FROM %s) AS excluded ON %s",
+		quoteTable(table),
+		strings.Join(buildSelectList(columns, options), ", "),
+		quoteIdent(tempTable),
+		buildKeyMatch(quoteTable(table), "excluded", primaryKey),
+	)
+
+	// Matched rows are left alone when there is nothing to update
+	updateColumns := updateColumnsFor(columns, options)
+	if assignments := buildUpdateValuesWithExcluded(updateColumns, primaryKey); options.conflictAction != DoNothing && assignments != "" {
+		sql.WriteString(" WHEN MATCHED")
+		if conditions := buildUpdateConditions(table, updateColumns, primaryKey, options); len(conditions) > 0 {
+			sql.WriteString(" AND " + strings.Join(conditions, " AND "))
+		}
+		sql.WriteString(" THEN UPDATE SET " + assignments)
+	}
+
+	quoted := quoteIdents(columns)
+	values := make([]string, len(quoted))
+	for i, col := range quoted {
+		values[i] = "excluded." + col
+	}
+	fmt.Fprintf(&sql, " WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", strings.Join(quoted, ", "), strings.Join(values, ", "))
+
+	if notMatchedBySource {
+		sql.WriteString(" WHEN NOT MATCHED BY SOURCE THEN DELETE")
+	}
+
+	return sql.String(), nil
+}
+
+// buildDeleteMissing constructs the DELETE of the rows of table whose primary
+// key is not in tempTable
+func buildDeleteMissing(table, tempTable string, primaryKey []string) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE NOT EXISTS (SELECT 1 FROM %s AS excluded WHERE %s)",
+		quoteTable(table),
+		quoteIdent(tempTable),
+		buildKeyMatch(quoteTable(table), "excluded", primaryKey),
+	)
+}
+
+// buildKeyMatch returns the condition that the primaryKey columns of left and
+// right, both quoted already, are equal
+func buildKeyMatch(left, right string, primaryKey []string) string {
+	conditions := make([]string, len(primaryKey))
+	for i, col := range quoteIdents(primaryKey) {
+		conditions[i] = fmt.Sprintf("%s.%s = %s.%s", left, col, right, col)
+	}
+	return strings.Join(conditions, " AND ")
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Operations reported by Metrics
+const (
+	OpFetch = "fetch"
+	// OpExec is Exec, ExecPrepared and RunBatch, which runs as one call
+	OpExec = "exec"
+	// OpBulk is every write that COPYs rows: the InsertBulk functions,
+	// CopyInsert, ImportCSV, IngestStream, BulkWriter flushes, DeleteBulk and
+	// SoftDeleteBulk
+	OpBulk = "bulk"
+)
+
+// DefaultMetricsBuckets are the upper bounds of the duration histograms of
+// NewMetrics when none are given
+var DefaultMetricsBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Metrics collects measurements of a DB: the state of its pool and, per
+// operation, how many calls ran, how long they took, how many rows they
+// returned, affected or copied and the SQLSTATE of those that failed. Pass
+// it to InitDB or Connect with WithMetrics and export it with the collector
+// of package dbprom, or its Snapshot from a collector of your monitoring
+// system.
+// Create one with NewMetrics; a Metrics serves a single DB.
+type Metrics struct {
+	buckets []time.Duration
+
+	mu         sync.Mutex
+	operations map[string]*OperationMetrics
+	pool       func() *pgxpool.Pool
+
+	// waiting counts the calls blocked acquiring a connection
+	waiting atomic.Int64
+}
+
+// MetricsSnapshot is the state of a Metrics at one point in time
+type MetricsSnapshot struct {
+	Pool PoolMetrics
+	// Operations holds the metrics of OpFetch, OpExec and OpBulk, once they ran
+	Operations map[string]OperationMetrics
+}
+
+// PoolMetrics is the state of the connection pool
+type PoolMetrics struct {
+	AcquiredConns int32
+	IdleConns     int32
+	TotalConns    int32
+	MaxConns      int32
+	// Waiting is the number of calls waiting for a connection right now
+	Waiting int64
+
+	// AcquireCount and AcquireDuration are the number and total duration of
+	// the acquires so far; EmptyAcquireCount counts those that had to wait
+	AcquireCount      int64
+	AcquireDuration   time.Duration
+	EmptyAcquireCount int64
+}
+
+// OperationMetrics are the totals of one operation
+type OperationMetrics struct {
+	Count    int64
+	Rows     int64
+	Duration time.Duration
+
+	// BucketCounts holds, for each bound of Buckets, the number of calls
+	// that took at most that long, as in a Prometheus histogram
+	Buckets      []time.Duration
+	BucketCounts []uint64
+
+	// Errors counts the failed calls by SQLSTATE; errors that did not come
+	// from the server, such as timeouts, are counted under ""
+	Errors map[string]int64
+}
+
+// NewMetrics returns an empty Metrics whose duration histograms have the
+// upper bounds buckets, or DefaultMetricsBuckets
+func NewMetrics(buckets ...time.Duration) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+	buckets = append([]time.Duration(nil), buckets...)
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i] < buckets[j] })
+
+	return &Metrics{buckets: buckets, operations: make(map[string]*OperationMetrics)}
+}
+
+// WithMetrics records the pool state and the fetch, exec and bulk load
+// calls of the DB in m
+func WithMetrics(m *Metrics) PoolOption {
+	return func(o *poolOptions) {
+		o.metrics = m
+	}
+}
+
+// Snapshot returns a copy of the current metrics
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := MetricsSnapshot{Operations: make(map[string]OperationMetrics, len(m.operations))}
+	for op, metrics := range m.operations {
+		copied := *metrics
+		copied.BucketCounts = append([]uint64(nil), metrics.BucketCounts...)
+		copied.Errors = make(map[string]int64, len(metrics.Errors))
+		for code, n := range metrics.Errors {
+			copied.Errors[code] = n
+		}
+		snapshot.Operations[op] = copied
+	}
+
+	if m.pool != nil {
+		if pool := m.pool(); pool != nil {
+			stat := pool.Stat()
+			snapshot.Pool = PoolMetrics{
+				AcquiredConns:     stat.AcquiredConns(),
+				IdleConns:         stat.IdleConns(),
+				TotalConns:        stat.TotalConns(),
+				MaxConns:          stat.MaxConns(),
+				AcquireCount:      stat.AcquireCount(),
+				AcquireDuration:   stat.AcquireDuration(),
+				EmptyAcquireCount: stat.EmptyAcquireCount(),
+			}
+		}
+	}
+	snapshot.Pool.Waiting = m.waiting.Load()
+
+	return snapshot
+}
+
+// attach makes m report the pool of d
+func (m *Metrics) attach(d *DB) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.pool = d.Pool
+	m.mu.Unlock()
+}
+
+// record adds a call of op that started at start
+func (m *Metrics) record(op string, start time.Time, rows int64, err error) {
+	if m == nil {
+		return
+	}
+	elapsed := time.Since(start)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	metrics, ok := m.operations[op]
+	if !ok {
+		metrics = &OperationMetrics{
+			Buckets:      m.buckets,
+			BucketCounts: make([]uint64, len(m.buckets)),
+			Errors:       make(map[string]int64),
+		}
+		m.operations[op] = metrics
+	}
+
+	metrics.Count++
+	metrics.Rows += rows
+	metrics.Duration += elapsed
+	for i, bound := range m.buckets {
+		if elapsed <= bound {
+			metrics.BucketCounts[i]++
+		}
+	}
+	if err != nil {
+		metrics.Errors[sqlState(err)]++
+	}
+}
+
+// sqlState returns the SQLSTATE of err, or "" when it did not come from the server
+func sqlState(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TableBatch is the rows of one table written by InsertBulkMulti
+type TableBatch struct {
+	Table      string
+	PrimaryKey []string
+	Rows       []map[string]interface{}
+	// Options apply to this batch only; chunking and parallel copy options are ignored
+	Options []BulkOption
+}
+
+// InsertBulkMulti writes batches atomically on the package-level Pool; see DB.InsertBulkMulti
+func InsertBulkMulti(ctx context.Context, batches []TableBatch, timeout time.Duration) error {
+	return defaultDB().InsertBulkMulti(ctx, batches, timeout)
+}
+
+// InsertBulkMulti stages and merges every batch like InsertBulkData, in order
+// and in a single transaction bounded by timeout, so related rows of several
+// tables, such as facts and their dimensions, are committed together or not
+// at all. Every batch is validated before the transaction starts.
+func (d *DB) InsertBulkMulti(ctx context.Context, batches []TableBatch, timeout time.Duration) error {
+	var tables []string
+	rows := 0
+	for _, batch := range batches {
+		if len(batch.Rows) > 0 {
+			tables = append(tables, batch.Table)
+			rows += len(batch.Rows)
+		}
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	// The call is recorded once, with the tables it wrote to
+	_, err := d.bulkCall(ctx, strings.Join(tables, ","), rows, func(ctx context.Context) (int64, error) {
+		return d.insertBulkMulti(ctx, batches, timeout)
+	})
+	return err
+}
+
+// insertBulkMulti runs InsertBulkMulti and returns the number of rows copied
+func (d *DB) insertBulkMulti(ctx context.Context, batches []TableBatch, timeout time.Duration) (int64, error) {
+	type preparedBatch struct {
+		table      string
+		primaryKey []string
+		columns    []string
+		rows       []map[string]interface{}
+		options    *bulkOptions
+	}
+
+	prepared := make([]preparedBatch, 0, len(batches))
+	for i, batch := range batches {
+		if len(batch.Rows) == 0 {
+			continue
+		}
+
+		options := &bulkOptions{}
+		for _, opt := range batch.Options {
+			opt(options)
+		}
+		if options.dryRun != nil {
+			return 0, errors.New("db: InsertBulkMulti does not support WithDryRun")
+		}
+
+		rows, columns, err := d.prepareBatch(ctx, batch, options)
+		if err != nil {
+			return 0, fmt.Errorf("db: batch %d into %s: %w", i, batch.Table, err)
+		}
+		prepared = append(prepared, preparedBatch{batch.Table, batch.PrimaryKey, columns, rows, options})
+	}
+
+	// Create a new context with timeout
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Begin the transaction
+	tx, err := d.Pool().Begin(ctxWithTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctxWithTimeout)
+
+	var copied int64
+	for _, batch := range prepared {
+		src := newMapCopyFromSource(batch.rows, batch.columns)
+		n, err := d.stageAndMerge(ctxWithTimeout, tx, batch.table, batch.columns, batch.primaryKey, src, batch.options)
+		if err != nil {
+			return 0, fmt.Errorf("db: error writing %s: %w", batch.table, err)
+		}
+		copied += n
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(ctxWithTimeout); err != nil {
+		return 0, err
+	}
+	return copied, nil
+}
+
+// prepareBatch validates the rows of batch and returns them formatted for
+// COPY, without duplicate keys, along with their columns
+func (d *DB) prepareBatch(ctx context.Context, batch TableBatch, options *bulkOptions) ([]map[string]interface{}, []string, error) {
+	columns, err := d.bulkColumns(ctx, batch.Table, batch.Rows[0], options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := validateRows(batch.Rows, columns, options.nullPolicy); err != nil {
+		return nil, nil, err
+	}
+	if err := d.prepareBulk(ctx, batch.Table, columns, options); err != nil {
+		return nil, nil, err
+	}
+
+	formatted, err := formatBulkRows(batch.Rows, 0, columns, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Drop rows with a duplicate primary key
+	rows, _, err := dedupMaps(batch.Rows, formatted, 0, batch.PrimaryKey, options.dedupFor(batch.PrimaryKey))
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, columns, nil
+}
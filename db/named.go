@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FetchNamed executes a query written with :name placeholders on the
+// package-level Pool, binding the values from arg. See BindNamed.
+func FetchNamed(ctx context.Context, query string, arg interface{}, opts ...QueryOption) ([]map[string]interface{}, error) {
+	return defaultDB().FetchNamed(ctx, query, arg, opts...)
+}
+
+// FetchNamed executes a query written with :name placeholders, binding the values from arg
+func (d *DB) FetchNamed(ctx context.Context, query string, arg interface{}, opts ...QueryOption) ([]map[string]interface{}, error) {
+	sql, args, err := BindNamed(query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return d.FetchDataFromTable(ctx, sql, optionArgs(args, opts)...)
+}
+
+// BindNamed rewrites the :name placeholders of query to $1, $2, ... and returns
+// the matching argument list. arg is a map[string]interface{} (or any map with
+// string keys) or a struct, whose fields are named like in Fetch. A name used
+// more than once binds to the same placeholder. Placeholders inside string
+// literals, quoted identifiers, dollar-quoted strings and comments are left
+// alone, and so are :: casts.
+func BindNamed(query string, arg interface{}) (string, []interface{}, error) {
+	lookup, err := namedLookup(arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var b strings.Builder
+	var args []interface{}
+	positions := make(map[string]int)
+
+	for i := 0; i < len(query); {
+		c := query[i]
+
+		switch {
+		case c == '\'' || c == '"':
+			end := skipQuoted(query, i, c)
+			b.WriteString(query[i:end])
+			i = end
+
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i
+			}
+			b.WriteString(query[i:end])
+			i = end
+
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i + 4
+			}
+			b.WriteString(query[i:end])
+			i = end
+
+		case c == '$':
+			end := skipDollarQuoted(query, i)
+			b.WriteString(query[i:end])
+			i = end
+
+		case c == ':' && strings.HasPrefix(query[i:], "::"):
+			b.WriteString("::")
+			i += 2
+
+		case c == ':' && i+1 < len(query) && isNameStart(query[i+1]):
+			end := i + 1
+			for end < len(query) && isNamePart(query[end]) {
+				end++
+			}
+			name := query[i+1 : end]
+
+			pos, ok := positions[name]
+			if !ok {
+				value, found := lookup(name)
+				if !found {
+					return "", nil, fmt.Errorf("db: no value for named parameter :%s", name)
+				}
+				args = append(args, value)
+				pos = len(args)
+				positions[name] = pos
+			}
+			b.WriteString("$" + strconv.Itoa(pos))
+			i = end
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return b.String(), args, nil
+}
+
+// namedLookup returns a function that resolves parameter names against arg
+func namedLookup(arg interface{}) (func(string) (interface{}, bool), error) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		return func(name string) (interface{}, bool) {
+			value, ok := m[name]
+			return value, ok
+		}, nil
+	}
+
+	value := reflect.ValueOf(arg)
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("db: named parameters need string map keys, got %s", value.Type())
+		}
+		return func(name string) (interface{}, bool) {
+			v := value.MapIndex(reflect.ValueOf(name).Convert(value.Type().Key()))
+			if !v.IsValid() {
+				return nil, false
+			}
+			return v.Interface(), true
+		}, nil
+
+	case reflect.Struct:
+		fields, err := structFields(value.Type())
+		if err != nil {
+			return nil, err
+		}
+		return func(name string) (interface{}, bool) {
+			index, ok := fields[name]
+			if !ok {
+				return nil, false
+			}
+			return value.FieldByIndex(index).Interface(), true
+		}, nil
+	}
+
+	return nil, fmt.Errorf("db: cannot bind named parameters from %T", arg)
+}
+
+// skipQuoted returns the index just past the quoted section starting at start.
+// A doubled quote character inside the section is an escaped quote.
+func skipQuoted(query string, start int, quote byte) int {
+	for i := start + 1; i < len(query); i++ {
+		if query[i] == quote {
+			if i+1 < len(query) && query[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+// skipDollarQuoted returns the index just past a $tag$...$tag$ string starting
+// at start, or start+1 when the $ does not open one (e.g. a $1 placeholder)
+func skipDollarQuoted(query string, start int) int {
+	end := start + 1
+	for end < len(query) && isNamePart(query[end]) && !(end == start+1 && query[end] >= '0' && query[end] <= '9') {
+		end++
+	}
+	if end >= len(query) || query[end] != '$' {
+		return start + 1
+	}
+
+	tag := query[start : end+1]
+	closing := strings.Index(query[end+1:], tag)
+	if closing < 0 {
+		return len(query)
+	}
+	return end + 1 + closing + len(tag)
+}
+
+// isNameStart reports whether c can start a parameter name
+func isNameStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// isNamePart reports whether c can appear in a parameter name
+func isNamePart(c byte) bool {
+	return isNameStart(c) || ('0' <= c && c <= '9')
+}
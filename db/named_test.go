@@ -0,0 +1,102 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBindNamed(t *testing.T) {
+	type filter struct {
+		Symbol string
+		Limit  int `db:"max_rows"`
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		arg      interface{}
+		wantSQL  string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			"map",
+			"SELECT * FROM trades WHERE symbol = :symbol AND qty > :qty",
+			map[string]interface{}{"symbol": "AAPL", "qty": 10},
+			"SELECT * FROM trades WHERE symbol = $1 AND qty > $2",
+			[]interface{}{"AAPL", 10},
+			false,
+		},
+		{
+			"repeated name",
+			"SELECT :a, :b, :a",
+			map[string]interface{}{"a": 1, "b": 2},
+			"SELECT $1, $2, $1",
+			[]interface{}{1, 2},
+			false,
+		},
+		{
+			"struct with tags",
+			"SELECT * FROM trades WHERE symbol = :symbol LIMIT :max_rows",
+			filter{Symbol: "MSFT", Limit: 5},
+			"SELECT * FROM trades WHERE symbol = $1 LIMIT $2",
+			[]interface{}{"MSFT", 5},
+			false,
+		},
+		{
+			"pointer to struct",
+			"SELECT :symbol",
+			&filter{Symbol: "IBM"},
+			"SELECT $1",
+			[]interface{}{"IBM"},
+			false,
+		},
+		{
+			"typed map",
+			"SELECT :n",
+			map[string]int{"n": 3},
+			"SELECT $1",
+			[]interface{}{3},
+			false,
+		},
+		{
+			"casts and quoted text are left alone",
+			`SELECT :v::text, ':skip', "col:skip", $$ :skip $$, $tag$ :skip $tag$ -- :skip
+/* :skip */ FROM t`,
+			map[string]interface{}{"v": "x"},
+			`SELECT $1::text, ':skip', "col:skip", $$ :skip $$, $tag$ :skip $tag$ -- :skip
+/* :skip */ FROM t`,
+			[]interface{}{"x"},
+			false,
+		},
+		{
+			"escaped quote",
+			"SELECT 'it''s :skip', :v",
+			map[string]interface{}{"v": 1},
+			"SELECT 'it''s :skip', $1",
+			[]interface{}{1},
+			false,
+		},
+		{"missing value", "SELECT :missing", map[string]interface{}{}, "", nil, true},
+		{"non-string keys", "SELECT :n", map[int]int{1: 1}, "", nil, true},
+		{"unsupported argument", "SELECT :n", 42, "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := BindNamed(tt.query, tt.arg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BindNamed error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("sql = %q, want %q", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
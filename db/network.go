@@ -0,0 +1,93 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgtype"
+)
+
+// inetValue converts ip to an inet host address, IPv4 when it has an IPv4 form
+func inetValue(ip net.IP) pgtype.Inet {
+	if ip == nil {
+		return pgtype.Inet{Status: pgtype.Null}
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	bits := len(ip) * 8
+	return pgtype.Inet{IPNet: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, Status: pgtype.Present}
+}
+
+// ipNetValue converts network to an inet or cidr value
+func ipNetValue(network *net.IPNet) pgtype.Inet {
+	if network == nil {
+		return pgtype.Inet{Status: pgtype.Null}
+	}
+	ip, mask := network.IP, network.Mask
+	// The server tells the families apart by address length
+	if v4 := ip.To4(); v4 != nil && len(mask) == net.IPv4len {
+		ip = v4
+	}
+	return pgtype.Inet{IPNet: &net.IPNet{IP: ip, Mask: mask}, Status: pgtype.Present}
+}
+
+// macaddrValue converts addr to a macaddr value
+func macaddrValue(addr net.HardwareAddr) pgtype.Macaddr {
+	if addr == nil {
+		return pgtype.Macaddr{Status: pgtype.Null}
+	}
+	return pgtype.Macaddr{Addr: addr, Status: pgtype.Present}
+}
+
+// nativeInet returns the address of an inet column as a net.IP when it is a
+// single host, and as the *net.IPNet otherwise
+func nativeInet(network *net.IPNet, oid uint32) interface{} {
+	if network == nil || oid != pgtype.InetOID {
+		return network
+	}
+	if ones, bits := network.Mask.Size(); ones == bits {
+		return network.IP
+	}
+	return network
+}
+
+// coerceInet converts value, a net.IP, *net.IPNet or address text such as
+// "10.0.0.1" or "10.0.0.0/8", to an inet or cidr value
+func coerceInet(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case net.IP:
+		return inetValue(v), nil
+	case net.IPNet:
+		return ipNetValue(&v), nil
+	case string:
+		v = strings.TrimSpace(v)
+		if ip, network, err := net.ParseCIDR(v); err == nil {
+			// inet keeps the host bits, which ParseCIDR masks off
+			network.IP = ip
+			return ipNetValue(network), nil
+		}
+		if ip := net.ParseIP(v); ip != nil {
+			return inetValue(ip), nil
+		}
+		return nil, fmt.Errorf("not an IP address or network")
+	}
+	return value, nil
+}
+
+// coerceMacaddr converts value, a net.HardwareAddr or text such as
+// "08:00:2b:01:02:03", to a macaddr value
+func coerceMacaddr(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case net.HardwareAddr:
+		return macaddrValue(v), nil
+	case string:
+		addr, err := net.ParseMAC(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("not a MAC address")
+		}
+		return macaddrValue(addr), nil
+	}
+	return value, nil
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KeysetPage is one page of rows returned by FetchPageKeyset
+type KeysetPage struct {
+	Rows []map[string]interface{}
+	// NextCursor holds the key values of the last row; pass it to get the next page
+	NextCursor []interface{}
+	// HasMore reports whether another page follows
+	HasMore bool
+}
+
+// FetchPageKeyset returns the page of query's rows that follows cursor, using
+// keyset pagination instead of OFFSET so every page costs the same no matter
+// how deep it is. query is wrapped in a subquery that is filtered on
+// keyColumns being past cursor and ordered by keyColumns. Pass a nil cursor
+// for the first page and the returned NextCursor for the following ones.
+//
+// keyColumns must be output columns of query that uniquely order its rows,
+// e.g. "time", "id". Suffix every one of them with " DESC" to page backwards;
+// mixing directions is not supported. args are bound to query's $n placeholders.
+func FetchPageKeyset(ctx context.Context, query string, keyColumns []string, cursor []interface{}, pageSize int, args ...interface{}) (*KeysetPage, error) {
+	return defaultDB().FetchPageKeyset(ctx, query, keyColumns, cursor, pageSize, args...)
+}
+
+// FetchPageKeyset returns the page of query's rows that follows cursor
+func (d *DB) FetchPageKeyset(ctx context.Context, query string, keyColumns []string, cursor []interface{}, pageSize int, args ...interface{}) (*KeysetPage, error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, err
+	}
+
+	// Options must not take up placeholder numbers ahead of the cursor values
+	call, args := d.startCall(ctx, OpFetch, query, args)
+	defer call.done()
+
+	rows, keys, err := d.fetchKeysetPage(call.ctx, query, keyColumns, cursor, pageSize, args, call.options.decode)
+	call.finish(len(rows), err)
+	if err != nil {
+		return nil, call.wrapErr(err)
+	}
+
+	page := &KeysetPage{Rows: rows}
+
+	// One extra row was requested to find out whether another page follows
+	if len(rows) > pageSize {
+		page.Rows = rows[:pageSize]
+		page.HasMore = true
+	}
+
+	if len(page.Rows) > 0 {
+		last := page.Rows[len(page.Rows)-1]
+		page.NextCursor = make([]interface{}, len(keys))
+		for i, key := range keys {
+			page.NextCursor[i] = last[key]
+		}
+	}
+
+	return page, nil
+}
+
+// fetchKeysetPage runs the keyset query of FetchPageKeyset and returns its
+// rows, including the extra one, and the bare key column names
+func (d *DB) fetchKeysetPage(ctx context.Context, query string, keyColumns []string, cursor []interface{}, pageSize int, args []interface{}, decode decodeOptions) ([]map[string]interface{}, []string, error) {
+	sql, sqlArgs, keys, err := buildKeysetQuery(query, keyColumns, cursor, pageSize, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := d.fetchDataFromTable(ctx, sql, sqlArgs, decode)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := d.transformRows(rows); err != nil {
+		return nil, nil, err
+	}
+	return rows, keys, nil
+}
+
+// buildKeysetQuery wraps query with the keyset filter, ordering and limit and
+// returns the statement, its arguments and the bare key column names
+func buildKeysetQuery(query string, keyColumns []string, cursor []interface{}, pageSize int, args []interface{}) (string, []interface{}, []string, error) {
+	if len(keyColumns) == 0 {
+		return "", nil, nil, fmt.Errorf("db: keyset pagination needs at least one key column")
+	}
+	if pageSize <= 0 {
+		return "", nil, nil, fmt.Errorf("db: page size must be positive, got %d", pageSize)
+	}
+	if cursor != nil && len(cursor) != len(keyColumns) {
+		return "", nil, nil, fmt.Errorf("db: cursor has %d values for %d key columns", len(cursor), len(keyColumns))
+	}
+
+	keys := make([]string, len(keyColumns))
+	descending := 0
+	for i, column := range keyColumns {
+		column = strings.TrimSpace(column)
+		upper := strings.ToUpper(column)
+		switch {
+		case strings.HasSuffix(upper, " DESC"):
+			column = strings.TrimSpace(column[:len(column)-len(" DESC")])
+			descending++
+		case strings.HasSuffix(upper, " ASC"):
+			column = strings.TrimSpace(column[:len(column)-len(" ASC")])
+		}
+		keys[i] = column
+	}
+	if descending != 0 && descending != len(keys) {
+		return "", nil, nil, fmt.Errorf("db: keyset pagination needs every key column in the same direction")
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending > 0 {
+		direction, comparison = "DESC", "<"
+	}
+
+	sqlArgs := append([]interface{}(nil), args...)
+
+	var sql strings.Builder
+	sql.WriteString("SELECT * FROM ")
+	sql.WriteString(subquery(query))
+	sql.WriteString(" AS keyset_page")
+
+	if cursor != nil {
+		placeholders := make([]string, len(cursor))
+		for i, value := range cursor {
+			sqlArgs = append(sqlArgs, value)
+			placeholders[i] = "$" + strconv.Itoa(len(sqlArgs))
+		}
+		fmt.Fprintf(&sql, " WHERE (%s) %s (%s)", strings.Join(keys, ", "), comparison, strings.Join(placeholders, ", "))
+	}
+
+	order := make([]string, len(keys))
+	for i, key := range keys {
+		order[i] = key + " " + direction
+	}
+	fmt.Fprintf(&sql, " ORDER BY %s LIMIT %d", strings.Join(order, ", "), pageSize+1)
+
+	return sql.String(), sqlArgs, keys, nil
+}
+
+// Page is one page of rows returned by FetchPage, with pagination metadata
+type Page struct {
+	Rows       []map[string]interface{}
+	Page       int   // 1-based page number
+	PageSize   int   // Rows per page
+	TotalRows  int64 // Rows of the whole query
+	TotalPages int
+	HasNext    bool
+	// Approximate is true when TotalRows is the planner's estimate
+	Approximate bool
+}
+
+// FetchPage returns page (1-based) of query's rows, size rows per page, by
+// appending LIMIT and OFFSET to query, along with the total row count from a
+// companion COUNT(*) query. query must not have its own LIMIT or OFFSET and
+// should have an ORDER BY so pages are stable.
+func FetchPage(ctx context.Context, query string, page, size int, args ...interface{}) (*Page, error) {
+	return defaultDB().FetchPage(ctx, query, page, size, args...)
+}
+
+// FetchPageApproximate is FetchPage using the planner's row estimate instead
+// of an exact COUNT(*), which is much cheaper on large tables
+func FetchPageApproximate(ctx context.Context, query string, page, size int, args ...interface{}) (*Page, error) {
+	return defaultDB().FetchPageApproximate(ctx, query, page, size, args...)
+}
+
+// FetchPage returns page (1-based) of query's rows with an exact total count
+func (d *DB) FetchPage(ctx context.Context, query string, page, size int, args ...interface{}) (*Page, error) {
+	return d.fetchPage(ctx, query, page, size, false, args)
+}
+
+// FetchPageApproximate returns page (1-based) of query's rows with an estimated total count
+func (d *DB) FetchPageApproximate(ctx context.Context, query string, page, size int, args ...interface{}) (*Page, error) {
+	return d.fetchPage(ctx, query, page, size, true, args)
+}
+
+// fetchPage runs the count and the paged query as one call
+func (d *DB) fetchPage(ctx context.Context, query string, page, size int, approximate bool, args []interface{}) (*Page, error) {
+	if page < 1 {
+		return nil, fmt.Errorf("db: page must be at least 1, got %d", page)
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("db: page size must be positive, got %d", size)
+	}
+	if err := d.checkSQL(query); err != nil {
+		return nil, err
+	}
+
+	call, args := d.startCall(ctx, OpFetch, query, args)
+	defer call.done()
+	ctx = call.ctx
+
+	var total int64
+	var err error
+	if approximate {
+		total, err = d.estimateRows(ctx, query, args)
+	} else {
+		total, err = d.countRows(ctx, query, args)
+	}
+	if err != nil {
+		call.finish(0, err)
+		return nil, fmt.Errorf("error counting rows: %w", call.wrapErr(err))
+	}
+
+	rows, err := d.fetchDataFromTable(ctx, pageQuery(query, page, size), args, call.options.decode)
+	if err == nil {
+		err = d.transformRows(rows)
+	}
+	call.finish(len(rows), err)
+	if err != nil {
+		return nil, call.wrapErr(err)
+	}
+
+	totalPages := int((total + int64(size) - 1) / int64(size))
+
+	return &Page{
+		Rows:        rows,
+		Page:        page,
+		PageSize:    size,
+		TotalRows:   total,
+		TotalPages:  totalPages,
+		HasNext:     int64(page)*int64(size) < total,
+		Approximate: approximate,
+	}, nil
+}
+
+// pageQuery returns the query for the 1-based page of query's rows. query is
+// wrapped in a subquery rather than suffixed, so a trailing semicolon or
+// -- comment does not break or swallow the LIMIT and OFFSET.
+func pageQuery(query string, page, size int) string {
+	return fmt.Sprintf("SELECT * FROM %s AS page LIMIT %d OFFSET %d", subquery(query), size, (page-1)*size)
+}
+
+// countRows returns the exact number of rows of query
+func (d *DB) countRows(ctx context.Context, query string, args []interface{}) (int64, error) {
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	var total int64
+	err = conn.QueryRow(ctx, d.tagSQL(ctx, "SELECT count(*) FROM "+subquery(query)+" AS counted"), args...).Scan(&total)
+	return total, err
+}
+
+// estimateRows returns the planner's row estimate for query
+func (d *DB) estimateRows(ctx context.Context, query string, args []interface{}) (int64, error) {
+	plan, err := d.queryPlan(ctx, "EXPLAIN (FORMAT JSON) ", query, args)
+	if err != nil {
+		return 0, err
+	}
+	return int64(plan.Plan.PlanRows), nil
+}
@@ -0,0 +1,42 @@
+package db
+
+import "testing"
+
+func TestPageQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		page  int
+		size  int
+		want  string
+	}{
+		{"first page", "SELECT * FROM t ORDER BY id", 1, 10, "SELECT * FROM (SELECT * FROM t ORDER BY id\n) AS page LIMIT 10 OFFSET 0"},
+		{"later page", "SELECT * FROM t ORDER BY id", 3, 25, "SELECT * FROM (SELECT * FROM t ORDER BY id\n) AS page LIMIT 25 OFFSET 50"},
+		{"trailing semicolon", "SELECT * FROM t ORDER BY id;", 2, 10, "SELECT * FROM (SELECT * FROM t ORDER BY id\n) AS page LIMIT 10 OFFSET 10"},
+		{"trailing comment", "SELECT * FROM t ORDER BY id -- newest last", 2, 10, "SELECT * FROM (SELECT * FROM t ORDER BY id -- newest last\n) AS page LIMIT 10 OFFSET 10"},
+		{"semicolon then newline", "SELECT * FROM t;\n", 1, 5, "SELECT * FROM (SELECT * FROM t\n) AS page LIMIT 5 OFFSET 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pageQuery(tt.query, tt.page, tt.size); got != tt.want {
+				t.Errorf("pageQuery(%q, %d, %d) = %q, want %q", tt.query, tt.page, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageQueryKeepsLimitOutsideComments(t *testing.T) {
+	// LIMIT and OFFSET must be tokens of the final statement, not part of a comment
+	tokens := sqlTokens(pageQuery("SELECT * FROM t -- all rows", 2, 10))
+
+	var found int
+	for _, token := range tokens {
+		if token.text == "limit" || token.text == "offset" {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Errorf("got %d of LIMIT and OFFSET in %v, want both", found, tokens)
+	}
+}
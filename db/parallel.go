@@ -0,0 +1,205 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// minRowsPerCopyWorker keeps tiny inputs from being spread over many connections
+const minRowsPerCopyWorker = 1000
+
+// WithParallelCopy shards each chunk across up to workers pooled connections
+// that COPY concurrently, to saturate the network on large loads. Each worker
+// copies its shard into a temporary table of its own connection, dropped on
+// commit or when the connection goes away, and merges it into the target table
+// in its own transaction. The transactions are committed once every worker
+// has merged, so a failed COPY or merge rolls back the whole chunk; a failure
+// while committing leaves the shards committed before it in place.
+//
+// Rows are sharded by primary key, so the workers never merge the same key,
+// but rows of different shards that clash on another unique constraint make
+// the workers wait on each other until the chunk times out. Loads with
+// WithDeleteMissing or WithAutoPartitions, which need every row in one
+// transaction, keep the single-connection path. The pool needs at least
+// workers free connections. workers <= 1 keeps the single-connection path.
+func WithParallelCopy(workers int) BulkOption {
+	return func(o *bulkOptions) {
+		o.copyWorkers = workers
+	}
+}
+
+// parallelStageAndMerge copies and merges the shards of data into table over
+// several connections and commits them once all of them are merged
+func (d *DB) parallelStageAndMerge(ctx context.Context, data []map[string]interface{}, columns []string, table string, primaryKey []string, workers int, options *bulkOptions) error {
+	if options.dryRun != nil {
+		return d.dryRunParallelMerge(ctx, table, columns, primaryKey, workers, options)
+	}
+
+	shards := shardRows(data, primaryKey, workers)
+	txs := make([]pgx.Tx, len(shards))
+	releases := make([]func(), len(shards))
+	defer func() {
+		// Roll back the shards left uncommitted, a no-op for committed ones
+		for i, tx := range txs {
+			if tx != nil {
+				tx.Rollback(ctx)
+				releases[i]()
+			}
+		}
+	}()
+
+	mergeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	options.result.begin()
+	start := time.Now()
+	var staged atomic.Int64
+
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var mergeErr error
+	// The merges share the counters of options.result
+	var mergeMu sync.Mutex
+
+	for i, shard := range shards {
+		i, shard := i, shard
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx, release, copied, err := d.mergeShard(mergeCtx, table, columns, primaryKey, shard, &mergeMu, options)
+			staged.Add(copied)
+			if err != nil {
+				errOnce.Do(func() {
+					mergeErr = err
+					// Stop the other workers, the chunk fails as a whole
+					cancel()
+				})
+				return
+			}
+			txs[i], releases[i] = tx, release
+		}()
+	}
+	wg.Wait()
+	options.result.copied(staged.Load(), time.Since(start))
+
+	if mergeErr != nil {
+		return mergeErr
+	}
+
+	// Commit the shards
+	for i, tx := range txs {
+		if err := tx.Commit(ctx); err != nil {
+			if i > 0 {
+				return fmt.Errorf("db: committed %d of %d shards: %w", i, len(txs), err)
+			}
+			return err
+		}
+	}
+	options.result.commit()
+
+	return nil
+}
+
+// mergeShard copies rows into a temporary table of a connection of its own and
+// merges it into table in a transaction, which it returns uncommitted along
+// with the function releasing the connection. mergeMu serializes the merges.
+func (d *DB) mergeShard(ctx context.Context, table string, columns []string, primaryKey []string, rows []map[string]interface{}, mergeMu *sync.Mutex, options *bulkOptions) (pgx.Tx, func(), int64, error) {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		release()
+		return nil, nil, 0, err
+	}
+
+	copied, err := d.copyShard(ctx, tx, table, columns, primaryKey, rows, mergeMu, options)
+	if err != nil {
+		tx.Rollback(ctx)
+		release()
+		return nil, nil, copied, err
+	}
+	return tx, release, copied, nil
+}
+
+// copyShard stages rows in a new temporary table dropped on commit and merges
+// them into table in tx, returning the number of rows copied
+func (d *DB) copyShard(ctx context.Context, tx pgx.Tx, table string, columns []string, primaryKey []string, rows []map[string]interface{}, mergeMu *sync.Mutex, options *bulkOptions) (int64, error) {
+	staging := generateUniqueTempTableName(table)
+
+	_, err := tx.Exec(ctx, d.tagSQL(ctx, buildStagingTable(staging, table, false, true, options.stagingTablespace)))
+	if err != nil {
+		return 0, err
+	}
+
+	copied, err := tx.CopyFrom(ctx, pgx.Identifier{staging}, columns, withProgress(newMapCopyFromSource(rows, columns), options))
+	if err != nil {
+		return copied, err
+	}
+
+	mergeMu.Lock()
+	defer mergeMu.Unlock()
+	return copied, d.runMerge(ctx, tx, table, staging, columns, primaryKey, options)
+}
+
+// shardRows splits rows into up to n shards. With a primary key the rows are
+// spread by the hash of their key, so every row of a key lands in the same
+// shard; without one the shards are consecutive runs of rows. Empty shards
+// are left out.
+func shardRows(rows []map[string]interface{}, primaryKey []string, n int) [][]map[string]interface{} {
+	if n <= 1 {
+		return [][]map[string]interface{}{rows}
+	}
+
+	var shards [][]map[string]interface{}
+	if len(primaryKey) == 0 {
+		size := (len(rows) + n - 1) / n
+		for start := 0; start < len(rows); start += size {
+			end := start + size
+			if end > len(rows) {
+				end = len(rows)
+			}
+			shards = append(shards, rows[start:end])
+		}
+		return shards
+	}
+
+	buckets := make([][]map[string]interface{}, n)
+	key := make([]interface{}, len(primaryKey))
+	for _, row := range rows {
+		for j, col := range primaryKey {
+			key[j] = row[col]
+		}
+		hash := fnv.New32a()
+		hash.Write([]byte(keyString(key)))
+		bucket := hash.Sum32() % uint32(n)
+		buckets[bucket] = append(buckets[bucket], row)
+	}
+	for _, bucket := range buckets {
+		if len(bucket) > 0 {
+			shards = append(shards, bucket)
+		}
+	}
+	return shards
+}
+
+// workersFor returns how many parallel COPY workers to use for a chunk of rows rows
+func (o *bulkOptions) workersFor(rows int) int {
+	if o.deleteMissing || o.partitions != 0 {
+		return 1
+	}
+	workers := o.copyWorkers
+	if limit := rows / minRowsPerCopyWorker; workers > limit {
+		workers = limit
+	}
+	return workers
+}
@@ -0,0 +1,73 @@
+package db
+
+import "testing"
+
+func TestShardRows(t *testing.T) {
+	rows := make([]map[string]interface{}, 10)
+	for i := range rows {
+		rows[i] = map[string]interface{}{"id": i % 5, "v": i}
+	}
+
+	tests := []struct {
+		name       string
+		primaryKey []string
+		n          int
+		maxShards  int
+	}{
+		{"one worker", []string{"id"}, 1, 1},
+		{"consecutive runs", nil, 3, 3},
+		{"by key", []string{"id"}, 3, 3},
+		{"more workers than keys", []string{"id"}, 8, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards := shardRows(rows, tt.primaryKey, tt.n)
+			if len(shards) == 0 || len(shards) > tt.maxShards {
+				t.Fatalf("got %d shards, want 1 to %d", len(shards), tt.maxShards)
+			}
+
+			total := 0
+			shardOf := make(map[interface{}]int)
+			for i, shard := range shards {
+				if len(shard) == 0 {
+					t.Errorf("shard %d is empty", i)
+				}
+				total += len(shard)
+				if len(tt.primaryKey) == 0 {
+					continue
+				}
+				for _, row := range shard {
+					if s, ok := shardOf[row["id"]]; ok && s != i {
+						t.Errorf("key %v is in shards %d and %d", row["id"], s, i)
+					}
+					shardOf[row["id"]] = i
+				}
+			}
+			if total != len(rows) {
+				t.Errorf("shards hold %d rows, want %d", total, len(rows))
+			}
+		})
+	}
+}
+
+func TestWorkersFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		options bulkOptions
+		rows    int
+		want    int
+	}{
+		{"disabled", bulkOptions{}, 10000, 0},
+		{"enough rows", bulkOptions{copyWorkers: 4}, 10000, 4},
+		{"capped by rows", bulkOptions{copyWorkers: 4}, 2500, 2},
+		{"delete missing", bulkOptions{copyWorkers: 4, deleteMissing: true}, 10000, 1},
+		{"auto partitions", bulkOptions{copyWorkers: 4, partitions: PartitionDaily}, 10000, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.workersFor(tt.rows); got != tt.want {
+				t.Errorf("workersFor(%d) = %d, want %d", tt.rows, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// PartitionInterval is the range covered by each partition WithAutoPartitions creates
+type PartitionInterval int
+
+const (
+	// PartitionDaily creates one partition per day, named table_pYYYYMMDD
+	PartitionDaily PartitionInterval = iota + 1
+	// PartitionWeekly creates one partition per ISO week, named table_pYYYYwWW
+	PartitionWeekly
+	// PartitionMonthly creates one partition per month, named table_pYYYYMM
+	PartitionMonthly
+	// PartitionYearly creates one partition per year, named table_pYYYY
+	PartitionYearly
+)
+
+// partitionUnit is the date_trunc field, to_char pattern of the name suffix
+// and interval of one partition
+type partitionUnit struct {
+	field, suffix, length string
+}
+
+var partitionUnits = map[PartitionInterval]partitionUnit{
+	PartitionDaily:   {"day", "YYYYMMDD", "1 day"},
+	PartitionWeekly:  {"week", `IYYY"w"IW`, "1 week"},
+	PartitionMonthly: {"month", "YYYYMM", "1 month"},
+	PartitionYearly:  {"year", "YYYY", "1 year"},
+}
+
+// WithAutoPartitions creates the missing partitions of a range-partitioned
+// table before the staged rows are merged, in the same transaction, so a batch
+// reaching into a new day or month does not fail. The partition key is read
+// from the catalog and must be a single date or timestamp column; every
+// interval of the staged keys gets a partition named after the table and the
+// interval start. Intervals already covered by another partition are left
+// alone. Dry runs do not record the partitions.
+func WithAutoPartitions(interval PartitionInterval) BulkOption {
+	return func(o *bulkOptions) {
+		o.partitions = interval
+	}
+}
+
+// ensurePartitions creates in tx the partitions of table the rows staged in
+// tempTable need
+func (d *DB) ensurePartitions(ctx context.Context, tx pgx.Tx, table, tempTable string, interval PartitionInterval) error {
+	unit, ok := partitionUnits[interval]
+	if !ok {
+		return fmt.Errorf("db: unknown partition interval %d", interval)
+	}
+
+	var column, typ string
+	err := tx.QueryRow(ctx, d.tagSQL(ctx, `SELECT a.attname, format_type(a.atttypid, a.atttypmod)
+		FROM pg_partitioned_table p
+		JOIN pg_attribute a ON a.attrelid = p.partrelid AND a.attnum = p.partattrs[0]
+		WHERE p.partrelid = $1::regclass AND p.partstrat = 'r' AND p.partnatts = 1`), quoteTable(table)).Scan(&column, &typ)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("db: %s is not range partitioned on a single column", table)
+	}
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, d.tagSQL(ctx, fmt.Sprintf(
+		"SELECT DISTINCT to_char(period, '%s'), period::%s::text, (period + interval '%s')::%s::text "+
+			"FROM (SELECT date_trunc('%s', %s) AS period FROM %s WHERE %s IS NOT NULL) AS periods",
+		unit.suffix, typ, unit.length, typ, unit.field, quoteIdent(column), quoteIdent(tempTable), quoteIdent(column),
+	)))
+	if err != nil {
+		return err
+	}
+
+	type partition struct{ name, from, to string }
+	var partitions []partition
+	for rows.Next() {
+		var suffix string
+		var p partition
+		if err := rows.Scan(&suffix, &p.from, &p.to); err != nil {
+			rows.Close()
+			return err
+		}
+		name := append(pgx.Identifier(nil), tableIdentifier(table)...)
+		name[len(name)-1] += "_p" + suffix
+		p.name = name.Sanitize()
+		partitions = append(partitions, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range partitions {
+		create := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%s) TO (%s)",
+			p.name, quoteTable(table), quoteLiteral(p.from), quoteLiteral(p.to))
+		if err := d.createPartition(ctx, tx, create); err != nil {
+			return fmt.Errorf("db: error creating partition %s: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+// createPartition executes create in a savepoint of tx, ignoring the errors
+// of a partition that already covers the interval
+func (d *DB) createPartition(ctx context.Context, tx pgx.Tx, create string) error {
+	savepoint, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := savepoint.Exec(ctx, d.tagSQL(ctx, create)); err != nil {
+		savepoint.Rollback(ctx)
+
+		var pgErr *pgconn.PgError
+		// invalid_object_definition is an overlapping partition, duplicate_table a concurrent create
+		if errors.As(err, &pgErr) && (pgErr.Code == "42P17" || pgErr.Code == "42P07") {
+			return nil
+		}
+		return err
+	}
+
+	return savepoint.Commit(ctx)
+}
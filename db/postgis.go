@@ -0,0 +1,1006 @@
+package db
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+// Formats of DatabaseConfig.PostGIS, in which geometry and geography columns
+// are returned
+const (
+	// GeometryWKT returns geometries as WKT strings, prefixed with SRID=n;
+	// when they have an SRID
+	GeometryWKT = "wkt"
+	// GeometryGeoJSON returns geometries as GeoJSON json.RawMessage values
+	GeometryGeoJSON = "geojson"
+)
+
+// Geometry kinds, as numbered by WKB
+const (
+	wkbPoint uint32 = iota + 1
+	wkbLineString
+	wkbPolygon
+	wkbMultiPoint
+	wkbMultiLineString
+	wkbMultiPolygon
+	wkbGeometryCollection
+)
+
+// Flags of the EWKB type word
+const (
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// geometryNames are the WKT and GeoJSON names of each geometry kind
+var geometryNames = map[uint32][2]string{
+	wkbPoint:              {"POINT", "Point"},
+	wkbLineString:         {"LINESTRING", "LineString"},
+	wkbPolygon:            {"POLYGON", "Polygon"},
+	wkbMultiPoint:         {"MULTIPOINT", "MultiPoint"},
+	wkbMultiLineString:    {"MULTILINESTRING", "MultiLineString"},
+	wkbMultiPolygon:       {"MULTIPOLYGON", "MultiPolygon"},
+	wkbGeometryCollection: {"GEOMETRYCOLLECTION", "GeometryCollection"},
+}
+
+// geometry is a decoded PostGIS geometry or geography value
+type geometry struct {
+	kind   uint32
+	srid   uint32
+	hasZ   bool
+	hasM   bool
+	coords [][]float64   // Point (no position when empty) and LineString
+	rings  [][][]float64 // Polygon
+	parts  []geometry    // Multi* and GeometryCollection
+}
+
+// registerPostGIS registers the geometry and geography types with conn so
+// they are scanned in format
+func registerPostGIS(ctx context.Context, conn *pgx.Conn, format string) error {
+	rows, err := conn.Query(ctx, "SELECT oid, typname FROM pg_type WHERE typname IN ('geometry', 'geography')")
+	if err != nil {
+		return fmt.Errorf("error looking up the PostGIS types: %w", err)
+	}
+	defer rows.Close()
+
+	var types []pgtype.DataType
+	for rows.Next() {
+		var oid uint32
+		var name string
+		if err := rows.Scan(&oid, &name); err != nil {
+			return fmt.Errorf("error looking up the PostGIS types: %w", err)
+		}
+		types = append(types, pgtype.DataType{Value: &geometryType{name: name, format: format}, Name: name, OID: oid})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error looking up the PostGIS types: %w", err)
+	}
+	if len(types) == 0 {
+		return errors.New("db: postgis is enabled but the postgis extension is not installed")
+	}
+
+	for _, dt := range types {
+		conn.ConnInfo().RegisterDataType(dt)
+	}
+	return nil
+}
+
+// geometryType is the pgtype value registered for the geometry and geography
+// types. It is scanned as the WKT or GeoJSON text of its format and encoded
+// as EWKB.
+type geometryType struct {
+	name   string
+	format string
+	geom   geometry
+	status pgtype.Status
+}
+
+// NewTypeValue implements pgtype.TypeValue
+func (g *geometryType) NewTypeValue() pgtype.Value {
+	return &geometryType{name: g.name, format: g.format}
+}
+
+// TypeName implements pgtype.TypeValue
+func (g *geometryType) TypeName() string {
+	return g.name
+}
+
+// Set parses src, a WKT, EWKT, hex EWKB or GeoJSON string, []byte or
+// json.RawMessage, or a GeoJSON map
+func (g *geometryType) Set(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		g.status = pgtype.Null
+		return nil
+	case string:
+		text = v
+	case *string:
+		if v == nil {
+			g.status = pgtype.Null
+			return nil
+		}
+		text = *v
+	case []byte:
+		text = string(v)
+	case json.RawMessage:
+		text = string(v)
+	case map[string]interface{}:
+		document, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		text = string(document)
+	default:
+		return fmt.Errorf("cannot convert %T to %s", src, g.name)
+	}
+
+	geom, err := parseGeometry(text)
+	if err != nil {
+		return err
+	}
+	g.geom, g.status = geom, pgtype.Present
+	return nil
+}
+
+// Get returns the WKT string or GeoJSON document of the value
+func (g geometryType) Get() interface{} {
+	switch g.status {
+	case pgtype.Present:
+		if g.format == GeometryGeoJSON {
+			return json.RawMessage(g.geom.geoJSON())
+		}
+		return g.geom.wkt()
+	case pgtype.Null:
+		return nil
+	default:
+		return g.status
+	}
+}
+
+// AssignTo stores the text of the value in a *string, *[]byte or *json.RawMessage
+func (g *geometryType) AssignTo(dst interface{}) error {
+	switch g.status {
+	case pgtype.Present:
+		text := g.geom.wkt()
+		if g.format == GeometryGeoJSON {
+			text = string(g.geom.geoJSON())
+		}
+		switch v := dst.(type) {
+		case *string:
+			*v = text
+			return nil
+		case *[]byte:
+			*v = []byte(text)
+			return nil
+		case *json.RawMessage:
+			*v = json.RawMessage(text)
+			return nil
+		}
+	case pgtype.Null:
+		return pgtype.NullAssignTo(dst)
+	}
+	return fmt.Errorf("cannot decode %s into %T", g.name, dst)
+}
+
+// DecodeBinary decodes the EWKB sent by the server
+func (g *geometryType) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		g.status = pgtype.Null
+		return nil
+	}
+	geom, err := decodeEWKB(src)
+	if err != nil {
+		return err
+	}
+	g.geom, g.status = geom, pgtype.Present
+	return nil
+}
+
+// DecodeText decodes the hex EWKB sent by the server
+func (g *geometryType) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		g.status = pgtype.Null
+		return nil
+	}
+	b, err := hex.DecodeString(string(src))
+	if err != nil {
+		return err
+	}
+	return g.DecodeBinary(ci, b)
+}
+
+// EncodeBinary encodes the value as EWKB
+func (g geometryType) EncodeBinary(ci *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	switch g.status {
+	case pgtype.Null:
+		return nil, nil
+	case pgtype.Undefined:
+		return nil, errors.New("cannot encode status undefined")
+	}
+	return g.geom.appendEWKB(buf, true), nil
+}
+
+// geometryValue converts value, the text of a geometry, to a value that COPY
+// encodes as EWKB
+func geometryValue(value interface{}, typ string) (interface{}, error) {
+	g := &geometryType{name: typ}
+	if err := g.Set(value); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// parseGeometry parses text as GeoJSON, hex EWKB or (E)WKT
+func parseGeometry(text string) (geometry, error) {
+	text = strings.TrimSpace(text)
+	switch {
+	case strings.HasPrefix(text, "{"):
+		return parseGeoJSON([]byte(text))
+	case isHexEWKB(text):
+		b, err := hex.DecodeString(text)
+		if err != nil {
+			return geometry{}, err
+		}
+		return decodeEWKB(b)
+	}
+	return parseEWKT(text)
+}
+
+// isHexEWKB reports whether text looks like hex encoded (E)WKB, which starts
+// with a 00 or 01 byte order mark
+func isHexEWKB(text string) bool {
+	if len(text) < 10 || len(text)%2 != 0 || (text[:2] != "00" && text[:2] != "01") {
+		return false
+	}
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// dims returns the number of ordinates of each position of g
+func (g geometry) dims() int {
+	n := 2
+	if g.hasZ {
+		n++
+	}
+	if g.hasM {
+		n++
+	}
+	return n
+}
+
+// empty reports whether g has no positions
+func (g geometry) empty() bool {
+	return len(g.coords) == 0 && len(g.rings) == 0 && len(g.parts) == 0
+}
+
+// setDims sets the dimensions of g and its parts, inferring them from the
+// first position when no Z or M was given, and checks every position has them
+func (g *geometry) setDims(hasZ, hasM, inferred bool) error {
+	if inferred {
+		switch len(g.firstPosition()) {
+		case 3:
+			hasZ = true
+		case 4:
+			hasZ, hasM = true, true
+		}
+	}
+	g.hasZ, g.hasM = hasZ, hasM
+	dims := g.dims()
+
+	check := func(positions [][]float64) error {
+		for _, p := range positions {
+			if len(p) != dims {
+				return fmt.Errorf("db: geometry position has %d ordinates, want %d", len(p), dims)
+			}
+		}
+		return nil
+	}
+	if err := check(g.coords); err != nil {
+		return err
+	}
+	for _, ring := range g.rings {
+		if err := check(ring); err != nil {
+			return err
+		}
+	}
+	for i := range g.parts {
+		if err := g.parts[i].setDims(hasZ, hasM, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// firstPosition returns the first position of g, or nil when it is empty
+func (g geometry) firstPosition() []float64 {
+	if len(g.coords) > 0 {
+		return g.coords[0]
+	}
+	for _, ring := range g.rings {
+		if len(ring) > 0 {
+			return ring[0]
+		}
+	}
+	for _, part := range g.parts {
+		if p := part.firstPosition(); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// wkbReader reads a geometry from (E)WKB
+type wkbReader struct {
+	data  []byte
+	order binary.ByteOrder
+	err   error
+}
+
+// decodeEWKB decodes b, in WKB, EWKB or ISO WKB with Z and M
+func decodeEWKB(b []byte) (geometry, error) {
+	r := &wkbReader{data: b}
+	g := r.geometry()
+	if r.err == nil && len(r.data) > 0 {
+		r.err = fmt.Errorf("db: %d bytes after the end of the geometry", len(r.data))
+	}
+	return g, r.err
+}
+
+// geometry reads a geometry with its byte order and type header
+func (r *wkbReader) geometry() geometry {
+	var g geometry
+	switch r.byte() {
+	case 0:
+		r.order = binary.BigEndian
+	case 1:
+		r.order = binary.LittleEndian
+	default:
+		r.fail(errors.New("db: invalid WKB byte order"))
+		return g
+	}
+
+	typ := r.uint32()
+	g.hasZ = typ&ewkbZ != 0
+	g.hasM = typ&ewkbM != 0
+	if typ&ewkbSRID != 0 {
+		g.srid = r.uint32()
+	}
+	typ &^= ewkbZ | ewkbM | ewkbSRID
+	switch {
+	case typ > 3000:
+		g.hasZ, g.hasM = true, true
+		typ -= 3000
+	case typ > 2000:
+		g.hasM = true
+		typ -= 2000
+	case typ > 1000:
+		g.hasZ = true
+		typ -= 1000
+	}
+	g.kind = typ
+
+	dims := g.dims()
+	switch g.kind {
+	case wkbPoint:
+		p := r.position(dims)
+		if r.err == nil && !(math.IsNaN(p[0]) && math.IsNaN(p[1])) {
+			g.coords = [][]float64{p}
+		}
+	case wkbLineString:
+		g.coords = r.positions(dims)
+	case wkbPolygon:
+		n := r.count(4)
+		for i := 0; i < n && r.err == nil; i++ {
+			g.rings = append(g.rings, r.positions(dims))
+		}
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+		n := r.count(5)
+		for i := 0; i < n && r.err == nil; i++ {
+			g.parts = append(g.parts, r.geometry())
+		}
+	default:
+		r.fail(fmt.Errorf("db: unsupported geometry type %d", typ))
+	}
+	return g
+}
+
+// fail records the first error
+func (r *wkbReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.data = nil
+}
+
+// take returns the next n bytes
+func (r *wkbReader) take(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.data) < n {
+		r.fail(errors.New("db: truncated WKB geometry"))
+		return make([]byte, n)
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *wkbReader) byte() byte {
+	return r.take(1)[0]
+}
+
+func (r *wkbReader) uint32() uint32 {
+	b := r.take(4)
+	if r.order == nil {
+		return 0
+	}
+	return r.order.Uint32(b)
+}
+
+// count reads an element count, checking there is room for that many
+// elements of at least size bytes
+func (r *wkbReader) count(size int) int {
+	n := int(r.uint32())
+	if n > len(r.data)/size {
+		r.fail(errors.New("db: truncated WKB geometry"))
+		return 0
+	}
+	return n
+}
+
+func (r *wkbReader) position(dims int) []float64 {
+	p := make([]float64, dims)
+	for i := range p {
+		b := r.take(8)
+		if r.order != nil {
+			p[i] = math.Float64frombits(r.order.Uint64(b))
+		}
+	}
+	return p
+}
+
+func (r *wkbReader) positions(dims int) [][]float64 {
+	n := r.count(8 * dims)
+	positions := make([][]float64, 0, n)
+	for i := 0; i < n && r.err == nil; i++ {
+		positions = append(positions, r.position(dims))
+	}
+	return positions
+}
+
+// appendEWKB appends g as little-endian EWKB; only the top level carries the SRID
+func (g geometry) appendEWKB(buf []byte, top bool) []byte {
+	typ := g.kind
+	if g.hasZ {
+		typ |= ewkbZ
+	}
+	if g.hasM {
+		typ |= ewkbM
+	}
+	if top && g.srid != 0 {
+		typ |= ewkbSRID
+	}
+
+	buf = append(buf, 1)
+	buf = binary.LittleEndian.AppendUint32(buf, typ)
+	if top && g.srid != 0 {
+		buf = binary.LittleEndian.AppendUint32(buf, g.srid)
+	}
+
+	appendPosition := func(buf []byte, p []float64) []byte {
+		for _, v := range p {
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		}
+		return buf
+	}
+	appendPositions := func(buf []byte, positions [][]float64) []byte {
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(positions)))
+		for _, p := range positions {
+			buf = appendPosition(buf, p)
+		}
+		return buf
+	}
+
+	switch g.kind {
+	case wkbPoint:
+		if len(g.coords) == 0 {
+			// An empty point has NaN ordinates
+			for i := 0; i < g.dims(); i++ {
+				buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(math.NaN()))
+			}
+		} else {
+			buf = appendPosition(buf, g.coords[0])
+		}
+	case wkbLineString:
+		buf = appendPositions(buf, g.coords)
+	case wkbPolygon:
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(g.rings)))
+		for _, ring := range g.rings {
+			buf = appendPositions(buf, ring)
+		}
+	default:
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(g.parts)))
+		for _, part := range g.parts {
+			buf = part.appendEWKB(buf, false)
+		}
+	}
+	return buf
+}
+
+// wkt returns g as WKT, prefixed with SRID=n; when it has an SRID
+func (g geometry) wkt() string {
+	var b strings.Builder
+	if g.srid != 0 {
+		fmt.Fprintf(&b, "SRID=%d;", g.srid)
+	}
+	g.writeWKT(&b)
+	return b.String()
+}
+
+// writeWKT writes the tagged WKT of g
+func (g geometry) writeWKT(b *strings.Builder) {
+	b.WriteString(geometryNames[g.kind][0])
+	switch {
+	case g.hasZ && g.hasM:
+		b.WriteString(" ZM")
+	case g.hasZ:
+		b.WriteString(" Z")
+	case g.hasM:
+		b.WriteString(" M")
+	}
+	b.WriteByte(' ')
+	g.writeWKTBody(b)
+}
+
+// writeWKTBody writes the parenthesized positions of g, or EMPTY
+func (g geometry) writeWKTBody(b *strings.Builder) {
+	if g.empty() {
+		b.WriteString("EMPTY")
+		return
+	}
+
+	writePositions := func(positions [][]float64) {
+		b.WriteByte('(')
+		for i, p := range positions {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			for j, v := range p {
+				if j > 0 {
+					b.WriteByte(' ')
+				}
+				b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		}
+		b.WriteByte(')')
+	}
+
+	switch g.kind {
+	case wkbPoint, wkbLineString:
+		writePositions(g.coords)
+	case wkbPolygon:
+		b.WriteByte('(')
+		for i, ring := range g.rings {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writePositions(ring)
+		}
+		b.WriteByte(')')
+	default:
+		b.WriteByte('(')
+		for i, part := range g.parts {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if g.kind == wkbGeometryCollection {
+				part.writeWKT(b)
+			} else {
+				part.writeWKTBody(b)
+			}
+		}
+		b.WriteByte(')')
+	}
+}
+
+// wktParser parses (E)WKT
+type wktParser struct {
+	text string
+	pos  int
+}
+
+// parseEWKT parses text, WKT with an optional SRID=n; prefix
+func parseEWKT(text string) (geometry, error) {
+	var srid uint32
+	if len(text) > 5 && strings.EqualFold(text[:5], "SRID=") {
+		end := strings.IndexByte(text, ';')
+		if end < 0 {
+			return geometry{}, errors.New("db: EWKT SRID is not followed by ;")
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(text[5:end]), 10, 32)
+		if err != nil {
+			return geometry{}, fmt.Errorf("db: invalid EWKT SRID: %w", err)
+		}
+		srid = uint32(n)
+		text = text[end+1:]
+	}
+
+	p := &wktParser{text: text}
+	g, err := p.geometry()
+	if err != nil {
+		return geometry{}, err
+	}
+	if p.skipSpace(); p.pos < len(p.text) {
+		return geometry{}, fmt.Errorf("db: unexpected %q after the end of the WKT geometry", p.text[p.pos:])
+	}
+	g.srid = srid
+	return g, nil
+}
+
+// geometry parses a tagged geometry and sets its dimensions
+func (p *wktParser) geometry() (geometry, error) {
+	word := strings.ToUpper(p.word())
+	var g geometry
+	hasZ, hasM, explicit := false, false, false
+
+	found := false
+	for kind, names := range geometryNames {
+		name := names[0]
+		if !strings.HasPrefix(word, name) {
+			continue
+		}
+		// PostGIS also writes POINTM and POINTZ without a space
+		switch word[len(name):] {
+		case "":
+		case "Z":
+			hasZ, explicit = true, true
+		case "M":
+			hasM, explicit = true, true
+		case "ZM":
+			hasZ, hasM, explicit = true, true, true
+		default:
+			continue
+		}
+		g.kind, found = kind, true
+		break
+	}
+	if !found {
+		return g, fmt.Errorf("db: unknown WKT geometry type %q", word)
+	}
+
+	if !explicit {
+		save := p.pos
+		switch strings.ToUpper(p.word()) {
+		case "Z":
+			hasZ, explicit = true, true
+		case "M":
+			hasM, explicit = true, true
+		case "ZM":
+			hasZ, hasM, explicit = true, true, true
+		default:
+			p.pos = save
+		}
+	}
+
+	if err := p.body(&g); err != nil {
+		return g, err
+	}
+	if err := g.setDims(hasZ, hasM, !explicit); err != nil {
+		return g, err
+	}
+	return g, nil
+}
+
+// body parses the parenthesized positions of g, or EMPTY
+func (p *wktParser) body(g *geometry) error {
+	if p.empty() {
+		return nil
+	}
+
+	var err error
+	switch g.kind {
+	case wkbPoint:
+		g.coords, err = p.positions()
+		if err == nil && len(g.coords) != 1 {
+			err = errors.New("db: WKT point must have one position")
+		}
+	case wkbLineString:
+		g.coords, err = p.positions()
+	case wkbPolygon:
+		err = p.list(func() error {
+			ring, err := p.positions()
+			g.rings = append(g.rings, ring)
+			return err
+		})
+	case wkbMultiPoint:
+		err = p.list(func() error {
+			part := geometry{kind: wkbPoint}
+			if p.empty() {
+				g.parts = append(g.parts, part)
+				return nil
+			}
+			// Points may be written with or without their own parentheses
+			if p.peek() == '(' {
+				positions, err := p.positions()
+				if err != nil {
+					return err
+				}
+				if len(positions) != 1 {
+					return errors.New("db: WKT point must have one position")
+				}
+				part.coords = positions
+			} else {
+				position, err := p.position()
+				if err != nil {
+					return err
+				}
+				part.coords = [][]float64{position}
+			}
+			g.parts = append(g.parts, part)
+			return nil
+		})
+	case wkbMultiLineString, wkbMultiPolygon:
+		kind := wkbLineString
+		if g.kind == wkbMultiPolygon {
+			kind = wkbPolygon
+		}
+		err = p.list(func() error {
+			part := geometry{kind: kind}
+			if err := p.body(&part); err != nil {
+				return err
+			}
+			g.parts = append(g.parts, part)
+			return nil
+		})
+	case wkbGeometryCollection:
+		err = p.list(func() error {
+			part, err := p.geometry()
+			g.parts = append(g.parts, part)
+			return err
+		})
+	}
+	return err
+}
+
+// list parses "(" item {"," item} ")"
+func (p *wktParser) list(item func() error) error {
+	if err := p.expect('('); err != nil {
+		return err
+	}
+	for {
+		if err := item(); err != nil {
+			return err
+		}
+		p.skipSpace()
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		return p.expect(')')
+	}
+}
+
+// positions parses "(" position {"," position} ")"
+func (p *wktParser) positions() ([][]float64, error) {
+	var positions [][]float64
+	err := p.list(func() error {
+		position, err := p.position()
+		positions = append(positions, position)
+		return err
+	})
+	return positions, err
+}
+
+// position parses the space separated ordinates of a position
+func (p *wktParser) position() ([]float64, error) {
+	var position []float64
+	for {
+		p.skipSpace()
+		start := p.pos
+		for p.pos < len(p.text) && strings.IndexByte("+-.0123456789eE", p.text[p.pos]) >= 0 {
+			p.pos++
+		}
+		if start == p.pos {
+			break
+		}
+		v, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("db: invalid WKT ordinate %q", p.text[start:p.pos])
+		}
+		position = append(position, v)
+	}
+	if len(position) < 2 || len(position) > 4 {
+		return nil, fmt.Errorf("db: WKT position has %d ordinates, want 2 to 4", len(position))
+	}
+	return position, nil
+}
+
+// empty consumes an EMPTY keyword if one follows
+func (p *wktParser) empty() bool {
+	save := p.pos
+	if strings.EqualFold(p.word(), "EMPTY") {
+		return true
+	}
+	p.pos = save
+	return false
+}
+
+// word returns the next run of letters
+func (p *wktParser) word() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.text) && isNameStart(p.text[p.pos]) {
+		p.pos++
+	}
+	return p.text[start:p.pos]
+}
+
+func (p *wktParser) expect(c byte) error {
+	p.skipSpace()
+	if p.peek() != c {
+		return fmt.Errorf("db: expected %q in WKT at offset %d", c, p.pos)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *wktParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.text) {
+		return p.text[p.pos]
+	}
+	return 0
+}
+
+func (p *wktParser) skipSpace() {
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r\n", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// geoJSON returns g as a GeoJSON geometry object
+func (g geometry) geoJSON() []byte {
+	document, _ := json.Marshal(g.geoJSONObject())
+	return document
+}
+
+// geoJSONObject returns the GeoJSON object of g, ready to be marshaled
+func (g geometry) geoJSONObject() map[string]interface{} {
+	object := map[string]interface{}{"type": geometryNames[g.kind][1]}
+
+	switch g.kind {
+	case wkbPoint:
+		if len(g.coords) == 0 {
+			object["coordinates"] = []float64{}
+		} else {
+			object["coordinates"] = g.coords[0]
+		}
+	case wkbLineString:
+		object["coordinates"] = nonNil(g.coords)
+	case wkbPolygon:
+		object["coordinates"] = nonNil(g.rings)
+	case wkbGeometryCollection:
+		geometries := make([]interface{}, len(g.parts))
+		for i, part := range g.parts {
+			geometries[i] = part.geoJSONObject()
+		}
+		object["geometries"] = geometries
+	default:
+		coordinates := make([]interface{}, len(g.parts))
+		for i, part := range g.parts {
+			coordinates[i] = part.geoJSONObject()["coordinates"]
+		}
+		object["coordinates"] = coordinates
+	}
+	return object
+}
+
+// nonNil returns s, or an empty slice when s is nil, so it marshals as []
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
+
+// parseGeoJSON parses a GeoJSON geometry, or the geometry of a Feature. GeoJSON
+// coordinates are WGS 84, so the geometry gets SRID 4326.
+func parseGeoJSON(document []byte) (geometry, error) {
+	g, err := geoJSONGeometry(document)
+	if err != nil {
+		return geometry{}, err
+	}
+	if err := g.setDims(false, false, true); err != nil {
+		return geometry{}, err
+	}
+	g.srid = 4326
+	return g, nil
+}
+
+// geoJSONGeometry decodes a GeoJSON object without setting its dimensions
+func geoJSONGeometry(document []byte) (geometry, error) {
+	var object struct {
+		Type        string            `json:"type"`
+		Coordinates json.RawMessage   `json:"coordinates"`
+		Geometries  []json.RawMessage `json:"geometries"`
+		Geometry    json.RawMessage   `json:"geometry"`
+	}
+	if err := json.Unmarshal(document, &object); err != nil {
+		return geometry{}, fmt.Errorf("db: invalid GeoJSON: %w", err)
+	}
+
+	if object.Type == "Feature" {
+		return geoJSONGeometry(object.Geometry)
+	}
+
+	var g geometry
+	for kind, names := range geometryNames {
+		if names[1] == object.Type {
+			g.kind = kind
+		}
+	}
+	if g.kind == 0 {
+		return g, fmt.Errorf("db: unknown GeoJSON geometry type %q", object.Type)
+	}
+
+	var err error
+	switch g.kind {
+	case wkbPoint:
+		var position []float64
+		if err = json.Unmarshal(object.Coordinates, &position); err == nil && len(position) > 0 {
+			g.coords = [][]float64{position}
+		}
+	case wkbLineString:
+		err = json.Unmarshal(object.Coordinates, &g.coords)
+	case wkbPolygon:
+		err = json.Unmarshal(object.Coordinates, &g.rings)
+	case wkbMultiPoint:
+		var positions [][]float64
+		err = json.Unmarshal(object.Coordinates, &positions)
+		for _, position := range positions {
+			g.parts = append(g.parts, geometry{kind: wkbPoint, coords: [][]float64{position}})
+		}
+	case wkbMultiLineString:
+		var lines [][][]float64
+		err = json.Unmarshal(object.Coordinates, &lines)
+		for _, line := range lines {
+			g.parts = append(g.parts, geometry{kind: wkbLineString, coords: line})
+		}
+	case wkbMultiPolygon:
+		var polygons [][][][]float64
+		err = json.Unmarshal(object.Coordinates, &polygons)
+		for _, rings := range polygons {
+			g.parts = append(g.parts, geometry{kind: wkbPolygon, rings: rings})
+		}
+	case wkbGeometryCollection:
+		for _, member := range object.Geometries {
+			part, err := geoJSONGeometry(member)
+			if err != nil {
+				return g, err
+			}
+			g.parts = append(g.parts, part)
+		}
+	}
+	if err != nil {
+		return g, fmt.Errorf("db: invalid GeoJSON %s coordinates: %w", object.Type, err)
+	}
+	return g, nil
+}
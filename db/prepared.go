@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Prepare registers sql under name on the DB created by InitDB; see DB.Prepare
+func Prepare(ctx context.Context, name, sql string) error {
+	return defaultDB().Prepare(ctx, name, sql)
+}
+
+// Prepare registers sql as the prepared statement name, so FetchPrepared and
+// ExecPrepared can run it without the server parsing and planning it on every
+// call. The statement is prepared right away on the idle connections, on
+// every connection the pool opens from now on, and on a busy connection the
+// first time it runs the statement. Registering a name again replaces its SQL.
+func (d *DB) Prepare(ctx context.Context, name, sql string) error {
+	if err := d.checkSQL(sql); err != nil {
+		return err
+	}
+	pool := d.Pool()
+	if pool == nil {
+		return errors.New("db: Prepare called before InitDB")
+	}
+
+	d.preparedMu.Lock()
+	if d.prepared == nil {
+		d.prepared = make(map[string]string)
+	}
+	d.prepared[name] = sql
+	d.preparedMu.Unlock()
+
+	for _, conn := range pool.AcquireAllIdle(ctx) {
+		err := prepareOn(ctx, conn.Conn(), name, sql)
+		conn.Release()
+		if err != nil {
+			return fmt.Errorf("error preparing statement %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// FetchPrepared runs the prepared statement name on the package-level Pool and returns every row as a map
+func FetchPrepared(ctx context.Context, name string, args ...interface{}) ([]map[string]interface{}, error) {
+	return defaultDB().FetchPrepared(ctx, name, args...)
+}
+
+// FetchPrepared runs the statement registered as name with args and returns
+// every row as a map, like FetchDataFromTable
+func (d *DB) FetchPrepared(ctx context.Context, name string, args ...interface{}) ([]map[string]interface{}, error) {
+	call, args := d.startCall(ctx, OpFetch, d.preparedSQL(name), args)
+	defer call.done()
+
+	result, err := d.fetchPrepared(call.ctx, name, args, call.options.decode)
+	call.finish(len(result), err)
+	if err != nil {
+		return nil, call.wrapErr(err)
+	}
+	if err := d.transformRows(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// fetchPrepared runs the statement of FetchPrepared
+func (d *DB) fetchPrepared(ctx context.Context, name string, args []interface{}, decode decodeOptions) ([]map[string]interface{}, error) {
+	conn, release, err := d.acquirePrepared(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	rows, err := conn.Query(ctx, name, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows(rows, decode)
+}
+
+// ExecPrepared runs the prepared statement name on the package-level Pool and returns the number of rows affected
+func ExecPrepared(ctx context.Context, name string, args ...interface{}) (int64, error) {
+	return defaultDB().ExecPrepared(ctx, name, args...)
+}
+
+// ExecPrepared runs the statement registered as name with args and returns
+// the number of rows affected, like Exec
+func (d *DB) ExecPrepared(ctx context.Context, name string, args ...interface{}) (int64, error) {
+	call, args := d.startCall(ctx, OpExec, d.preparedSQL(name), args)
+	defer call.done()
+
+	affected, err := d.execPrepared(call.ctx, name, args)
+	call.finish(int(affected), err)
+	return affected, call.wrapErr(err)
+}
+
+// execPrepared runs the statement of ExecPrepared
+func (d *DB) execPrepared(ctx context.Context, name string, args []interface{}) (int64, error) {
+	conn, release, err := d.acquirePrepared(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	tag, err := conn.Exec(ctx, name, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// preparedSQL returns the SQL registered as name, or "" when there is none
+func (d *DB) preparedSQL(name string) string {
+	d.preparedMu.RLock()
+	defer d.preparedMu.RUnlock()
+	return d.prepared[name]
+}
+
+// acquirePrepared acquires a connection on which the statement name is prepared
+func (d *DB) acquirePrepared(ctx context.Context, name string) (*pgxpool.Conn, func(), error) {
+	d.preparedMu.RLock()
+	sql, ok := d.prepared[name]
+	d.preparedMu.RUnlock()
+	if !ok {
+		return nil, nil, fmt.Errorf("db: no prepared statement named %q, register it with Prepare", name)
+	}
+
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := prepareOn(ctx, conn.Conn(), name, sql); err != nil {
+		release()
+		return nil, nil, fmt.Errorf("error preparing statement %s: %w", name, err)
+	}
+
+	return conn, release, nil
+}
+
+// prepareConn prepares every registered statement on a new connection
+func (d *DB) prepareConn(ctx context.Context, conn *pgx.Conn) error {
+	d.preparedMu.RLock()
+	defer d.preparedMu.RUnlock()
+
+	for name, sql := range d.prepared {
+		if err := prepareOn(ctx, conn, name, sql); err != nil {
+			return fmt.Errorf("error preparing statement %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// prepareOn prepares sql as name on conn. pgx skips statements the connection
+// already has with the same SQL; one registered under name with other SQL is
+// deallocated and prepared again.
+func prepareOn(ctx context.Context, conn *pgx.Conn, name, sql string) error {
+	_, err := conn.Prepare(ctx, name, sql)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "42P05" { // duplicate_prepared_statement
+		if err := conn.Deallocate(ctx, name); err != nil {
+			return err
+		}
+		_, err = conn.Prepare(ctx, name, sql)
+	}
+
+	return err
+}
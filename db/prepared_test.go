@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrepareUsesTheDefaultDB(t *testing.T) {
+	tests := []struct {
+		name    string
+		init    bool
+		wantErr bool
+	}{
+		{"before InitDB", false, true},
+		{"after InitDB", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.init {
+				initLazyDB(t)
+			}
+
+			err := Prepare(context.Background(), "latest", "SELECT 1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Prepare error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// FetchPrepared and ExecPrepared look the statement up on the same DB
+			if sql := defaultDB().preparedSQL("latest"); sql != "SELECT 1" {
+				t.Errorf("statement registered on the default DB = %q, want %q", sql, "SELECT 1")
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// progressCounter counts the rows handed to COPY by one bulk call
+type progressCounter struct {
+	every  int64
+	fn     func(rowsCopied int64)
+	copied atomic.Int64
+}
+
+// WithProgress calls fn with the number of rows copied so far every time
+// another every rows have been handed to COPY, across all chunks of the call,
+// so long loads can report progress or feed a watchdog. fn runs on the
+// goroutine feeding COPY and should return quickly; with WithParallelCopy it
+// is called from several goroutines at once. every <= 0 disables it.
+func WithProgress(every int64, fn func(rowsCopied int64)) BulkOption {
+	return func(o *bulkOptions) {
+		if every <= 0 || fn == nil {
+			o.progress = nil
+			return
+		}
+		o.progress = &progressCounter{every: every, fn: fn}
+	}
+}
+
+// withProgress wraps src so it reports to the progress callback of options, if any
+func withProgress(src pgx.CopyFromSource, options *bulkOptions) pgx.CopyFromSource {
+	if options.progress == nil {
+		return src
+	}
+	return &progressCopyFromSource{CopyFromSource: src, counter: options.progress}
+}
+
+// progressCopyFromSource is a pgx.CopyFromSource counting the rows read from
+// the source it wraps
+type progressCopyFromSource struct {
+	pgx.CopyFromSource
+	counter *progressCounter
+}
+
+// Next implements the pgx.CopyFromSource interface
+func (p *progressCopyFromSource) Next() bool {
+	if !p.CopyFromSource.Next() {
+		return false
+	}
+	if n := p.counter.copied.Add(1); n%p.counter.every == 0 {
+		p.counter.fn(n)
+	}
+	return true
+}
@@ -0,0 +1,357 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrNoRows is returned by FetchOne when the query returns no rows
+var ErrNoRows = errors.New("db: no rows in result set")
+
+// Querier is the set of database operations offered by this package. Code that
+// depends on Querier instead of the package-level functions can be unit-tested
+// with a fake implementation.
+type Querier interface {
+	FetchDataFromTable(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error)
+	FetchOne(ctx context.Context, query string, args ...interface{}) (map[string]interface{}, error)
+	Exec(ctx context.Context, sql string, args ...interface{}) (int64, error)
+	InsertBulkData(ctx context.Context, data []map[string]interface{}, table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) error
+}
+
+// DB is a handle on a connection pool that implements Querier
+type DB struct {
+	mu       sync.RWMutex
+	pool     *pgxpool.Pool
+	config   *DatabaseConfig
+	poolOpts *poolOptions
+
+	reconnectMu sync.Mutex
+	reconnect   *reconnectCall
+
+	// swapMu serializes pool replacements by Reconnect and ReloadConfig
+	swapMu sync.Mutex
+
+	// replicas serve FetchReadOnly; replicaNext picks the next one
+	replicas    []*replica
+	replicaNext atomic.Uint32
+
+	// zone is the FetchLocation of the config
+	zone *time.Location
+
+	// metrics is set by WithMetrics and tracer by WithTracer
+	metrics *Metrics
+	tracer  Tracer
+
+	// slowQuery holds the slow query settings of the config
+	slowQuery slowQueryLog
+
+	// published is the pool InitDB assigned to the package-level Pool; while
+	// Pool still holds it, the package-level functions use this DB
+	published *pgxpool.Pool
+
+	// logger is set by WithLogger and counters are the totals of Stats
+	logger   pgx.Logger
+	counters callCounters
+
+	// audit writes the audit entries of the DB, nil when nothing is audited
+	audit *auditLog
+
+	// prepared maps the names registered with Prepare to their SQL
+	preparedMu sync.RWMutex
+	prepared   map[string]string
+}
+
+// reconnectCall is a Reconnect in progress that concurrent callers wait on
+type reconnectCall struct {
+	done chan struct{}
+	err  error
+}
+
+// std is the DB created by InitDB; it backs the package-level functions
+var std atomic.Pointer[DB]
+
+// Client is a database client that owns its connection pool and exposes every
+// operation of the package as a method. It is the same type as DB.
+type Client = DB
+
+var _ Querier = (*DB)(nil)
+
+// NewDB returns a DB that runs its operations on pool
+func NewDB(pool *pgxpool.Pool) *DB {
+	return &DB{pool: pool}
+}
+
+// Connect opens a new Client from config. Unlike InitDB it leaves the
+// package-level Pool untouched, so several clients can coexist.
+func Connect(ctx context.Context, config *DatabaseConfig, opts ...PoolOption) (*Client, error) {
+	return openDB(ctx, config, newPoolOptions(opts))
+}
+
+// openDB connects a new DB from config, keeping the config and options for Reconnect
+func openDB(ctx context.Context, config *DatabaseConfig, options *poolOptions) (*DB, error) {
+	d := &DB{config: config, poolOpts: options}
+	// Statements registered with Prepare are prepared on every new connection
+	options.afterConnect = append(options.afterConnect, d.prepareConn)
+
+	pool, err := newPool(ctx, config, options)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas, err := openReplicas(ctx, config, options)
+	if err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	d.pool = pool
+	d.replicas = replicas
+	d.zone = config.fetchLocation()
+	d.slowQuery = newSlowQueryLog(config, options)
+	d.metrics = options.metrics
+	d.metrics.attach(d)
+	d.tracer = options.tracer
+	d.logger = options.logger
+	d.audit = newAuditLog(d, options)
+	return d, nil
+}
+
+// defaultDB returns the DB created by InitDB, which follows Reconnect and
+// ReloadConfig, or a DB backed by the package-level Pool when it was replaced
+func defaultDB() *DB {
+	if d := std.Load(); d != nil && d.published == Pool {
+		return d
+	}
+	return &DB{pool: Pool}
+}
+
+// Pool returns the connection pool used by the DB
+func (d *DB) Pool() *pgxpool.Pool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.pool
+}
+
+// Close closes the DB's connection pool and the pools of its replicas
+func (d *DB) Close() {
+	if pool := d.Pool(); pool != nil {
+		pool.Close()
+	}
+
+	d.mu.RLock()
+	replicas := d.replicas
+	d.mu.RUnlock()
+	closeReplicas(replicas)
+}
+
+// Reconnect rebuilds the pool of the DB created by InitDB from its config
+func Reconnect(ctx context.Context) error {
+	d := std.Load()
+	if d == nil {
+		return errors.New("db: Reconnect called before InitDB")
+	}
+	return d.Reconnect(ctx)
+}
+
+// Reconnect builds a new pool from the DB's stored config, swaps it in and
+// closes the old pool once its connections are released. If connecting fails
+// the old pool is kept. Callers that arrive while a reconnect is already
+// running wait for it and share its result instead of creating another pool.
+func (d *DB) Reconnect(ctx context.Context) error {
+	d.reconnectMu.Lock()
+	if call := d.reconnect; call != nil {
+		d.reconnectMu.Unlock()
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &reconnectCall{done: make(chan struct{})}
+	d.reconnect = call
+	d.reconnectMu.Unlock()
+
+	call.err = d.swapPool(ctx, nil)
+	close(call.done)
+
+	d.reconnectMu.Lock()
+	d.reconnect = nil
+	d.reconnectMu.Unlock()
+
+	return call.err
+}
+
+// ReloadConfig switches the DB created by InitDB to config; see DB.ReloadConfig.
+// The package-level functions move to the new pool, but the Pool variable
+// keeps the one InitDB created; use CurrentPool.
+func ReloadConfig(ctx context.Context, config *DatabaseConfig) error {
+	d := std.Load()
+	if d == nil {
+		return errors.New("db: ReloadConfig called before InitDB")
+	}
+	return d.ReloadConfig(ctx, config)
+}
+
+// ReloadConfig connects a new pool with config while the current pool keeps
+// serving, then atomically swaps them and drains the old pool in the
+// background: queries already running on it finish, new ones use the new pool.
+// Use it for password rotation or host changes. On error nothing changes.
+func (d *DB) ReloadConfig(ctx context.Context, config *DatabaseConfig) error {
+	if config == nil {
+		return errors.New("db: ReloadConfig called with a nil config")
+	}
+	return d.swapPool(ctx, config)
+}
+
+// swapPool replaces the DB's pool with one freshly connected from config,
+// or from the stored config when config is nil
+func (d *DB) swapPool(ctx context.Context, config *DatabaseConfig) error {
+	d.swapMu.Lock()
+	defer d.swapMu.Unlock()
+
+	d.mu.RLock()
+	if config == nil {
+		config = d.config
+	}
+	options := d.poolOpts
+	d.mu.RUnlock()
+
+	if config == nil {
+		return errors.New("db: no config to reconnect with")
+	}
+	if options == nil {
+		options = &poolOptions{}
+	}
+
+	pool, err := newPool(ctx, config, options)
+	if err != nil {
+		return err
+	}
+
+	replicas, err := openReplicas(ctx, config, options)
+	if err != nil {
+		pool.Close()
+		return err
+	}
+
+	d.mu.Lock()
+	old, oldReplicas := d.pool, d.replicas
+	d.pool = pool
+	d.replicas = replicas
+	d.config = config
+	d.zone = config.fetchLocation()
+	d.slowQuery = newSlowQueryLog(config, options)
+	d.mu.Unlock()
+
+	// Close blocks until every acquired connection is released
+	if old != nil {
+		go old.Close()
+	}
+	go closeReplicas(oldReplicas)
+
+	return nil
+}
+
+// FetchOne executes query on the package-level Pool and returns the first row
+// as a map. It returns ErrNoRows when the query produces no rows.
+func FetchOne(ctx context.Context, query string, args ...interface{}) (map[string]interface{}, error) {
+	return defaultDB().FetchOne(ctx, query, args...)
+}
+
+// FetchOne executes query with args and returns the first row as a map.
+// It returns ErrNoRows when the query produces no rows.
+func (d *DB) FetchOne(ctx context.Context, query string, args ...interface{}) (map[string]interface{}, error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, err
+	}
+
+	call, args := d.startCall(ctx, OpFetch, query, args)
+	defer call.done()
+
+	row, err := d.fetchOne(call.ctx, query, args, call.options.decode)
+	switch {
+	case err == nil:
+		call.finish(1, nil)
+	case errors.Is(err, ErrNoRows):
+		call.finish(0, nil)
+	default:
+		call.finish(0, err)
+	}
+	if err != nil {
+		return nil, call.wrapErr(err)
+	}
+	if err := d.transformRow(row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// fetchOne runs the query of FetchOne
+func (d *DB) fetchOne(ctx context.Context, query string, args []interface{}, decode decodeOptions) (map[string]interface{}, error) {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Execute the query
+	rows, err := conn.Query(ctx, d.tagSQL(ctx, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNoRows
+	}
+
+	return scanRowMap(rows, columnNames(rows), decode)
+}
+
+// Exec executes an UPDATE, DELETE, DDL or other statement on the package-level
+// Pool and returns the number of rows affected
+func Exec(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	return defaultDB().Exec(ctx, sql, args...)
+}
+
+// Exec executes sql with args and returns the number of rows affected
+func (d *DB) Exec(ctx context.Context, sql string, args ...interface{}) (int64, error) {
+	if err := d.checkSQL(sql); err != nil {
+		return 0, err
+	}
+
+	call, args := d.startCall(ctx, OpExec, sql, args)
+	defer call.done()
+
+	affected, err := d.exec(call.ctx, sql, args)
+	call.finish(int(affected), err)
+	return affected, call.wrapErr(err)
+}
+
+// exec runs the statement of Exec
+func (d *DB) exec(ctx context.Context, sql string, args []interface{}) (int64, error) {
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	// Execute the statement
+	tag, err := conn.Exec(ctx, d.tagSQL(ctx, sql), args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
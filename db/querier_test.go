@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// lazyConfig is a config whose pools are created without dialing
+func lazyConfig() *DatabaseConfig {
+	return &DatabaseConfig{Host: "127.0.0.1", Port: 1, User: "test", DBName: "test", LazyConnect: true}
+}
+
+// initLazyDB runs InitDB with lazyConfig and restores the package state when the test ends
+func initLazyDB(t *testing.T) {
+	t.Helper()
+	if err := InitDB(lazyConfig()); err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() {
+		Close()
+		Pool = nil
+		std.Store(nil)
+	})
+}
+
+func TestReconnectMovesPackageFunctionsToTheNewPool(t *testing.T) {
+	initLazyDB(t)
+	initial := Pool
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := Reconnect(context.Background()); err != nil {
+				t.Errorf("Reconnect: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			// The package-level functions read the current pool concurrently
+			_ = CurrentPool()
+			_ = defaultDB().Pool()
+		}()
+	}
+	wg.Wait()
+
+	if Pool != initial {
+		t.Error("Reconnect assigned the package-level Pool")
+	}
+	if CurrentPool() == initial {
+		t.Error("CurrentPool still returns the pool InitDB created")
+	}
+	if defaultDB() != std.Load() {
+		t.Error("the package-level functions no longer use the DB created by InitDB")
+	}
+}
+
+func TestReloadConfigWhileQuerying(t *testing.T) {
+	initLazyDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var readers sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for ctx.Err() == nil {
+				_ = CurrentPool()
+				_ = Stats()
+			}
+		}()
+	}
+
+	for _, appName := range []string{"first", "second", "third"} {
+		config := lazyConfig()
+		config.ApplicationName = appName
+		if err := ReloadConfig(ctx, config); err != nil {
+			t.Fatalf("ReloadConfig: %v", err)
+		}
+		if got := CurrentPool().Config().ConnConfig.RuntimeParams["application_name"]; got != appName {
+			t.Errorf("application_name = %q after reloading %q", got, appName)
+		}
+	}
+	cancel()
+	readers.Wait()
+
+	if err := ReloadConfig(context.Background(), nil); err == nil {
+		t.Error("ReloadConfig accepted a nil config")
+	}
+}
+
+func TestDefaultDBFollowsAnAssignedPool(t *testing.T) {
+	initLazyDB(t)
+	if err := Reconnect(context.Background()); err != nil {
+		t.Fatalf("Reconnect: %v", err)
+	}
+
+	other, err := newPool(context.Background(), lazyConfig(), &poolOptions{})
+	if err != nil {
+		t.Fatalf("newPool: %v", err)
+	}
+	defer other.Close()
+
+	Pool = other
+	if d := defaultDB(); d == std.Load() || d.Pool() != Pool {
+		t.Error("defaultDB ignores a Pool assigned by the caller")
+	}
+}
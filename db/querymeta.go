@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// QueryMeta describes how a query ran, for showing "took 12ms, 40 rows" next
+// to a result
+type QueryMeta struct {
+	// Duration is the time from the start of the call to the last row,
+	// including the wait for a connection
+	Duration time.Duration
+	RowCount int
+	// ColumnTypes holds the result columns in order
+	ColumnTypes []ColumnInfo
+	// ConnPID is the process ID of the backend that ran the query, 0 when
+	// no connection was acquired
+	ConnPID uint32
+}
+
+// ColumnInfo is a result column and its PostgreSQL type
+type ColumnInfo struct {
+	Name string
+	// Type is the name of the type, such as "int8" or "timestamptz", or ""
+	// when the type is not registered, as for enums and domains
+	Type string
+	OID  uint32
+}
+
+// FetchWithMeta is Fetch also returning the QueryMeta of the query. The meta
+// is filled as far as the query got when it fails.
+func FetchWithMeta[T any](ctx context.Context, query string, args ...interface{}) ([]T, QueryMeta, error) {
+	return FetchWithMetaOn[T](ctx, defaultDB(), query, args...)
+}
+
+// FetchWithMetaOn is FetchWithMeta running on the pool of d
+func FetchWithMetaOn[T any](ctx context.Context, d *DB, query string, args ...interface{}) ([]T, QueryMeta, error) {
+	var meta QueryMeta
+	start := time.Now()
+	result, err := fetchStructs[T](ctx, d, 0, query, args, &meta)
+	meta.Duration = time.Since(start)
+	meta.RowCount = len(result)
+	return result, meta, err
+}
+
+// fillMeta records the backend and the result columns of rows, running on
+// conn, in meta
+func fillMeta(meta *QueryMeta, conn *pgxpool.Conn, rows pgx.Rows) {
+	if meta == nil {
+		return
+	}
+	meta.ConnPID = conn.Conn().PgConn().PID()
+
+	ci := conn.Conn().ConnInfo()
+	descs := rows.FieldDescriptions()
+	meta.ColumnTypes = make([]ColumnInfo, len(descs))
+	for i, desc := range descs {
+		meta.ColumnTypes[i] = ColumnInfo{Name: string(desc.Name), OID: desc.DataTypeOID}
+		if dt, ok := ci.DataTypeForOID(desc.DataTypeOID); ok {
+			meta.ColumnTypes[i].Type = dt.Name
+		}
+	}
+}
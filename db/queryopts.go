@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQueryTimeout is returned, wrapping the driver error, when a call runs
+// longer than the limit set with WithTimeout
+var ErrQueryTimeout = errors.New("db: query timed out")
+
+// QueryOption configures a single fetch or exec call. It is passed among the
+// query arguments and removed before they are bound to the placeholders:
+//
+//	rows, err := db.FetchDataFromTable(ctx, "SELECT * FROM trades WHERE symbol = $1",
+//		db.WithTimeout(5*time.Second), symbol)
+type QueryOption func(*queryOptions)
+
+// queryOptions holds the settings of a single call
+type queryOptions struct {
+	timeout time.Duration
+	handle  *QueryHandle
+	cache   *QueryCache
+
+	decode decodeOptions
+}
+
+// WithTimeout limits how long the call may run, including acquiring the
+// connection. When the limit is reached the statement is canceled and the
+// call fails with an error matching ErrQueryTimeout.
+func WithTimeout(timeout time.Duration) QueryOption {
+	return func(o *queryOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithNulls guarantees that every column of the result appears in each row
+// map, with a nil value for NULL, so consumers can tell a missing column
+// from a NULL from a zero value. Without it, values the driver reports as
+// not present are left out of the map. It applies to the functions returning
+// maps; FetchRows and FetchColumns always report NULL as nil, and Fetch can
+// scan NULL into pointer or pgtype fields.
+func WithNulls() QueryOption {
+	return func(o *queryOptions) {
+		o.decode.keepNulls = true
+	}
+}
+
+// queryCall is a call in progress with its QueryOptions applied
+type queryCall struct {
+	parent  context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+	options queryOptions
+
+	// Set by startCall; table and inputRows by setTable
+	op        string
+	statement string
+	table     string
+	inputRows int
+	start     time.Time
+	metrics   *Metrics
+	counters  *callCounters
+	span      Span
+	audit     *auditLog
+
+	// slowQuery and poolWait are set when slow queries are logged
+	slowQuery slowQueryLog
+	poolWait  *atomic.Int64
+}
+
+// startQuery removes the QueryOptions from args and applies them to ctx. It
+// returns the call, whose ctx must be used for the query, and the remaining
+// arguments. The caller must call done when the call is over.
+func startQuery(ctx context.Context, args []interface{}) (*queryCall, []interface{}) {
+	call := &queryCall{parent: ctx, ctx: ctx, cancel: func() {}}
+
+	var remaining []interface{}
+	for i, arg := range args {
+		opt, ok := arg.(QueryOption)
+		if !ok {
+			if remaining != nil {
+				remaining = append(remaining, arg)
+			}
+			continue
+		}
+		if remaining == nil {
+			remaining = append(make([]interface{}, 0, len(args)), args[:i]...)
+		}
+		opt(&call.options)
+	}
+	if remaining == nil {
+		remaining = args
+	}
+
+	if call.options.timeout > 0 {
+		call.ctx, call.cancel = context.WithTimeout(ctx, call.options.timeout)
+	}
+	if call.options.handle != nil {
+		call.ctx = context.WithValue(call.ctx, queryHandleKey{}, call.options.handle)
+	}
+
+	return call, remaining
+}
+
+// startQuery is startQuery with the decoding settings of the DB's config
+func (d *DB) startQuery(ctx context.Context, args []interface{}) (*queryCall, []interface{}) {
+	call, args := startQuery(ctx, args)
+
+	d.mu.RLock()
+	call.options.decode.zone = d.zone
+	d.mu.RUnlock()
+
+	return call, args
+}
+
+// done releases the resources of the call
+func (c *queryCall) done() {
+	c.cancel()
+}
+
+// wrapErr converts err into an ErrQueryCanceled error when the call's
+// QueryHandle was canceled, and into an ErrQueryTimeout error when the call
+// ran past its own timeout, rather than being canceled by the caller's context
+func (c *queryCall) wrapErr(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	canceled := c.options.handle != nil && c.options.handle.Canceled() && !errors.Is(err, ErrQueryCanceled)
+	timedOut := c.options.timeout > 0 && c.parent.Err() == nil && c.ctx.Err() == context.DeadlineExceeded
+	if !canceled && !timedOut {
+		return err
+	}
+
+	var partial *PartialResultError
+	if errors.As(err, &partial) {
+		partial.Err = c.wrapErr(partial.Err)
+		return err
+	}
+
+	if canceled {
+		return fmt.Errorf("%w: %w", ErrQueryCanceled, err)
+	}
+	return fmt.Errorf("%w after %s: %w", ErrQueryTimeout, c.options.timeout, err)
+}
+
+// optionArgs returns opts as query arguments, to be picked up by startQuery
+func optionArgs(args []interface{}, opts []QueryOption) []interface{} {
+	if len(opts) == 0 {
+		return args
+	}
+	out := append(make([]interface{}, 0, len(args)+len(opts)), args...)
+	for _, opt := range opts {
+		out = append(out, opt)
+	}
+	return out
+}
+
+// withoutQueryOptions returns args without the QueryOptions among them
+func withoutQueryOptions(args []interface{}) []interface{} {
+	remaining := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		if _, ok := arg.(QueryOption); !ok {
+			remaining = append(remaining, arg)
+		}
+	}
+	return remaining
+}
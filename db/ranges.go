@@ -0,0 +1,276 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/shopspring/decimal"
+)
+
+// Range is the value of a range column. tstzrange, tsrange and daterange
+// columns are returned as Range[time.Time], int4range as Range[int32],
+// int8range as Range[int64] and numrange as Range[float64], or as
+// Range[decimal.Decimal] or Range[string] as set by WithNumericMode.
+//
+// Range[time.Time] is bulk loaded as a tstzrange, Range[int32] as an
+// int4range, Range[int] and Range[int64] as an int8range and Range[float64]
+// and Range[decimal.Decimal] as a numrange; with WithColumnTypeDetection they
+// are converted to the range type of their column, and so is range text such
+// as "[1,10)".
+type Range[T any] struct {
+	Lower T
+	Upper T
+
+	// Bounds holds the brackets of the range: "[)", "[]", "(]" or "()". An
+	// empty Bounds means "[)", the default of the range constructors.
+	Bounds string
+
+	// LowerInf and UpperInf mark unbounded ends, whose value is ignored
+	LowerInf bool
+	UpperInf bool
+
+	// Empty is the empty range, which has no bounds
+	Empty bool
+}
+
+// boundTypes returns the pgtype bound types of r
+func (r Range[T]) boundTypes() (lower, upper pgtype.BoundType, err error) {
+	if r.Empty {
+		return pgtype.Empty, pgtype.Empty, nil
+	}
+
+	bounds := r.Bounds
+	if bounds == "" {
+		bounds = "[)"
+	}
+	if len(bounds) != 2 || (bounds[0] != '[' && bounds[0] != '(') || (bounds[1] != ']' && bounds[1] != ')') {
+		return 0, 0, fmt.Errorf("db: invalid range bounds %q", r.Bounds)
+	}
+
+	lower, upper = pgtype.Exclusive, pgtype.Exclusive
+	if bounds[0] == '[' {
+		lower = pgtype.Inclusive
+	}
+	if bounds[1] == ']' {
+		upper = pgtype.Inclusive
+	}
+	if r.LowerInf {
+		lower = pgtype.Unbounded
+	}
+	if r.UpperInf {
+		upper = pgtype.Unbounded
+	}
+	return lower, upper, nil
+}
+
+// nativeRange builds the Range of a decoded range with bound types lowerType
+// and upperType; the bounds are only converted for the ends that have one
+func nativeRange[T any](lowerType, upperType pgtype.BoundType, lower, upper func() T) Range[T] {
+	var r Range[T]
+	if lowerType == pgtype.Empty {
+		r.Empty = true
+		return r
+	}
+
+	brackets := []byte("()")
+	switch lowerType {
+	case pgtype.Inclusive:
+		brackets[0] = '['
+		r.Lower = lower()
+	case pgtype.Exclusive:
+		r.Lower = lower()
+	case pgtype.Unbounded:
+		r.LowerInf = true
+	}
+	switch upperType {
+	case pgtype.Inclusive:
+		brackets[1] = ']'
+		r.Upper = upper()
+	case pgtype.Exclusive:
+		r.Upper = upper()
+	case pgtype.Unbounded:
+		r.UpperInf = true
+	}
+	r.Bounds = string(brackets)
+	return r
+}
+
+// nativeNumrange converts v to the Range type of the numeric mode
+func nativeNumrange(v pgtype.Numrange, mode NumericMode) interface{} {
+	switch mode {
+	case NumericDecimal:
+		return nativeRange(v.LowerType, v.UpperType,
+			func() decimal.Decimal { return numericDecimal(v.Lower) },
+			func() decimal.Decimal { return numericDecimal(v.Upper) })
+	case NumericString:
+		return nativeRange(v.LowerType, v.UpperType,
+			func() string { return numericDecimal(v.Lower).String() },
+			func() string { return numericDecimal(v.Upper).String() })
+	}
+	return nativeRange(v.LowerType, v.UpperType,
+		func() float64 { return numericDecimal(v.Lower).InexactFloat64() },
+		func() float64 { return numericDecimal(v.Upper).InexactFloat64() })
+}
+
+// numericDecimal returns the value of a finite numeric
+func numericDecimal(v pgtype.Numeric) decimal.Decimal {
+	if v.Int == nil {
+		return decimal.Zero
+	}
+	return decimal.NewFromBigInt(v.Int, v.Exp)
+}
+
+// timeRangeValue converts r to the range of typ: a tsrange, a daterange or,
+// by default, a tstzrange
+func timeRangeValue(r Range[time.Time], typ string) (interface{}, error) {
+	lowerType, upperType, err := r.boundTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case "tsrange":
+		return pgtype.Tsrange{
+			Lower:     timeValue(r.Lower, "timestamp").(pgtype.Timestamp),
+			Upper:     timeValue(r.Upper, "timestamp").(pgtype.Timestamp),
+			LowerType: lowerType,
+			UpperType: upperType,
+			Status:    pgtype.Present,
+		}, nil
+	case "daterange":
+		return pgtype.Daterange{
+			Lower:     timeValue(r.Lower, "date").(pgtype.Date),
+			Upper:     timeValue(r.Upper, "date").(pgtype.Date),
+			LowerType: lowerType,
+			UpperType: upperType,
+			Status:    pgtype.Present,
+		}, nil
+	}
+	return pgtype.Tstzrange{
+		Lower:     pgtype.Timestamptz{Time: r.Lower, Status: pgtype.Present},
+		Upper:     pgtype.Timestamptz{Time: r.Upper, Status: pgtype.Present},
+		LowerType: lowerType,
+		UpperType: upperType,
+		Status:    pgtype.Present,
+	}, nil
+}
+
+// intRangeValue converts r to an int4range when typ asks for one and to an
+// int8range otherwise
+func intRangeValue(r Range[int64], typ string) (interface{}, error) {
+	lowerType, upperType, err := r.boundTypes()
+	if err != nil {
+		return nil, err
+	}
+
+	if typ == "int4range" {
+		for _, bound := range []int64{r.Lower, r.Upper} {
+			if bound < math.MinInt32 || bound > math.MaxInt32 {
+				return nil, fmt.Errorf("db: range bound %d does not fit in an int4range", bound)
+			}
+		}
+		return pgtype.Int4range{
+			Lower:     pgtype.Int4{Int: int32(r.Lower), Status: pgtype.Present},
+			Upper:     pgtype.Int4{Int: int32(r.Upper), Status: pgtype.Present},
+			LowerType: lowerType,
+			UpperType: upperType,
+			Status:    pgtype.Present,
+		}, nil
+	}
+	return pgtype.Int8range{
+		Lower:     pgtype.Int8{Int: r.Lower, Status: pgtype.Present},
+		Upper:     pgtype.Int8{Int: r.Upper, Status: pgtype.Present},
+		LowerType: lowerType,
+		UpperType: upperType,
+		Status:    pgtype.Present,
+	}, nil
+}
+
+// numRangeValue converts r to a numrange
+func numRangeValue(r Range[decimal.Decimal]) (interface{}, error) {
+	lowerType, upperType, err := r.boundTypes()
+	if err != nil {
+		return nil, err
+	}
+	return pgtype.Numrange{
+		Lower:     pgtype.Numeric{Int: r.Lower.Coefficient(), Exp: r.Lower.Exponent(), Status: pgtype.Present},
+		Upper:     pgtype.Numeric{Int: r.Upper.Coefficient(), Exp: r.Upper.Exponent(), Status: pgtype.Present},
+		LowerType: lowerType,
+		UpperType: upperType,
+		Status:    pgtype.Present,
+	}, nil
+}
+
+// rangeValue converts value, a Range, to the range type it is loaded as by
+// default, or to typ when it is set. ok is false when value is not a Range.
+func rangeValue(value interface{}, typ string) (encoded interface{}, ok bool, err error) {
+	switch v := value.(type) {
+	case Range[time.Time]:
+		encoded, err = timeRangeValue(v, typ)
+	case Range[int32]:
+		if typ == "" {
+			typ = "int4range"
+		}
+		encoded, err = intRangeValue(Range[int64]{Lower: int64(v.Lower), Upper: int64(v.Upper), Bounds: v.Bounds, LowerInf: v.LowerInf, UpperInf: v.UpperInf, Empty: v.Empty}, typ)
+	case Range[int]:
+		encoded, err = intRangeValue(Range[int64]{Lower: int64(v.Lower), Upper: int64(v.Upper), Bounds: v.Bounds, LowerInf: v.LowerInf, UpperInf: v.UpperInf, Empty: v.Empty}, typ)
+	case Range[int64]:
+		encoded, err = intRangeValue(v, typ)
+	case Range[float64]:
+		encoded, err = numRangeValue(Range[decimal.Decimal]{Lower: decimal.NewFromFloat(v.Lower), Upper: decimal.NewFromFloat(v.Upper), Bounds: v.Bounds, LowerInf: v.LowerInf, UpperInf: v.UpperInf, Empty: v.Empty})
+	case Range[decimal.Decimal]:
+		encoded, err = numRangeValue(v)
+	default:
+		return nil, false, nil
+	}
+	return encoded, true, err
+}
+
+// coerceRange converts value, a Range or range text such as "[1,10)", to a
+// value of the range type typ
+func coerceRange(value interface{}, typ string) (interface{}, error) {
+	if s, ok := value.(string); ok {
+		dt, ok := copyConnInfo.DataTypeForName(typ)
+		if !ok {
+			return value, nil
+		}
+		decoded := pgtype.NewValue(dt.Value).(pgtype.TextDecoder)
+		if err := decoded.DecodeText(copyConnInfo, []byte(s)); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	}
+
+	encoded, ok, err := rangeValue(value, typ)
+	if !ok {
+		return value, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if rangeTypeName(encoded) != typ {
+		return nil, fmt.Errorf("cannot use %T for a column of type %s", value, typ)
+	}
+	return encoded, nil
+}
+
+// rangeTypeName returns the name of the range type of encoded
+func rangeTypeName(encoded interface{}) string {
+	switch encoded.(type) {
+	case pgtype.Tstzrange:
+		return "tstzrange"
+	case pgtype.Tsrange:
+		return "tsrange"
+	case pgtype.Daterange:
+		return "daterange"
+	case pgtype.Int4range:
+		return "int4range"
+	case pgtype.Int8range:
+		return "int8range"
+	case pgtype.Numrange:
+		return "numrange"
+	}
+	return ""
+}
@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// AuthModeRDSIAM makes InitDB authenticate with RDS IAM auth tokens
+const AuthModeRDSIAM = "rds-iam"
+
+// rdsAuthTokenExpiry is how long a generated RDS auth token stays valid
+const rdsAuthTokenExpiry = 15 * time.Minute
+
+// AuthTokenFunc returns the password for a single new connection. It is called
+// every time the pool dials, so short-lived tokens are always fresh.
+type AuthTokenFunc func(ctx context.Context, connConfig *pgx.ConnConfig) (string, error)
+
+// AWSCredentials are the AWS keys used to sign RDS auth tokens
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSCredentialsFunc returns the AWS credentials to sign with
+type AWSCredentialsFunc func(ctx context.Context) (AWSCredentials, error)
+
+// AWSCredentialsFromEnv reads AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+func AWSCredentialsFromEnv(ctx context.Context) (AWSCredentials, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return AWSCredentials{}, errors.New("db: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return creds, nil
+}
+
+// RDSIAMAuthToken returns an AuthTokenFunc that generates an RDS IAM auth
+// token for the connection's host, port and user, signed with the credentials
+// returned by creds. Plug in the AWS SDK's credential chain to use instance or
+// task roles. RDS only accepts these tokens over TLS.
+func RDSIAMAuthToken(region string, creds AWSCredentialsFunc) AuthTokenFunc {
+	return func(ctx context.Context, connConfig *pgx.ConnConfig) (string, error) {
+		awsCreds, err := creds(ctx)
+		if err != nil {
+			return "", fmt.Errorf("error getting AWS credentials: %w", err)
+		}
+		endpoint := net.JoinHostPort(connConfig.Host, strconv.Itoa(int(connConfig.Port)))
+		return buildRDSAuthToken(endpoint, region, connConfig.User, awsCreds, time.Now().UTC()), nil
+	}
+}
+
+// buildRDSAuthToken presigns an rds-db connect request with AWS Signature Version 4
+func buildRDSAuthToken(endpoint, region, user string, creds AWSCredentials, now time.Time) string {
+	const service = "rds-db"
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+
+	params := map[string]string{
+		"Action":              "connect",
+		"DBUser":              user,
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    creds.AccessKeyID + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.Itoa(int(rdsAuthTokenExpiry.Seconds())),
+		"X-Amz-SignedHeaders": "host",
+	}
+	if creds.SessionToken != "" {
+		params["X-Amz-Security-Token"] = creds.SessionToken
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = awsURIEncode(key) + "=" + awsURIEncode(params[key])
+	}
+	query := strings.Join(pairs, "&")
+
+	emptyPayloadHash := sha256.Sum256(nil)
+	canonicalRequest := strings.Join([]string{
+		"GET",
+		"/",
+		query,
+		"host:" + endpoint + "\n",
+		"host",
+		hex.EncodeToString(emptyPayloadHash[:]),
+	}, "\n")
+
+	signature := awsSignature(canonicalRequest, now, region, service, creds.SecretAccessKey)
+	return endpoint + "/?" + query + "&X-Amz-Signature=" + signature
+}
+
+// awsSignature returns the Signature Version 4 signature of canonicalRequest,
+// made at now for service in region
+func awsSignature(canonicalRequest string, now time.Time, region, service, secretAccessKey string) string {
+	date := now.Format("20060102")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		now.Format("20060102T150405Z"),
+		strings.Join([]string{date, region, service, "aws4_request"}, "/"),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(awsSigningKey(secretAccessKey, date, region, service), stringToSign))
+}
+
+// awsSigningKey derives the Signature Version 4 signing key of service in
+// region on date, formatted as 20060102
+func awsSigningKey(secretAccessKey, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEncode percent-encodes s the way Signature Version 4 expects
+func awsURIEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// applyAuthToken makes every new connection use a freshly generated auth token
+// as its password, when AuthToken is set or AuthMode asks for RDS IAM
+func (c *DatabaseConfig) applyAuthToken(poolConfig *pgxpool.Config) error {
+	authToken := c.AuthToken
+	if authToken == nil {
+		switch c.AuthMode {
+		case "":
+			return nil
+		case AuthModeRDSIAM:
+			if c.AWSRegion == "" {
+				return errors.New("db: awsRegion is required for rds-iam auth")
+			}
+			authToken = RDSIAMAuthToken(c.AWSRegion, AWSCredentialsFromEnv)
+		default:
+			return fmt.Errorf("db: unknown authMode %q", c.AuthMode)
+		}
+	}
+
+	poolConfig.BeforeConnect = chainBeforeConnect(poolConfig.BeforeConnect, func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		token, err := authToken(ctx, connConfig)
+		if err != nil {
+			return fmt.Errorf("error generating auth token: %w", err)
+		}
+		connConfig.Password = token
+		return nil
+	})
+
+	return nil
+}
+
+// chainBeforeConnect runs first and then next, stopping at the first error
+func chainBeforeConnect(first, next func(context.Context, *pgx.ConnConfig) error) func(context.Context, *pgx.ConnConfig) error {
+	if first == nil {
+		return next
+	}
+	return func(ctx context.Context, connConfig *pgx.ConnConfig) error {
+		if err := first(ctx, connConfig); err != nil {
+			return err
+		}
+		return next(ctx, connConfig)
+	}
+}
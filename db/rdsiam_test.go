@@ -0,0 +1,126 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The vectors below come from the AWS Signature Version 4 documentation and
+// test suite, which sign with these example keys
+const (
+	exampleAccessKeyID     = "AKIDEXAMPLE"
+	exampleSecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+)
+
+func TestAWSSigningKey(t *testing.T) {
+	key := awsSigningKey(exampleSecretAccessKey, "20120215", "us-east-1", "iam")
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("signing key = %s, want %s", got, want)
+	}
+}
+
+func TestAWSSignature(t *testing.T) {
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	emptyPayload := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	tests := []struct {
+		name             string
+		canonicalRequest string
+		want             string
+	}{
+		{
+			"get-vanilla",
+			"GET\n/\n\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" + emptyPayload,
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			"get-vanilla-query-order-key-case",
+			"GET\n/\nParam1=value1&Param2=value2\nhost:example.amazonaws.com\nx-amz-date:20150830T123600Z\n\nhost;x-amz-date\n" + emptyPayload,
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := awsSignature(tt.canonicalRequest, now, "us-east-1", "service", exampleSecretAccessKey); got != tt.want {
+				t.Errorf("signature = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildRDSAuthToken(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC)
+	endpoint := "mydb.123456789012.us-east-1.rds.amazonaws.com:5432"
+
+	tests := []struct {
+		name   string
+		creds  AWSCredentials
+		params map[string]string
+	}{
+		{
+			"long-term keys",
+			AWSCredentials{AccessKeyID: exampleAccessKeyID, SecretAccessKey: exampleSecretAccessKey},
+			nil,
+		},
+		{
+			"session token",
+			AWSCredentials{AccessKeyID: exampleAccessKeyID, SecretAccessKey: exampleSecretAccessKey, SessionToken: "token/with+chars="},
+			map[string]string{"X-Amz-Security-Token": "token/with+chars="},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := buildRDSAuthToken(endpoint, "us-east-1", "app user", tt.creds, now)
+
+			prefix := endpoint + "/?"
+			if !strings.HasPrefix(token, prefix) {
+				t.Fatalf("token %q does not start with %q", token, prefix)
+			}
+			query, signature, ok := strings.Cut(strings.TrimPrefix(token, prefix), "&X-Amz-Signature=")
+			if !ok {
+				t.Fatalf("token %q has no trailing signature", token)
+			}
+
+			values, err := url.ParseQuery(query)
+			if err != nil {
+				t.Fatalf("token query %q: %v", query, err)
+			}
+			want := map[string]string{
+				"Action":              "connect",
+				"DBUser":              "app user",
+				"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+				"X-Amz-Credential":    exampleAccessKeyID + "/20240301/us-east-1/rds-db/aws4_request",
+				"X-Amz-Date":          "20240301T102030Z",
+				"X-Amz-Expires":       "900",
+				"X-Amz-SignedHeaders": "host",
+			}
+			for k, v := range tt.params {
+				want[k] = v
+			}
+			if len(values) != len(want) {
+				t.Errorf("token has parameters %v, want %v", values, want)
+			}
+			for k, v := range want {
+				if got := values.Get(k); got != v {
+					t.Errorf("%s = %q, want %q", k, got, v)
+				}
+			}
+			// Spaces must be encoded as %20, never +
+			if strings.Contains(query, "+") {
+				t.Errorf("query %q is not encoded with %%20", query)
+			}
+
+			// The query is signed as it appears in the token, with the host header
+			emptyPayload := sha256.Sum256(nil)
+			canonical := "GET\n/\n" + query + "\nhost:" + endpoint + "\n\nhost\n" + hex.EncodeToString(emptyPayload[:])
+			if want := awsSignature(canonical, now, "us-east-1", "rds-db", exampleSecretAccessKey); signature != want {
+				t.Errorf("signature = %s, want %s", signature, want)
+			}
+		})
+	}
+}
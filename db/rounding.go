@@ -0,0 +1,130 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgtype"
+	"github.com/shopspring/decimal"
+)
+
+// RoundingMode is how a value is rounded to the scale of a Rounding
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds halves away from zero (the default)
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds halves to the even neighbour, as banks do
+	RoundHalfEven
+	// RoundDown truncates toward zero
+	RoundDown
+	// RoundUp rounds away from zero
+	RoundUp
+	// RoundFloor rounds toward negative infinity
+	RoundFloor
+	// RoundCeil rounds toward positive infinity
+	RoundCeil
+)
+
+// Rounding is the number of decimal places a column is rounded to and how
+type Rounding struct {
+	Scale int32
+	Mode  RoundingMode
+}
+
+// WithRounding rounds the numeric and floating point columns named in rules
+// as they are fetched by the call, so every consumer sees the same values.
+// Values keep the type set by WithNumericMode.
+func WithRounding(rules map[string]Rounding) QueryOption {
+	return func(o *queryOptions) {
+		o.decode.rounding = rules
+	}
+}
+
+// WithColumnRounding rounds the values of the columns named in rules before
+// they are loaded. float64, float32, decimal.Decimal and numeric strings are
+// rounded; other values are loaded as they are.
+func WithColumnRounding(rules map[string]Rounding) BulkOption {
+	return func(o *bulkOptions) {
+		o.rounding = rules
+	}
+}
+
+// round returns d rounded as set by r
+func (r Rounding) round(d decimal.Decimal) decimal.Decimal {
+	switch r.Mode {
+	case RoundHalfEven:
+		return d.RoundBank(r.Scale)
+	case RoundDown:
+		return d.RoundDown(r.Scale)
+	case RoundUp:
+		return d.RoundUp(r.Scale)
+	case RoundFloor:
+		return d.RoundFloor(r.Scale)
+	case RoundCeil:
+		return d.RoundCeil(r.Scale)
+	}
+	return d.Round(r.Scale)
+}
+
+// roundValue returns value rounded as set by r, keeping its type. Values that
+// are not numbers, NaN and infinities are returned as they are.
+func roundValue(value interface{}, r Rounding) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			return r.round(decimal.NewFromFloat(v)).InexactFloat64()
+		}
+	case float32:
+		if !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0) {
+			return float32(r.round(decimal.NewFromFloat32(v)).InexactFloat64())
+		}
+	case decimal.Decimal:
+		return r.round(v)
+	case string:
+		if d, err := decimal.NewFromString(strings.TrimSpace(v)); err == nil {
+			return r.round(d).StringFixed(r.Scale)
+		}
+	case pgtype.Numeric:
+		if v.Status == pgtype.Present && !v.NaN && v.InfinityModifier == pgtype.None {
+			d := r.round(numericDecimal(v))
+			return pgtype.Numeric{Int: d.Coefficient(), Exp: d.Exponent(), Status: pgtype.Present}
+		}
+	default:
+		// Round what a non-nil pointer points to
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Pointer && !rv.IsNil() {
+			return roundValue(rv.Elem().Interface(), r)
+		}
+	}
+	return value
+}
+
+// roundRow returns row with the columns that have a rounding rule rounded.
+// row itself is left untouched.
+func (o *bulkOptions) roundRow(row map[string]interface{}) map[string]interface{} {
+	if len(o.rounding) == 0 {
+		return row
+	}
+
+	rounded := make(map[string]interface{}, len(row))
+	for col, value := range row {
+		if rule, ok := o.rounding[col]; ok {
+			value = roundValue(value, rule)
+		}
+		rounded[col] = value
+	}
+	return rounded
+}
+
+// roundingKey returns rules in a stable text form, for cache keys
+func roundingKey(rules map[string]Rounding) string {
+	columns := make([]string, 0, len(rules))
+	for col, rule := range rules {
+		columns = append(columns, fmt.Sprintf("%s:%d:%d", col, rule.Scale, rule.Mode))
+	}
+	sort.Strings(columns)
+	return strings.Join(columns, ",")
+}
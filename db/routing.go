@@ -0,0 +1,165 @@
+package db
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// replicaRetryAfter is how long a replica that failed to serve a query is
+// skipped before FetchReadOnly tries it again
+const replicaRetryAfter = 30 * time.Second
+
+// HostConfig is the address of a read replica. The other connection settings
+// are taken from the DatabaseConfig it belongs to.
+type HostConfig struct {
+	Host string `yaml:"host"`
+	// Port defaults to the primary's port
+	Port int `yaml:"port"`
+}
+
+// replica is the pool of one read replica
+type replica struct {
+	db        *DB
+	host      HostConfig
+	downUntil atomic.Int64 // unix nanoseconds; 0 while healthy
+}
+
+// healthy reports whether the replica may be used at now
+func (r *replica) healthy(now time.Time) bool {
+	return now.UnixNano() >= r.downUntil.Load()
+}
+
+// FetchReadOnly executes query on a read replica of the DB created by InitDB;
+// see DB.FetchReadOnly
+func FetchReadOnly(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	return defaultDB().FetchReadOnly(ctx, query, args...)
+}
+
+// FetchReadOnly executes query like FetchDataFromTable on one of the replicas
+// listed in DatabaseConfig.Replicas, picked round-robin. A replica that fails
+// with a connection error is skipped for a while and the query moves on to the
+// next one; when no replica is configured or none can serve it, the query runs
+// on the primary. Writes such as Exec and InsertBulkData always go to the
+// primary, so only use FetchReadOnly for queries that tolerate replication lag.
+func (d *DB) FetchReadOnly(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	d.mu.RLock()
+	replicas := d.replicas
+	d.mu.RUnlock()
+
+	return d.fetchData(ctx, query, args, replicas)
+}
+
+// fetchReadOnly runs query like fetchDataFromTable on the next healthy replica
+// that can serve it, and on the primary when none can. The replicas only lend
+// their pools: the call belongs to d, so its metrics, spans, slow query log and
+// Stats cover the fetch wherever it ran.
+func (d *DB) fetchReadOnly(ctx context.Context, query string, args []interface{}, decode decodeOptions, replicas []*replica) ([]map[string]interface{}, error) {
+	n := len(replicas)
+	start := int(d.replicaNext.Add(1) % uint32(n))
+	for i := 0; i < n; i++ {
+		r := replicas[(start+i)%n]
+		if !r.healthy(time.Now()) {
+			continue
+		}
+
+		rows, err := r.db.fetchDataFromTable(ctx, query, args, decode)
+		if err == nil || !isConnectionError(err) || ctx.Err() != nil {
+			return rows, err
+		}
+
+		r.downUntil.Store(time.Now().Add(replicaRetryAfter).UnixNano())
+	}
+
+	return d.fetchDataFromTable(ctx, query, args, decode)
+}
+
+// isConnectionError reports whether err means the server could not be reached,
+// the connection broke or timed out, rather than the query failing. Errors of
+// the server and of the package itself, such as a GuardrailError or a failed
+// decode, are not connection errors, nor is a call canceled by the caller.
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrQueryCanceled) {
+		return false
+	}
+
+	// Failed connects wrap the error of the dial, a net.Error
+	var netErr net.Error
+	// pgconn marks the errors that happened before anything was sent
+	var retry interface{ SafeToRetry() bool }
+	return errors.As(err, &netErr) ||
+		(errors.As(err, &retry) && retry.SafeToRetry()) ||
+		pgconn.Timeout(err)
+}
+
+// openReplicas connects a pool for every replica of config
+func openReplicas(ctx context.Context, config *DatabaseConfig, options *poolOptions) ([]*replica, error) {
+	replicas := make([]*replica, 0, len(config.Replicas))
+
+	for _, host := range config.Replicas {
+		replicaOpts := *options
+		replicaOpts.host = &HostConfig{Host: host.Host, Port: host.Port}
+
+		pool, err := newPool(ctx, config, &replicaOpts)
+		if err != nil {
+			closeReplicas(replicas)
+			return nil, fmt.Errorf("error connecting to replica %s: %w", host.Host, err)
+		}
+
+		replicas = append(replicas, &replica{
+			db:   &DB{pool: pool, config: config, poolOpts: &replicaOpts, zone: config.fetchLocation()},
+			host: host,
+		})
+	}
+
+	return replicas, nil
+}
+
+// closeReplicas closes the pools of replicas
+func closeReplicas(replicas []*replica) {
+	for _, r := range replicas {
+		r.db.Close()
+	}
+}
+
+// applyHost points a replica pool at its host instead of the primary's
+func (o *poolOptions) applyHost(poolConfig *pgxpool.Config) {
+	if o.host == nil {
+		return
+	}
+
+	port := uint16(o.host.Port)
+	if port == 0 {
+		port = poolConfig.ConnConfig.Port
+	}
+
+	primary := poolConfig.ConnConfig.Host
+	poolConfig.ConnConfig.Host = o.host.Host
+	poolConfig.ConnConfig.Port = port
+	poolConfig.ConnConfig.TLSConfig = retargetTLS(poolConfig.ConnConfig.TLSConfig, primary, o.host.Host)
+	// Fallbacks repeat the connection per sslmode attempt and per host of a
+	// multi-host connection string; keep the sslmode attempts on the replica
+	for _, fallback := range poolConfig.ConnConfig.Fallbacks {
+		fallback.TLSConfig = retargetTLS(fallback.TLSConfig, fallback.Host, o.host.Host)
+		fallback.Host = o.host.Host
+		fallback.Port = port
+	}
+}
+
+// retargetTLS returns config verifying host instead of from, so certificate
+// hostname checks match the replica
+func retargetTLS(config *tls.Config, from, host string) *tls.Config {
+	if config == nil || config.ServerName != from {
+		return config
+	}
+	config = config.Clone()
+	config.ServerName = host
+	return config
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// retryableError is an error pgconn reports as safe to retry
+type retryableError struct{}
+
+func (retryableError) Error() string     { return "write failed before sending" }
+func (retryableError) SafeToRetry() bool { return true }
+
+func TestIsConnectionError(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network error", dialErr, true},
+		{"wrapped network error", fmt.Errorf("acquire: %w", dialErr), true},
+		{"safe to retry", fmt.Errorf("query: %w", retryableError{}), true},
+		{"server error", &pgconn.PgError{Code: "42P01"}, false},
+		{"guardrail", &GuardrailError{Reason: "DELETE without WHERE"}, false},
+		{"column type", &ColumnTypeError{Column: "id", Err: errors.New("bad")}, false},
+		{"decode error", errors.New("can't scan into dest[0]"), false},
+		{"canceled", context.Canceled, false},
+		{"canceled with handle", fmt.Errorf("%w: %w", ErrQueryCanceled, dialErr), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnectionError(tt.err); got != tt.want {
+				t.Errorf("isConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchReadOnlyIsCountedByThePrimary(t *testing.T) {
+	tests := []struct {
+		name      string
+		down      bool
+		wantTried bool
+	}{
+		{"replica tried, then primary", false, true},
+		{"replica skipped while down", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := lazyConfig()
+			config.Replicas = []HostConfig{{Host: "127.0.0.1", Port: 2}}
+			metrics := NewMetrics()
+			d, err := openDB(context.Background(), config, newPoolOptions([]PoolOption{WithMetrics(metrics)}))
+			if err != nil {
+				t.Fatalf("openDB: %v", err)
+			}
+			defer d.Close()
+
+			r := d.replicas[0]
+			downUntil := int64(0)
+			if tt.down {
+				downUntil = time.Now().Add(time.Hour).UnixNano()
+				r.downUntil.Store(downUntil)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if _, err := d.FetchReadOnly(ctx, "SELECT 1"); err == nil {
+				t.Fatal("FetchReadOnly succeeded without a server")
+			}
+
+			if tried := r.downUntil.Load() != downUntil; tried != tt.wantTried {
+				t.Errorf("replica tried = %v, want %v", tried, tt.wantTried)
+			}
+			if stats := d.Stats(); stats.Fetches != 1 || stats.Errors != 1 {
+				t.Errorf("Stats() counted %d fetches and %d errors, want 1 and 1", stats.Fetches, stats.Errors)
+			}
+			if got := metrics.Snapshot().Operations[OpFetch].Count; got != 1 {
+				t.Errorf("metrics counted %d fetches, want 1", got)
+			}
+			if stats := r.db.Stats(); stats.Fetches != 0 {
+				t.Errorf("replica counted %d fetches of its own, want 0", stats.Fetches)
+			}
+		})
+	}
+}
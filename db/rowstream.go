@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// RowStream iterates over the rows of a query one at a time, converting each
+// row only when it is reached, so large result sets are never held in memory.
+// A RowStream holds a pooled connection until Close is called.
+//
+//	stream, err := db.FetchStream(ctx, "SELECT * FROM trades")
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//
+//	for stream.Next() {
+//		row := stream.Row()
+//		...
+//	}
+//	return stream.Err()
+type RowStream struct {
+	release func()
+	rows    pgx.Rows
+	columns []string
+	row     map[string]interface{}
+	err     error
+	call    *queryCall
+	db      *DB
+	count   int  // Rows returned by Next so far
+	closed  bool // Whether the call was finished by Close
+}
+
+// FetchStream executes query on the package-level Pool and returns a RowStream over its rows
+func FetchStream(ctx context.Context, query string, args ...interface{}) (*RowStream, error) {
+	return defaultDB().FetchStream(ctx, query, args...)
+}
+
+// FetchStream executes query with args and returns a RowStream over its rows.
+// A WithTimeout option covers the whole iteration, up to Close.
+func (d *DB) FetchStream(ctx context.Context, query string, args ...interface{}) (*RowStream, error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, err
+	}
+
+	// Every stream gets a QueryHandle for Cancel, unless args bring their own
+	call, args := d.startCall(ctx, OpFetch, query, append([]interface{}{WithQueryHandle(&QueryHandle{})}, args...))
+
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(call.ctx)
+	if err != nil {
+		call.finish(0, err)
+		call.done()
+		return nil, call.wrapErr(err)
+	}
+
+	// Execute the query
+	rows, err := conn.Query(call.ctx, d.tagSQL(call.ctx, query), args...)
+	if err != nil {
+		release()
+		call.finish(0, err)
+		call.done()
+		return nil, call.wrapErr(err)
+	}
+
+	return &RowStream{release: release, rows: rows, columns: columnNames(rows), call: call, db: d}, nil
+}
+
+// Cancel asks the server to cancel the query of the stream, e.g. from another
+// goroutine serving a "stop query" button. Next then returns false and Err
+// reports an error matching ErrQueryCanceled. Close must still be called.
+func (s *RowStream) Cancel(ctx context.Context) error {
+	if s.call == nil {
+		return nil
+	}
+	return s.call.options.handle.Cancel(ctx)
+}
+
+// Columns returns the column names of the result
+func (s *RowStream) Columns() []string {
+	return s.columns
+}
+
+// Next advances to the next row, returning false at the end of the result or on error
+func (s *RowStream) Next() bool {
+	if s.err != nil || s.rows == nil || !s.rows.Next() {
+		s.row = nil
+		return false
+	}
+
+	row, err := scanRowMap(s.rows, s.columns, s.call.options.decode)
+	if err == nil {
+		err = s.db.transformRow(row)
+	}
+	if err != nil {
+		s.err = s.call.wrapErr(err)
+		s.row = nil
+		return false
+	}
+
+	s.row = row
+	s.count++
+	return true
+}
+
+// Row returns the current row, converted to native types
+func (s *RowStream) Row() map[string]interface{} {
+	return s.row
+}
+
+// Err returns the error that stopped the iteration, if any
+func (s *RowStream) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.rows != nil {
+		return s.call.wrapErr(s.rows.Err())
+	}
+	return nil
+}
+
+// Close releases the rows and the pooled connection and ends the call, which
+// is recorded with the rows read so far. It is safe to call more than once.
+func (s *RowStream) Close() {
+	if s.rows != nil {
+		s.rows.Close()
+		if s.err == nil {
+			s.err = s.call.wrapErr(s.rows.Err())
+		}
+		s.rows = nil
+	}
+	if s.release != nil {
+		s.release()
+		s.release = nil
+	}
+	if s.call != nil && !s.closed {
+		s.closed = true
+		s.call.finish(s.count, s.err)
+		s.call.done()
+	}
+}
+
+// QueryRaw executes query on the package-level Pool and returns the driver's
+// rows; see DB.QueryRaw
+func QueryRaw(ctx context.Context, query string, args ...interface{}) (pgx.Rows, func(), error) {
+	return defaultDB().QueryRaw(ctx, query, args...)
+}
+
+// QueryRaw executes query with args and returns the underlying pgx.Rows for
+// callers that need their own scanning. The returned release func closes the
+// rows and gives the pooled connection back; it must be called once the rows
+// are no longer needed and is safe to call more than once. Errors from the
+// rows are the driver's own, without ErrQueryTimeout or ErrQueryCanceled
+// wrapping.
+func (d *DB) QueryRaw(ctx context.Context, query string, args ...interface{}) (pgx.Rows, func(), error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, nil, err
+	}
+
+	call, args := d.startCall(ctx, OpFetch, query, args)
+
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(call.ctx)
+	if err != nil {
+		call.finish(0, err)
+		call.done()
+		return nil, nil, call.wrapErr(err)
+	}
+
+	// Execute the query
+	rows, err := conn.Query(call.ctx, d.tagSQL(call.ctx, query), args...)
+	if err != nil {
+		release()
+		call.finish(0, err)
+		call.done()
+		return nil, nil, call.wrapErr(err)
+	}
+
+	var once sync.Once
+	return rows, func() {
+		once.Do(func() {
+			rows.Close()
+			release()
+			// The command tag holds the rows the server sent, read or not
+			call.finish(int(rows.CommandTag().RowsAffected()), rows.Err())
+			call.done()
+		})
+	}, nil
+}
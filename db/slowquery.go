@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// slowQueryLog logs the calls that run longer than threshold
+type slowQueryLog struct {
+	threshold time.Duration
+	maxSQL    int
+	logger    pgx.Logger
+}
+
+// poolWaitKey is the context key of the time a call waited for connections
+type poolWaitKey struct{}
+
+// newSlowQueryLog returns the slow query settings of config. Slow queries go
+// to the logger of WithLogger, or are printed to stdout, whatever the logLevel.
+func newSlowQueryLog(config *DatabaseConfig, options *poolOptions) slowQueryLog {
+	if config.SlowQueryThreshold <= 0 {
+		return slowQueryLog{}
+	}
+
+	return slowQueryLog{threshold: config.SlowQueryThreshold, maxSQL: config.SlowQueryMaxSQL, logger: eventLogger(options.logger)}
+}
+
+// watch makes the call measure how long it waits for connections, if slow
+// queries are logged
+func (s slowQueryLog) watch(call *queryCall) {
+	if s.threshold <= 0 {
+		return
+	}
+	call.slowQuery = s
+	call.poolWait = new(atomic.Int64)
+	call.ctx = context.WithValue(call.ctx, poolWaitKey{}, call.poolWait)
+}
+
+// log logs the call when it took longer than the threshold
+func (s slowQueryLog) log(call *queryCall, rows int, err error) {
+	elapsed := time.Since(call.start)
+	if s.threshold <= 0 || elapsed <= s.threshold {
+		return
+	}
+
+	data := map[string]interface{}{
+		"operation": call.op,
+		"duration":  elapsed,
+		"rows":      rows,
+		"pool_wait": time.Duration(call.poolWait.Load()),
+	}
+	if call.statement != "" {
+		data["sql"] = s.truncate(call.statement)
+	}
+	if call.table != "" {
+		data["table"] = call.table
+	}
+	if err != nil {
+		data["err"] = err
+	}
+	s.logger.Log(call.parent, pgx.LogLevelWarn, "slow query", data)
+}
+
+// truncate cuts sql to maxSQL bytes, on a character boundary
+func (s slowQueryLog) truncate(sql string) string {
+	if s.maxSQL <= 0 || len(sql) <= s.maxSQL {
+		return sql
+	}
+	cut := s.maxSQL
+	for cut > 0 && !utf8.RuneStart(sql[cut]) {
+		cut--
+	}
+	return sql[:cut] + "..."
+}
+
+// addPoolWait adds the time since start to the pool wait of the call of ctx
+func addPoolWait(ctx context.Context, start time.Time) {
+	if wait, ok := ctx.Value(poolWaitKey{}).(*atomic.Int64); ok {
+		wait.Add(int64(time.Since(start)))
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithUnloggedStaging stages the rows in an UNLOGGED table instead of a
+// temporary one. Use it when the staging data should live in a shared
+// tablespace rather than in the session's temp_buffers; neither kind
+// generates WAL for the staged rows, but creating and dropping an UNLOGGED
+// table does write the catalog changes.
+//
+// InsertBulkData creates and drops the table inside the load's transaction,
+// so neither a failed load nor a crash of the client or the server leaves it
+// behind. A BulkWriter creates it once and drops it on Close: unlike a
+// temporary table, which goes away with its session, the table of a writer
+// that is never closed, or whose process dies, stays in the database until it
+// is dropped by hand. Such tables are named temp_<table>_<uuid>. Parallel
+// loads always stage in temporary tables and ignore it.
+func WithUnloggedStaging() BulkOption {
+	return func(o *bulkOptions) {
+		o.stagingUnlogged = true
+	}
+}
+
+// WithStagingOnCommitDrop creates the temporary staging table with ON COMMIT
+// DROP, so it never outlives the transaction of its load. Without it the
+// table stays on the pooled connection until the connection is closed.
+// BulkWriter keeps its staging table across transactions and ignores it.
+func WithStagingOnCommitDrop() BulkOption {
+	return func(o *bulkOptions) {
+		o.stagingOnCommitDrop = true
+	}
+}
+
+// WithStagingTablespace places the staging table in tablespace
+func WithStagingTablespace(tablespace string) BulkOption {
+	return func(o *bulkOptions) {
+		o.stagingTablespace = tablespace
+	}
+}
+
+// buildStagingTable constructs the statement creating staging with the
+// columns of table. onCommitDrop only applies to temporary tables.
+func buildStagingTable(staging, table string, unlogged, onCommitDrop bool, tablespace string) string {
+	var sql strings.Builder
+
+	if unlogged {
+		sql.WriteString("CREATE UNLOGGED TABLE ")
+	} else {
+		sql.WriteString("CREATE TEMPORARY TABLE ")
+	}
+	sql.WriteString(quoteIdent(staging))
+
+	if onCommitDrop && !unlogged {
+		sql.WriteString(" ON COMMIT DROP")
+	}
+	if tablespace != "" {
+		sql.WriteString(" TABLESPACE " + quoteIdent(tablespace))
+	}
+
+	fmt.Fprintf(&sql, " AS TABLE %s WITH NO DATA", quoteTable(table))
+	return sql.String()
+}
@@ -0,0 +1,135 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// DBStats is the state of the pool of a DB and the totals of its calls since
+// it was opened
+type DBStats struct {
+	// Pool is the state of the connection pool, nil when there is none
+	Pool *pgxpool.Stat
+
+	Fetches   int64
+	Execs     int64
+	BulkLoads int64
+	// Errors counts the calls of every kind that failed
+	Errors int64
+
+	RowsFetched  int64
+	RowsAffected int64
+	RowsCopied   int64
+}
+
+// callCounters are the totals of the calls of a DB
+type callCounters struct {
+	fetches, execs, bulkLoads, errors     atomic.Int64
+	rowsFetched, rowsAffected, rowsCopied atomic.Int64
+}
+
+// add counts a call of op that produced rows rows
+func (c *callCounters) add(op string, rows int, err error) {
+	if c == nil {
+		return
+	}
+	switch op {
+	case OpFetch:
+		c.fetches.Add(1)
+		c.rowsFetched.Add(int64(rows))
+	case OpExec:
+		c.execs.Add(1)
+		c.rowsAffected.Add(int64(rows))
+	case OpBulk:
+		c.bulkLoads.Add(1)
+		c.rowsCopied.Add(int64(rows))
+	}
+	if err != nil {
+		c.errors.Add(1)
+	}
+}
+
+// Stats returns the statistics of the DB created by InitDB
+func Stats() DBStats {
+	return defaultDB().Stats()
+}
+
+// Stats returns the state of the pool and the totals of the calls of the DB
+func (d *DB) Stats() DBStats {
+	stats := DBStats{
+		Fetches:      d.counters.fetches.Load(),
+		Execs:        d.counters.execs.Load(),
+		BulkLoads:    d.counters.bulkLoads.Load(),
+		Errors:       d.counters.errors.Load(),
+		RowsFetched:  d.counters.rowsFetched.Load(),
+		RowsAffected: d.counters.rowsAffected.Load(),
+		RowsCopied:   d.counters.rowsCopied.Load(),
+	}
+	if pool := d.Pool(); pool != nil {
+		stats.Pool = pool.Stat()
+	}
+	return stats
+}
+
+// ReportStats calls report with the statistics of the DB created by InitDB
+// every interval; see DB.ReportStats
+func ReportStats(ctx context.Context, interval time.Duration, report func(DBStats)) error {
+	return defaultDB().ReportStats(ctx, interval, report)
+}
+
+// ReportStats starts a goroutine that calls report with the Stats of the DB
+// every interval until ctx is done, to log them or push them to a monitoring
+// system. A nil report logs them at info level to the logger of WithLogger,
+// or to stdout.
+func (d *DB) ReportStats(ctx context.Context, interval time.Duration, report func(DBStats)) error {
+	if interval <= 0 {
+		return errors.New("db: ReportStats needs a positive interval")
+	}
+	if report == nil {
+		logger := eventLogger(d.logger)
+		report = func(stats DBStats) {
+			logger.Log(ctx, pgx.LogLevelInfo, "pool stats", stats.logData())
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				report(d.Stats())
+			}
+		}
+	}()
+	return nil
+}
+
+// logData returns the stats as the data of a log event
+func (s DBStats) logData() map[string]interface{} {
+	data := map[string]interface{}{
+		"fetches":       s.Fetches,
+		"execs":         s.Execs,
+		"bulk_loads":    s.BulkLoads,
+		"errors":        s.Errors,
+		"rows_fetched":  s.RowsFetched,
+		"rows_affected": s.RowsAffected,
+		"rows_copied":   s.RowsCopied,
+	}
+	if s.Pool != nil {
+		data["acquired_conns"] = s.Pool.AcquiredConns()
+		data["idle_conns"] = s.Pool.IdleConns()
+		data["total_conns"] = s.Pool.TotalConns()
+		data["max_conns"] = s.Pool.MaxConns()
+		data["empty_acquires"] = s.Pool.EmptyAcquireCount()
+		data["acquire_duration"] = s.Pool.AcquireDuration()
+	}
+	return data
+}
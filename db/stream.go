@@ -0,0 +1,184 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// maxStreamRecordSize is the longest record IngestStream accepts
+const maxStreamRecordSize = 64 * 1024 * 1024
+
+// IngestStream reads newline-delimited records from r, decodes each one with
+// decode and upserts them into table with the same temporary table, COPY and
+// ON CONFLICT UPDATE steps as InsertBulkData. Records are decoded while COPY is
+// running, so the input is never held in memory as a whole.
+//
+// The columns are taken from the first record and blank lines are skipped. The
+// byte slice passed to decode is reused for the next record and must not be
+// retained. The stream is loaded in a single transaction bounded by timeout;
+// chunking options are ignored. It returns the number of rows copied.
+func IngestStream(ctx context.Context, r io.Reader, decode func([]byte) (map[string]interface{}, error), table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) (int64, error) {
+	return defaultDB().IngestStream(ctx, r, decode, table, primaryKey, timeout, opts...)
+}
+
+// IngestStream streams newline-delimited records from r into table
+func (d *DB) IngestStream(ctx context.Context, r io.Reader, decode func([]byte) (map[string]interface{}, error), table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) (int64, error) {
+	options := &bulkOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamRecordSize)
+	line := 0
+
+	// nextRecord decodes the next non-blank line, returning io.EOF at the end of the input
+	nextRecord := func() (map[string]interface{}, error) {
+		for scanner.Scan() {
+			line++
+			record := bytes.TrimSpace(scanner.Bytes())
+			if len(record) == 0 {
+				continue
+			}
+			row, err := decode(record)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			return row, nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	return d.mergeStream(ctx, func(context.Context) (map[string]interface{}, error) {
+		return nextRecord()
+	}, table, primaryKey, timeout, options)
+}
+
+// InsertBulkChannel upserts the rows received from rows into table on the
+// package-level Pool; see DB.InsertBulkChannel
+func InsertBulkChannel(ctx context.Context, rows <-chan map[string]interface{}, table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) (int64, error) {
+	return defaultDB().InsertBulkChannel(ctx, rows, table, primaryKey, timeout, opts...)
+}
+
+// InsertBulkChannel upserts the rows received from rows into table like
+// InsertBulkData, copying each row as it arrives so memory stays bounded no
+// matter how many rows are sent. The load ends when rows is closed. The
+// columns are taken from the first row and every row is loaded in a single
+// transaction bounded by timeout; chunking options are ignored. It returns the
+// number of rows copied. The producer must stop sending once it returns.
+func (d *DB) InsertBulkChannel(ctx context.Context, rows <-chan map[string]interface{}, table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) (int64, error) {
+	options := &bulkOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return d.mergeStream(ctx, func(ctx context.Context) (map[string]interface{}, error) {
+		select {
+		case row, ok := <-rows:
+			if !ok {
+				return nil, io.EOF
+			}
+			return row, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}, table, primaryKey, timeout, options)
+}
+
+// InsertBulkFromSource upserts the rows of src into table on the package-level
+// Pool; see DB.InsertBulkFromSource
+func InsertBulkFromSource(ctx context.Context, src pgx.CopyFromSource, columns []string, table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) (int64, error) {
+	return defaultDB().InsertBulkFromSource(ctx, src, columns, table, primaryKey, timeout, opts...)
+}
+
+// InsertBulkFromSource upserts the rows of src, whose values are in the order
+// of columns, into table with the same temporary table, COPY and ON CONFLICT
+// steps as InsertBulkData. src is read while COPY is running, so rows can be
+// produced on demand. Values are copied as src returns them, without the
+// conversions of InsertBulkData. The load runs in a single transaction bounded
+// by timeout; chunking options are ignored. It returns the number of rows copied.
+func (d *DB) InsertBulkFromSource(ctx context.Context, src pgx.CopyFromSource, columns []string, table string, primaryKey []string, timeout time.Duration, opts ...BulkOption) (int64, error) {
+	options := &bulkOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("db: InsertBulkFromSource needs the column list")
+	}
+
+	return d.bulkCall(ctx, table, 0, func(ctx context.Context) (int64, error) {
+		// Create a new context with timeout
+		ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if err := d.prepareBulk(ctxWithTimeout, table, columns, options); err != nil {
+			return 0, err
+		}
+
+		return d.mergeInTx(ctxWithTimeout, table, columns, primaryKey, src, options)
+	})
+}
+
+// mergeStream upserts the rows returned by next, formatted like a batch of
+// InsertBulkData, into table in a single transaction. next returns io.EOF
+// after the last row and is called with the context bounded by timeout.
+func (d *DB) mergeStream(ctx context.Context, next func(context.Context) (map[string]interface{}, error), table string, primaryKey []string, timeout time.Duration, options *bulkOptions) (int64, error) {
+	return d.bulkCall(ctx, table, 0, func(ctx context.Context) (int64, error) {
+		return d.copyStream(ctx, next, table, primaryKey, timeout, options)
+	})
+}
+
+// copyStream runs mergeStream
+func (d *DB) copyStream(ctx context.Context, next func(context.Context) (map[string]interface{}, error), table string, primaryKey []string, timeout time.Duration, options *bulkOptions) (int64, error) {
+	// Create a new context with timeout
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	first, err := next(ctxWithTimeout)
+	if err == io.EOF {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	columns, err := d.bulkColumns(ctxWithTimeout, table, first, options)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := d.prepareBulk(ctxWithTimeout, table, columns, options); err != nil {
+		return 0, err
+	}
+
+	// Format every row the same way InsertBulkData formats a batch
+	pending := first
+	index := 0
+	src := newIteratorCopyFromSource(func() (map[string]interface{}, error) {
+		row := pending
+		if row == nil {
+			var err error
+			if row, err = next(ctxWithTimeout); err != nil {
+				return nil, err
+			}
+		}
+		pending = nil
+		if err := validateRow(index, row, columns, options.nullPolicy); err != nil {
+			return nil, err
+		}
+		index++
+		return formatBulkRow(index-1, row, columns, options)
+	}, columns)
+
+	return d.mergeInTx(ctxWithTimeout, table, columns, primaryKey, src, options)
+}
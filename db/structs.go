@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structFieldsCache caches the column to field index mapping per struct type
+var structFieldsCache sync.Map // map[reflect.Type]map[string][]int
+
+// Fetch executes query on the package-level Pool and scans every row into a T,
+// which must be a struct. Columns are matched to fields by their `db:"name"`
+// tag, or by the lower-cased field name when there is no tag; `db:"-"` skips a
+// field and anything after a comma in the tag is ignored. Fields of embedded
+// structs are matched as if they were declared on T. Columns without a
+// matching field are ignored. Use pointer or pgtype fields for nullable columns.
+func Fetch[T any](ctx context.Context, query string, args ...interface{}) ([]T, error) {
+	return FetchWith[T](ctx, defaultDB(), query, args...)
+}
+
+// FetchWith is Fetch running on the pool of d
+func FetchWith[T any](ctx context.Context, d *DB, query string, args ...interface{}) ([]T, error) {
+	return fetchStructs[T](ctx, d, 0, query, args, nil)
+}
+
+// FetchOneInto executes query on the package-level Pool and scans the first row
+// into a T, matching columns to fields like Fetch. It returns ErrNoRows when
+// the query produces no rows.
+func FetchOneInto[T any](ctx context.Context, query string, args ...interface{}) (T, error) {
+	return FetchOneIntoWith[T](ctx, defaultDB(), query, args...)
+}
+
+// FetchOneIntoWith is FetchOneInto running on the pool of d
+func FetchOneIntoWith[T any](ctx context.Context, d *DB, query string, args ...interface{}) (T, error) {
+	var zero T
+	items, err := fetchStructs[T](ctx, d, 1, query, args, nil)
+	if err != nil {
+		return zero, err
+	}
+	if len(items) == 0 {
+		return zero, ErrNoRows
+	}
+	return items[0], nil
+}
+
+// fetchStructs scans up to limit rows of query into T values; limit <= 0 reads
+// every row. The connection and the columns are recorded in meta, if not nil.
+func fetchStructs[T any](ctx context.Context, d *DB, limit int, query string, args []interface{}, meta *QueryMeta) ([]T, error) {
+	if err := d.checkSQL(query); err != nil {
+		return nil, err
+	}
+
+	call, args := d.startCall(ctx, OpFetch, query, args)
+	defer call.done()
+
+	result, err := scanStructs[T](call.ctx, d, limit, query, args, meta)
+	call.finish(len(result), err)
+	return result, call.wrapErr(err)
+}
+
+// scanStructs runs the query of fetchStructs
+func scanStructs[T any](ctx context.Context, d *DB, limit int, query string, args []interface{}, meta *QueryMeta) ([]T, error) {
+	fields, err := structFields(reflect.TypeOf((*T)(nil)).Elem())
+	if err != nil {
+		return nil, err
+	}
+
+	// Acquire a connection from the pool
+	conn, release, err := d.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Execute the query
+	rows, err := conn.Query(ctx, d.tagSQL(ctx, query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	fillMeta(meta, conn, rows)
+
+	colDescs := rows.FieldDescriptions()
+	result := make([]T, 0)
+
+	for rows.Next() {
+		var item T
+		value := reflect.ValueOf(&item).Elem()
+
+		dest := make([]interface{}, len(colDescs))
+		for i, colDesc := range colDescs {
+			if index, ok := fields[string(colDesc.Name)]; ok {
+				dest[i] = value.FieldByIndex(index).Addr().Interface()
+			} else {
+				// Unmatched columns still need a destination
+				dest[i] = new(interface{})
+			}
+		}
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		result = append(result, item)
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// structFields returns the column name to field index mapping of struct type t
+func structFields(t reflect.Type) (map[string][]int, error) {
+	if cached, ok := structFieldsCache.Load(t); ok {
+		return cached.(map[string][]int), nil
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("db: cannot scan rows into %s, a struct type is required", t)
+	}
+
+	fields := make(map[string][]int)
+	collectStructFields(t, nil, fields)
+
+	structFieldsCache.Store(t, fields)
+	return fields, nil
+}
+
+// collectStructFields adds the fields of t, prefixed by index, to fields
+func collectStructFields(t reflect.Type, index []int, fields map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		// Anything after a comma is an option, e.g. `db:"id,pk"`
+		tag, _, _ := strings.Cut(field.Tag.Get("db"), ",")
+		if tag == "-" {
+			continue
+		}
+
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		// Flatten untagged embedded structs
+		if field.Anonymous && tag == "" && field.Type.Kind() == reflect.Struct {
+			collectStructFields(field.Type, fieldIndex, fields)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := tag
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		// Fields closer to the top level win over embedded ones
+		if existing, ok := fields[name]; !ok || len(existing) > len(fieldIndex) {
+			fields[name] = fieldIndex
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// queryTagsKey is the context key of the tags added with ContextWithQueryTags
+type queryTagsKey struct{}
+
+// WithQueryTagging prepends a comment such as
+//
+//	/* route=/trades service=ingestor */
+//
+// to every statement the DB issues, so load can be attributed to its source in
+// pg_stat_statements and the server logs. static holds tags added to every
+// statement, e.g. the service name; tags from ContextWithQueryTags are added
+// per call and win over static tags with the same key. Statements run by
+// FetchPrepared and ExecPrepared are not tagged.
+func WithQueryTagging(static map[string]string) PoolOption {
+	return func(o *poolOptions) {
+		o.tagging = true
+		if o.staticTags == nil {
+			o.staticTags = make(map[string]string, len(static))
+		}
+		for key, value := range static {
+			o.staticTags[key] = value
+		}
+	}
+}
+
+// ContextWithQueryTags returns a copy of ctx carrying tags for the statements
+// run with it, merged with any tags ctx already carries. Tags are only
+// written when the pool was built with WithQueryTagging.
+func ContextWithQueryTags(ctx context.Context, tags map[string]string) context.Context {
+	merged := make(map[string]string, len(tags))
+	if existing, ok := ctx.Value(queryTagsKey{}).(map[string]string); ok {
+		for key, value := range existing {
+			merged[key] = value
+		}
+	}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, queryTagsKey{}, merged)
+}
+
+// tagSQL prepends the query tags of d and ctx to sql
+func (d *DB) tagSQL(ctx context.Context, sql string) string {
+	d.mu.RLock()
+	options := d.poolOpts
+	d.mu.RUnlock()
+
+	if options == nil || !options.tagging {
+		return sql
+	}
+
+	tags := make(map[string]string, len(options.staticTags))
+	for key, value := range options.staticTags {
+		tags[key] = value
+	}
+	if fromCtx, ok := ctx.Value(queryTagsKey{}).(map[string]string); ok {
+		for key, value := range fromCtx {
+			tags[key] = value
+		}
+	}
+	if len(tags) == 0 {
+		return sql
+	}
+
+	return formatQueryTags(tags) + " " + sql
+}
+
+// formatQueryTags renders tags as a SQL comment with the keys in sorted order
+func formatQueryTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// A */ inside a tag would end the comment early
+	escape := strings.NewReplacer("*/", "* /", "/*", "/ *", "\n", " ")
+
+	var b strings.Builder
+	b.WriteString("/*")
+	for _, key := range keys {
+		b.WriteString(" ")
+		b.WriteString(escape.Replace(key))
+		b.WriteString("=")
+		b.WriteString(escape.Replace(tags[key]))
+	}
+	b.WriteString(" */")
+	return b.String()
+}
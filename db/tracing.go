@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// Tracer starts the spans of the database calls of a DB, as the Start method
+// of an OpenTelemetry trace.Tracer does. The span must be a child of the span
+// in ctx, if any, and be carried by the returned context. The package has no
+// dependency on a tracing library; package dbotel adapts OpenTelemetry.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer. The database calls set these
+// attributes:
+//
+//	db.system         "postgresql"
+//	db.operation      OpFetch, OpExec, OpBulk or "transaction"
+//	db.statement      the SQL of fetch and exec calls
+//	db.sql.table      the target table of bulk loads and their transactions
+//	db.rows_affected  the rows returned, affected or copied, as an int64
+//	db.cache_hit      true for fetches answered by a QueryCache
+//
+// The duration of the call is the duration of the span.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	// End ends the span; err is the error the call failed with, or nil
+	End(err error)
+}
+
+// WithTracer traces the fetch, exec and bulk load calls of the DB, and the
+// transactions of the bulk loads, with spans started by t
+func WithTracer(t Tracer) PoolOption {
+	return func(o *poolOptions) {
+		o.tracer = t
+	}
+}
+
+// noSpan is the Span of calls that are not traced
+type noSpan struct{}
+
+func (noSpan) SetAttribute(string, interface{}) {}
+func (noSpan) End(error)                        {}
+
+// startSpan starts a span for op with the tracer of d, or a noSpan
+func (d *DB) startSpan(ctx context.Context, op string) (context.Context, Span) {
+	if d.tracer == nil {
+		return ctx, noSpan{}
+	}
+	ctx, span := d.tracer.Start(ctx, "db."+op)
+	span.SetAttribute("db.system", "postgresql")
+	span.SetAttribute("db.operation", op)
+	return ctx, span
+}
+
+// startCall is startQuery for a call of op running statement, which is
+// recorded by the metrics and traced by the tracer of d. The caller must
+// call finish with the result of the call.
+func (d *DB) startCall(ctx context.Context, op, statement string, args []interface{}) (*queryCall, []interface{}) {
+	call, args := d.startQuery(ctx, args)
+	call.op = op
+	call.statement = statement
+	call.start = time.Now()
+	call.metrics = d.metrics
+	call.counters = &d.counters
+	call.audit = d.audit
+	call.ctx, call.span = d.startSpan(call.ctx, op)
+	if statement != "" {
+		call.span.SetAttribute("db.statement", statement)
+	}
+
+	d.mu.RLock()
+	slowQuery := d.slowQuery
+	d.mu.RUnlock()
+	slowQuery.watch(call)
+
+	return call, args
+}
+
+// setTable records table as the target of the call, given inputRows rows
+func (c *queryCall) setTable(table string, inputRows int) {
+	c.table = table
+	c.inputRows = inputRows
+	c.span.SetAttribute("db.sql.table", table)
+}
+
+// bulkCall runs load, a bulk write of inputRows rows into table returning the
+// number of rows it wrote, as an OpBulk call. inputRows is 0 for streams,
+// whose size is not known up front.
+func (d *DB) bulkCall(ctx context.Context, table string, inputRows int, load func(context.Context) (int64, error)) (int64, error) {
+	call, _ := d.startCall(ctx, OpBulk, "", nil)
+	defer call.done()
+	call.setTable(table, inputRows)
+
+	rows, err := load(call.ctx)
+	call.finish(int(rows), err)
+	return rows, err
+}
+
+// finish reports the result of a call started with startCall, which produced
+// or affected rows rows, to the metrics, the span, the slow query log and the
+// audit log
+func (c *queryCall) finish(rows int, err error) {
+	c.metrics.record(c.op, c.start, int64(rows), err)
+	c.counters.add(c.op, rows, err)
+	c.slowQuery.log(c, rows, err)
+	c.audit.record(c, rows, err)
+	c.span.SetAttribute("db.rows_affected", int64(rows))
+	c.span.End(err)
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"fmt"
+)
+
+// WithColumnTransform applies fn to the value of column in every row fetched
+// as a map, e.g. to mask PII or convert currencies in one central place. It
+// runs after the native-type conversion, only for rows that hold the column.
+// Transforms registered for the same column run in order. Map results are
+// those of FetchData, FetchDataFromTable, FetchOne, FetchPrepared,
+// FetchStream, FetchInto and the functions built on them.
+func WithColumnTransform(column string, fn func(value interface{}) (interface{}, error)) PoolOption {
+	return func(o *poolOptions) {
+		if o.columnTransforms == nil {
+			o.columnTransforms = make(map[string][]func(interface{}) (interface{}, error))
+		}
+		o.columnTransforms[column] = append(o.columnTransforms[column], fn)
+	}
+}
+
+// WithRowTransform applies fn to every row fetched as a map, after the column
+// transforms. fn may change, add, rename or delete entries in place.
+func WithRowTransform(fn func(row map[string]interface{}) error) PoolOption {
+	return func(o *poolOptions) {
+		o.rowTransforms = append(o.rowTransforms, fn)
+	}
+}
+
+// transformRows applies the DB's transforms to every row of rows in place
+func (d *DB) transformRows(rows []map[string]interface{}) error {
+	for _, row := range rows {
+		if err := d.transformRow(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transformRow applies the DB's column and row transforms to row in place
+func (d *DB) transformRow(row map[string]interface{}) error {
+	d.mu.RLock()
+	options := d.poolOpts
+	d.mu.RUnlock()
+
+	if options == nil || row == nil {
+		return nil
+	}
+
+	for column, fns := range options.columnTransforms {
+		value, ok := row[column]
+		if !ok {
+			continue
+		}
+		for _, fn := range fns {
+			var err error
+			if value, err = fn(value); err != nil {
+				return fmt.Errorf("error transforming column %s: %w", column, err)
+			}
+		}
+		row[column] = value
+	}
+
+	for _, fn := range options.rowTransforms {
+		if err := fn(row); err != nil {
+			return fmt.Errorf("error transforming row: %w", err)
+		}
+	}
+
+	return nil
+}
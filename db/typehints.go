@@ -0,0 +1,97 @@
+package db
+
+// TypeHint is the column type a bulk load converts the values of a column to
+// when their Go type does not tell, such as strings holding timestamps or
+// JSON documents. Any PostgreSQL type name can be used as a hint, e.g.
+// TypeHint("int8range").
+type TypeHint string
+
+const (
+	// TypeTimestamptz parses strings as RFC3339 or the other layouts accepted
+	// by WithColumnTypeDetection
+	TypeTimestamptz TypeHint = "timestamptz"
+	// TypeTimestamp writes times as their wall clock, like WithTimestampColumns
+	TypeTimestamp TypeHint = "timestamp"
+	// TypeDate writes times as their calendar date
+	TypeDate TypeHint = "date"
+	// TypeJSONB takes strings and []byte as JSON text and marshals other values
+	TypeJSONB TypeHint = "jsonb"
+	// TypeJSON is TypeJSONB for json columns
+	TypeJSON TypeHint = "json"
+	// TypeText keeps strings that look like numbers as text
+	TypeText TypeHint = "text"
+	// TypeNumeric parses strings as numbers without going through float64
+	TypeNumeric TypeHint = "numeric"
+	// TypeUUID parses strings as UUIDs
+	TypeUUID TypeHint = "uuid"
+)
+
+// ColumnTypes maps column names to the type their values are written as
+type ColumnTypes map[string]TypeHint
+
+// WithColumnTypes declares the types of columns whose values would otherwise
+// be guessed from their Go type, e.g. that "event_ts" strings are timestamps
+// and "payload" strings are jsonb:
+//
+//	db.WithColumnTypes(db.ColumnTypes{"event_ts": db.TypeTimestamptz, "payload": db.TypeJSONB})
+//
+// A value that cannot be converted fails the call with a *ColumnTypeError.
+// Strings in a column named "time" are parsed as a TypeTimestamptz unless the
+// column is declared otherwise; its other values are passed through. With
+// WithColumnTypeDetection the types of the table are used instead.
+func WithColumnTypes(types ColumnTypes) BulkOption {
+	return func(o *bulkOptions) {
+		if o.columnTypes == nil {
+			o.columnTypes = make(ColumnTypes, len(types))
+		}
+		for col, hint := range types {
+			o.columnTypes[col] = hint
+		}
+	}
+}
+
+// columnType returns the type declared for col with WithColumnTypes, if any,
+// that value is converted to
+func (o *bulkOptions) columnType(col string, value interface{}) (string, bool) {
+	if hint, ok := o.columnTypes[col]; ok {
+		return string(hint), true
+	}
+	// Strings in the time column have always been parsed as timestamps; other
+	// values, such as epoch integers, go through unchanged
+	if _, isString := value.(string); isString && col == "time" {
+		if typ := o.timeColumns[col]; typ != "" {
+			return typ, true
+		}
+		return string(TypeTimestamptz), true
+	}
+	return "", false
+}
+
+// applyColumnTypes converts the values of row, at index i of the input, whose
+// column has a declared type. row itself is left untouched.
+func (o *bulkOptions) applyColumnTypes(i int, row map[string]interface{}, columns []string) (map[string]interface{}, error) {
+	var typed map[string]interface{}
+	for _, col := range columns {
+		typ, ok := o.columnType(col, row[col])
+		if !ok {
+			continue
+		}
+		value, err := coerceValue(row[col], typ)
+		if err != nil {
+			return nil, &ColumnTypeError{Row: i, Column: col, Type: typ, Value: row[col], Err: err}
+		}
+
+		if typed == nil {
+			typed = make(map[string]interface{}, len(row))
+			for k, v := range row {
+				typed[k] = v
+			}
+		}
+		typed[col] = value
+	}
+
+	if typed == nil {
+		return row, nil
+	}
+	return typed, nil
+}
@@ -0,0 +1,103 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+)
+
+func TestColumnType(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  bulkOptions
+		col      string
+		value    interface{}
+		wantType string
+		wantOK   bool
+	}{
+		{"undeclared", bulkOptions{}, "price", "1.5", "", false},
+		{"declared", bulkOptions{columnTypes: ColumnTypes{"payload": TypeJSONB}}, "payload", "{}", "jsonb", true},
+		{"declared wins over time", bulkOptions{columnTypes: ColumnTypes{"time": TypeText}}, "time", "now", "text", true},
+		{"time string", bulkOptions{}, "time", "2026-10-14T06:00:00Z", "timestamptz", true},
+		{"time string in timestamp column", bulkOptions{timeColumns: map[string]string{"time": "timestamp"}}, "time", "2026-10-14T06:00:00Z", "timestamp", true},
+		{"time epoch", bulkOptions{}, "time", int64(1760421600), "", false},
+		{"time value", bulkOptions{}, "time", time.Now(), "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, ok := tt.options.columnType(tt.col, tt.value)
+			if typ != tt.wantType || ok != tt.wantOK {
+				t.Errorf("columnType(%q, %v) = %q, %v, want %q, %v", tt.col, tt.value, typ, ok, tt.wantType, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestApplyColumnTypes(t *testing.T) {
+	options := &bulkOptions{columnTypes: ColumnTypes{"n": TypeNumeric}}
+	columns := []string{"time", "n"}
+
+	row := map[string]interface{}{"time": int64(1760421600), "n": "12.50"}
+	typed, err := options.applyColumnTypes(0, row, columns)
+	if err != nil {
+		t.Fatalf("applyColumnTypes: %v", err)
+	}
+	if typed["time"] != int64(1760421600) {
+		t.Errorf("epoch time = %#v, want it passed through", typed["time"])
+	}
+	if _, ok := typed["n"].(*pgtype.Numeric); !ok {
+		t.Errorf("n = %T, want *pgtype.Numeric", typed["n"])
+	}
+	if row["n"] != "12.50" {
+		t.Errorf("applyColumnTypes modified the input row: %v", row)
+	}
+
+	_, err = options.applyColumnTypes(4, map[string]interface{}{"time": "yesterday", "n": "1"}, columns)
+	var typeErr *ColumnTypeError
+	if !errors.As(err, &typeErr) || typeErr.Row != 4 || typeErr.Column != "time" {
+		t.Fatalf("applyColumnTypes error = %v, want a *ColumnTypeError for row 4, column time", err)
+	}
+}
+
+func TestFormatBulkRowsKeepsTimeValues(t *testing.T) {
+	at := time.Date(2026, 10, 14, 6, 30, 0, 0, time.FixedZone("BRT", -3*60*60))
+	tests := []struct {
+		name    string
+		options bulkOptions
+		col     string
+		want    interface{}
+	}{
+		{"time column", bulkOptions{}, "time", pgtype.Timestamptz{Time: at, Status: pgtype.Present}},
+		{"untyped column", bulkOptions{}, "created", pgtype.Timestamptz{Time: at, Status: pgtype.Present}},
+		{"timestamp column", bulkOptions{timeColumns: map[string]string{"created": "timestamp"}}, "created",
+			pgtype.Timestamp{Time: time.Date(2026, 10, 14, 6, 30, 0, 0, time.UTC), Status: pgtype.Present}},
+		{"date column", bulkOptions{timeColumns: map[string]string{"created": "date"}}, "created",
+			pgtype.Date{Time: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC), Status: pgtype.Present}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns := []string{tt.col}
+			row := map[string]interface{}{tt.col: at}
+
+			rows, err := formatBulkRows([]map[string]interface{}{row}, 0, columns, &tt.options)
+			if err != nil {
+				t.Fatalf("formatBulkRows: %v", err)
+			}
+			if got := rows[0][tt.col]; got != tt.want {
+				t.Errorf("formatBulkRows %s = %#v, want %#v", tt.col, got, tt.want)
+			}
+
+			single, err := formatBulkRow(0, row, columns, &tt.options)
+			if err != nil {
+				t.Fatalf("formatBulkRow: %v", err)
+			}
+			if got := single[tt.col]; got != tt.want {
+				t.Errorf("formatBulkRow %s = %#v, want %#v", tt.col, got, tt.want)
+			}
+		})
+	}
+}
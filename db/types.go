@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// applyTypes registers the database's enum and composite types, and the
+// hstore and PostGIS types when the config enables them, on every new
+// connection, ahead of the caller's AfterConnect hooks
+func (c *DatabaseConfig) applyTypes(poolConfig *pgxpool.Config) {
+	hstore, postgis := c.Hstore, c.PostGIS
+	next := poolConfig.AfterConnect
+
+	poolConfig.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		if err := registerEnums(ctx, conn); err != nil {
+			return err
+		}
+		if hstore {
+			if err := registerHstore(ctx, conn); err != nil {
+				return err
+			}
+		}
+		if postgis != "" {
+			if err := registerPostGIS(ctx, conn, postgis); err != nil {
+				return err
+			}
+		}
+		// Composite types come last, their attributes may use the types above
+		if err := registerComposites(ctx, conn); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(ctx, conn)
+		}
+		return nil
+	}
+}
+
+// registerEnums registers every enum type of the database with conn, so enum
+// columns are scanned as plain strings. Enum types created later are only
+// known to connections opened after them.
+func registerEnums(ctx context.Context, conn *pgx.Conn) error {
+	rows, err := conn.Query(ctx, `
+		SELECT t.oid, t.typname, array_agg(e.enumlabel ORDER BY e.enumsortorder)
+		FROM pg_type t
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		GROUP BY t.oid, t.typname`)
+	if err != nil {
+		return fmt.Errorf("error looking up enum types: %w", err)
+	}
+	defer rows.Close()
+
+	var types []pgtype.DataType
+	for rows.Next() {
+		var oid uint32
+		var name string
+		var labels []string
+		if err := rows.Scan(&oid, &name, &labels); err != nil {
+			return fmt.Errorf("error looking up enum types: %w", err)
+		}
+		types = append(types, pgtype.DataType{Value: pgtype.NewEnumType(name, labels), Name: name, OID: oid})
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error looking up enum types: %w", err)
+	}
+
+	for _, dt := range types {
+		conn.ConnInfo().RegisterDataType(dt)
+	}
+	return nil
+}